@@ -0,0 +1,686 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/download"
+	"github.com/Waddenn/plex-client/internal/export"
+	"github.com/Waddenn/plex-client/internal/player"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/query"
+	"github.com/Waddenn/plex-client/internal/store"
+)
+
+// Exit codes of the commands, for scripts.
+const (
+	exitOK          = 0
+	exitError       = 1   // The command failed
+	exitUsage       = 2   // Bad command line
+	exitNotFound    = 3   // Nothing, or more than one item, matched
+	exitAuth        = 4   // Not signed in, or Plex rejected the token
+	exitInterrupted = 130 // Stopped with Ctrl+C
+)
+
+// command is a subcommand run without the TUI.
+type command struct {
+	name    string
+	args    string
+	summary string
+	// online commands talk to the server; the others only read the cache
+	online bool
+	run    func(c *cli, args []string) int
+}
+
+var commands = []command{
+	{"sync", "[--force] [--section KEY]", "Update the library cache", true, runSync},
+	{"list", "movies|shows|episodes [--json] [--section KEY]", "List the cached library", false, runList},
+	{"search", "QUERY [--json] [--limit N]", "Search the cached library", false, runSearch},
+	{"play", "<ratingKey|title> [--resume|--from-start] [--quality Q]", "Play an item in mpv", true, runPlay},
+	{"rate", "<ratingKey|title> RATING", "Rate an item from 0 to 10", true, runRate},
+	{"export", "[movies|shows|seasons|episodes...] [--format F] [--fields LIST] [--filter QUERY] [--section KEY] [-o FILE]", "Export the cached library as JSON, NDJSON or CSV", false, runExport},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// usage prints the help of the whole program.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: plex-client [flags] [command]\n\n")
+	fmt.Fprintf(w, "Without a command, the TUI starts.\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-7s %s\n          %s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(w, "\nExit codes: 0 success, 1 error, 2 bad usage, 3 not found or ambiguous,\n")
+	fmt.Fprintf(w, "4 not signed in or token rejected, 130 interrupted.\n")
+}
+
+// cli runs commands against the cache and the active server.
+type cli struct {
+	cfg   *config.Config
+	db    *sql.DB
+	store *store.Store
+	plex  *plex.Client
+	cmd   command // The command being run
+
+	// tokenRejected is set when plex.tv refused the saved token at startup
+	tokenRejected bool
+
+	stdout io.Writer
+	stderr io.Writer
+	// progressOut shows sync progress; nil unless stderr is a terminal
+	progressOut io.Writer
+}
+
+func newCLI(cfg *config.Config, d *sql.DB, p *plex.Client, tokenRejected bool) *cli {
+	c := &cli{
+		cfg:           cfg,
+		db:            d,
+		store:         store.New(d),
+		plex:          p,
+		tokenRejected: tokenRejected,
+		stdout:        os.Stdout,
+		stderr:        os.Stderr,
+	}
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		c.progressOut = os.Stderr
+	}
+	return c
+}
+
+// run runs cmd with its arguments and returns the exit code.
+func (c *cli) run(cmd command, args []string) int {
+	if cmd.online {
+		switch {
+		case c.cfg.Plex.Token == "":
+			fmt.Fprintln(c.stderr, "plex-client: not signed in; run plex-client without a command to log in")
+			return exitAuth
+		case c.tokenRejected:
+			fmt.Fprintln(c.stderr, "plex-client: Plex rejected the saved token; run plex-client without a command to sign in again")
+			return exitAuth
+		case c.cfg.Plex.BaseURL == "":
+			fmt.Fprintln(c.stderr, "plex-client: no server selected; run plex-client without a command to pick one")
+			return exitAuth
+		}
+	}
+	c.cmd = cmd
+	return cmd.run(c, args)
+}
+
+// fail reports err and returns the matching exit code.
+func (c *cli) fail(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(c.stderr, "plex-client: interrupted")
+		return exitInterrupted
+	case errors.Is(err, plex.ErrUnauthorized):
+		fmt.Fprintf(c.stderr, "plex-client: %v; run plex-client without a command to sign in again\n", err)
+		return exitAuth
+	}
+	fmt.Fprintf(c.stderr, "plex-client: %v\n", err)
+	return exitError
+}
+
+// usageError reports a bad command line for the running command.
+func (c *cli) usageError(format string, a ...interface{}) int {
+	fmt.Fprintf(c.stderr, "plex-client %s: %s\nUsage: plex-client %s %s\n", c.cmd.name, fmt.Sprintf(format, a...), c.cmd.name, c.cmd.args)
+	return exitUsage
+}
+
+// flagSet returns the flags of the running command.
+func (c *cli) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: plex-client %s %s\n", c.cmd.name, c.cmd.args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses fs, allowing flags after the positional arguments as in
+// "list movies --json", and returns the positional arguments. ok is false
+// when parsing failed, with code the exit code.
+func parseArgs(fs *flag.FlagSet, args []string) (positional []string, code int, ok bool) {
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, exitOK, false
+			}
+			return nil, exitUsage, false
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, exitOK, true
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// interruptible returns a context cancelled by Ctrl+C.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+func runSync(c *cli, args []string) int {
+	fs := c.flagSet()
+	force := fs.Bool("force", false, "sync every section, even unchanged ones")
+	sectionKey := fs.String("section", "", "only sync the library section `KEY` (or title)")
+	pos, code, ok := parseArgs(fs, args)
+	if !ok {
+		return code
+	}
+	if len(pos) > 0 {
+		return c.usageError("unexpected argument %q", pos[0])
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	progress := &progressLine{w: c.progressOut}
+	// Otherwise the sync would bring back the state they change
+	c.flushOutbox(ctx)
+
+	if *sectionKey != "" {
+		sections, err := c.plex.GetSectionsContext(ctx)
+		if err != nil {
+			return c.fail(err)
+		}
+		for _, s := range sections {
+			if s.Key != *sectionKey && !strings.EqualFold(s.Title, *sectionKey) {
+				continue
+			}
+			var stats cache.SyncStats
+			err := cache.SyncSectionContext(ctx, c.plex, c.db, s, *force, c.cfg.Sync.Concurrency, &stats, progress.update)
+			progress.clear()
+			if err != nil {
+				return c.fail(err)
+			}
+			fmt.Fprintf(c.stdout, "Synced %s: %d added, %d removed\n", s.Title, stats.Added, stats.Removed)
+			return exitOK
+		}
+		fmt.Fprintf(c.stderr, "plex-client: no library section %q\n", *sectionKey)
+		return exitNotFound
+	}
+
+	var sectionErr error
+	for ev := range cache.SyncStream(ctx, c.plex, c.db, *force, c.cfg.Sync.Concurrency) {
+		switch {
+		case ev.Done:
+			progress.clear()
+			if ev.Err != nil {
+				return c.fail(ev.Err)
+			}
+			fmt.Fprintf(c.stdout, "Synced: %d added, %d removed\n", ev.Added, ev.Removed)
+		case ev.Err != nil:
+			progress.clear()
+			fmt.Fprintf(c.stderr, "plex-client: section %s: %v\n", ev.Section, ev.Err)
+			if sectionErr == nil {
+				sectionErr = ev.Err
+			}
+		default:
+			progress.update(ev.Status, ev.Added, ev.Removed)
+		}
+	}
+	if errors.Is(sectionErr, plex.ErrUnauthorized) {
+		return exitAuth
+	} else if sectionErr != nil {
+		return exitError
+	}
+	return exitOK
+}
+
+// progressLine shows sync progress on one terminal line. It stays silent
+// when w is nil, so logs of scripted syncs stay readable.
+type progressLine struct {
+	w     io.Writer
+	shown bool
+}
+
+func (p *progressLine) update(status string, added, removed int) {
+	if p.w == nil {
+		return
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s (+%d -%d)", status, added, removed)
+	p.shown = true
+}
+
+// clear removes the line before other output.
+func (p *progressLine) clear() {
+	if p.shown {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.shown = false
+	}
+}
+
+// listItem is the JSON form of a movie, show or episode.
+type listItem struct {
+	RatingKey  string `json:"ratingKey"`
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Year       int    `json:"year,omitempty"`
+	Show       string `json:"show,omitempty"`
+	Season     int    `json:"season,omitempty"`
+	Episode    int    `json:"episode,omitempty"`
+	Duration   int    `json:"duration,omitempty"` // Milliseconds
+	ViewCount  int    `json:"viewCount"`
+	ViewOffset int    `json:"viewOffset,omitempty"` // Milliseconds
+	Section    string `json:"section,omitempty"`
+}
+
+func newListItem(v plex.Video) listItem {
+	item := listItem{
+		RatingKey:  v.RatingKey,
+		Type:       v.Type,
+		Title:      v.Title,
+		Year:       v.Year,
+		Duration:   v.Duration,
+		ViewCount:  v.ViewCount,
+		ViewOffset: v.ViewOffset,
+		Section:    v.LibrarySectionID,
+	}
+	if v.Type == "episode" {
+		item.Show, item.Season, item.Episode = v.GrandparentTitle, v.ParentIndex, v.Index
+	}
+	return item
+}
+
+// displayTitle names v on one line, e.g. "Alien (1979)" or
+// "The Expanse - S01E03 - Remember the Cant".
+func displayTitle(v plex.Video) string {
+	switch {
+	case v.Type == "episode" && v.GrandparentTitle != "":
+		return fmt.Sprintf("%s - S%02dE%02d - %s", v.GrandparentTitle, v.ParentIndex, v.Index, v.Title)
+	case v.Year > 0:
+		return fmt.Sprintf("%s (%d)", v.Title, v.Year)
+	}
+	return v.Title
+}
+
+// printItems writes items as a JSON array, or one "ratingKey<TAB>title" line
+// each, prefixed with the type when withType is set.
+func (c *cli) printItems(items []plex.Video, asJSON, withType bool) int {
+	if asJSON {
+		out := make([]listItem, 0, len(items))
+		for _, v := range items {
+			out = append(out, newListItem(v))
+		}
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+	for _, v := range items {
+		if withType {
+			fmt.Fprintf(c.stdout, "%s\t", v.Type)
+		}
+		fmt.Fprintf(c.stdout, "%s\t%s\n", v.RatingKey, displayTitle(v))
+	}
+	return exitOK
+}
+
+func runList(c *cli, args []string) int {
+	fs := c.flagSet()
+	asJSON := fs.Bool("json", false, "print a JSON array")
+	section := fs.String("section", "", "only list the library section `KEY`")
+	pos, code, ok := parseArgs(fs, args)
+	if !ok {
+		return code
+	}
+	if len(pos) != 1 {
+		return c.usageError("expected one of movies, shows or episodes")
+	}
+
+	var items []plex.Video
+	var err error
+	switch pos[0] {
+	case "movies":
+		items, err = c.store.ListLibrary("movie", *section)
+	case "shows":
+		items, err = c.store.ListLibrary("show", *section)
+	case "episodes":
+		items, err = c.store.ListAllEpisodes()
+		if *section != "" {
+			kept := items[:0]
+			for _, v := range items {
+				if v.LibrarySectionID == *section {
+					kept = append(kept, v)
+				}
+			}
+			items = kept
+		}
+	default:
+		return c.usageError("unknown kind %q, expected movies, shows or episodes", pos[0])
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.printItems(items, *asJSON, false)
+}
+
+func runSearch(c *cli, args []string) int {
+	fs := c.flagSet()
+	asJSON := fs.Bool("json", false, "print a JSON array")
+	limit := fs.Int("limit", 20, "return at most `N` items of each type")
+	pos, code, ok := parseArgs(fs, args)
+	if !ok {
+		return code
+	}
+	query := strings.Join(pos, " ")
+	if strings.TrimSpace(query) == "" {
+		return c.usageError("missing query")
+	}
+
+	res, err := c.store.Search(query, *limit)
+	if err != nil {
+		return c.fail(err)
+	}
+	items := res.All()
+	if len(items) == 0 {
+		if *asJSON {
+			c.printItems(nil, true, true)
+		}
+		fmt.Fprintf(c.stderr, "plex-client: nothing matches %q\n", query)
+		return exitNotFound
+	}
+	return c.printItems(items, *asJSON, true)
+}
+
+func runPlay(c *cli, args []string) int {
+	fs := c.flagSet()
+	resume := fs.Bool("resume", false, "resume from the saved position (default unless player.resume_default is start_over)")
+	fromStart := fs.Bool("from-start", false, "ignore the saved position")
+	quality := fs.String("quality", c.cfg.Player.Quality, "playback `quality`, see player.quality")
+	pos, code, ok := parseArgs(fs, args)
+	if !ok {
+		return code
+	}
+	target := strings.Join(pos, " ")
+	if target == "" {
+		return c.usageError("missing rating key or title")
+	}
+
+	v, code := c.resolve(target)
+	if code != exitOK {
+		return code
+	}
+	if v.Type == "show" {
+		ep, err := c.nextEpisode(v.RatingKey)
+		if err != nil {
+			return c.fail(err)
+		}
+		if ep == nil {
+			fmt.Fprintf(c.stderr, "plex-client: no cached episodes of %s; run plex-client sync\n", v.Title)
+			return exitNotFound
+		}
+		v = *ep
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	// A downloaded file plays without the server, from the cached position
+	downloads := download.NewStore(c.db)
+	local, hasLocal := downloads.LocalPath(v.RatingKey)
+	item, err := c.plex.GetMetadataContext(ctx, v.RatingKey)
+	if err != nil && !hasLocal {
+		return c.fail(err)
+	}
+	if err != nil {
+		item = &v
+		if dl, err := downloads.Get(v.RatingKey); err == nil && item.Title == "" {
+			item.Title = dl.Title
+		}
+	}
+	playbackURL := local
+	if !hasLocal {
+		if playbackURL, err = c.plex.PlaybackURLContext(ctx, *item, *quality); err != nil {
+			return c.fail(err)
+		}
+	}
+	// Without a prompt, player.resume_default decides
+	var start int64
+	offset := int64(item.ViewOffset)
+	if c.cfg.Player.Resumable(offset) && !*fromStart && (*resume || c.cfg.Player.ResumeDefault != config.ResumeStartOver) {
+		start = offset
+	}
+
+	title := displayTitle(*item)
+	fmt.Fprintf(c.stderr, "Playing %s\n", title)
+	// mpv gets Ctrl+C as well and quits; the watch state is saved as usual
+	reporter := &cache.WatchStateReporter{Plex: c.plex, DB: c.db, Outbox: cache.NewOutbox(c.db)}
+	_, err = player.Play(title, playbackURL, item.RatingKey, start, c.cfg, reporter)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	c.flushOutbox(flushCtx)
+	if err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+func runRate(c *cli, args []string) int {
+	fs := c.flagSet()
+	pos, code, ok := parseArgs(fs, args)
+	if !ok {
+		return code
+	}
+	if len(pos) < 2 {
+		return c.usageError("expected a rating key or title and a rating")
+	}
+	rating, err := strconv.ParseFloat(pos[len(pos)-1], 64)
+	if err != nil || rating < 0 || rating > 10 {
+		return c.usageError("rating %q is not a number from 0 to 10", pos[len(pos)-1])
+	}
+
+	v, code := c.resolve(strings.Join(pos[:len(pos)-1], " "))
+	if code != exitOK {
+		return code
+	}
+	title := v.RatingKey
+	if v.Title != "" {
+		title = displayTitle(v)
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	reporter := &cache.WatchStateReporter{Plex: c.plex, DB: c.db, Outbox: cache.NewOutbox(c.db)}
+	queued, err := reporter.Rate(ctx, v.RatingKey, rating)
+	switch {
+	case queued:
+		fmt.Fprintf(c.stderr, "plex-client: the rating of %s will be sent once the server is reachable: %v\n", title, err)
+	case err != nil:
+		return c.fail(err)
+	default:
+		fmt.Fprintf(c.stderr, "Rated %s %g/10\n", title, rating)
+	}
+	return exitOK
+}
+
+// flushOutbox sends the watch state and ratings queued while the server was
+// unreachable, here or in the TUI, and reports what is still left.
+func (c *cli) flushOutbox(ctx context.Context) {
+	outbox := cache.NewOutbox(c.db)
+	if n, err := outbox.Len(); err != nil || n == 0 {
+		return
+	}
+	if _, err := outbox.Flush(ctx, c.plex); err != nil {
+		n, _ := outbox.Len()
+		fmt.Fprintf(c.stderr, "plex-client: %d updates will be sent once the server is reachable: %v\n", n, err)
+	}
+}
+
+// resolve finds the item target names: a rating key of the cache, or a
+// title looked up in the cache. An exact title wins over titles containing
+// target, which win over other search hits; more than one candidate is
+// ambiguous. Digits are a title first, such as "1917", unless they are a
+// cached key; a number matching nothing is taken as a key not synced yet.
+func (c *cli) resolve(target string) (plex.Video, int) {
+	if isRatingKey(target) {
+		if show, err := c.store.GetSeriesMetadata(target); err == nil {
+			show.RatingKey, show.Type = target, "show"
+			return *show, exitOK
+		}
+		for _, isEpisode := range []bool{false, true} {
+			if _, err := c.store.GetVideoMetadata(target, isEpisode); err == nil {
+				return plex.Video{RatingKey: target}, exitOK
+			}
+		}
+	}
+
+	res, err := c.store.Search(target, 50)
+	if err != nil {
+		return plex.Video{}, c.fail(err)
+	}
+	hits := res.All()
+	for _, match := range []func(v plex.Video) bool{
+		func(v plex.Video) bool {
+			return strings.EqualFold(v.Title, target) || strings.EqualFold(displayTitle(v), target)
+		},
+		func(v plex.Video) bool {
+			return strings.Contains(strings.ToLower(displayTitle(v)), strings.ToLower(target))
+		},
+		func(plex.Video) bool { return true },
+	} {
+		var found []plex.Video
+		for _, v := range hits {
+			if match(v) {
+				found = append(found, v)
+			}
+		}
+		switch {
+		case len(found) == 1:
+			return found[0], exitOK
+		case len(found) > 1:
+			fmt.Fprintf(c.stderr, "plex-client: %q matches several items; pass a rating key:\n", target)
+			for _, v := range found {
+				fmt.Fprintf(c.stderr, "  %s\t%s\t%s\n", v.RatingKey, v.Type, displayTitle(v))
+			}
+			return plex.Video{}, exitNotFound
+		}
+	}
+	if isRatingKey(target) {
+		return plex.Video{RatingKey: target}, exitOK
+	}
+	fmt.Fprintf(c.stderr, "plex-client: nothing matches %q\n", target)
+	return plex.Video{}, exitNotFound
+}
+
+func isRatingKey(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+// nextEpisode returns the first unwatched episode of a show in the cache,
+// or its first episode when all were watched. Specials come last.
+func (c *cli) nextEpisode(showID string) (*plex.Video, error) {
+	seasons, err := c.store.ListSeasons(showID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(seasons, func(i, j int) bool {
+		return seasons[i].Index != "0" && seasons[j].Index == "0"
+	})
+
+	var first *plex.Video
+	for _, season := range seasons {
+		episodes, err := c.store.ListEpisodes(season.RatingKey)
+		if err != nil {
+			return nil, err
+		}
+		for i := range episodes {
+			if episodes[i].ViewCount == 0 {
+				return &episodes[i], nil
+			}
+			if first == nil {
+				first = &episodes[i]
+			}
+		}
+	}
+	return first, nil
+}
+
+func runExport(c *cli, args []string) int {
+	fs := c.flagSet()
+	format := fs.String("format", "", "`json`, ndjson or csv (default: from the -o extension, else json)")
+	fields := fs.String("fields", "", "comma-separated `fields`, or \"all\" (default "+strings.Join(export.DefaultFields, ",")+")")
+	filter := fs.String("filter", "", "keep items matching `QUERY`, as typed in the browser, e.g. \"res:4k unwatched\"")
+	section := fs.String("section", "", "only export the library section `KEY`")
+	output := fs.String("o", "", "write to `FILE` instead of stdout")
+	pos, code, ok := parseArgs(fs, args)
+	if !ok {
+		return code
+	}
+
+	opts := export.Options{Section: *section}
+	for _, arg := range pos {
+		k, err := export.ParseKind(arg)
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+		opts.Kinds = append(opts.Kinds, k)
+	}
+	var err error
+	if opts.Fields, err = export.ParseFields(*fields); err != nil {
+		return c.usageError("%v", err)
+	}
+	if *filter != "" {
+		if opts.Match, err = query.Parse(*filter); err != nil {
+			return c.usageError("filter: %v", err)
+		}
+	}
+	f := export.JSON
+	if *format != "" {
+		if f, err = export.ParseFormat(*format); err != nil {
+			return c.usageError("%v", err)
+		}
+	} else if guessed, ok := export.FormatOf(*output); ok {
+		f = guessed
+	}
+
+	out := c.stdout
+	var file *os.File
+	if *output != "" {
+		if file, err = os.Create(*output); err != nil {
+			return c.fail(err)
+		}
+		out = file
+	}
+	n, err := export.Write(out, c.store, f, opts)
+	if file != nil {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+	if file != nil {
+		fmt.Fprintf(c.stderr, "Exported %d items to %s\n", n, *output)
+	}
+	return exitOK
+}
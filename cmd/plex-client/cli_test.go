@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/db"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+)
+
+// newTestCLI returns a cli over a fresh cache holding three movies and a show
+// with three episodes, and its stdout and stderr.
+func newTestCLI(t *testing.T) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	d, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	var n int
+	movies := []plex.Video{
+		{RatingKey: "1", Title: "Heat", Year: 1995, Duration: 10_200_000},
+		{RatingKey: "2", Title: "Alien", Year: 1979, ViewCount: 1},
+		{RatingKey: "3", Title: "Aliens", Year: 1986},
+	}
+	steps := []error{
+		func() error {
+			_, err := d.Exec(`INSERT INTO sections (key, title, type, updated_at) VALUES ('1', 'Movies', 'movie', 0), ('2', 'TV', 'show', 0)`)
+			return err
+		}(),
+		cache.SaveMovies(d, "1", movies, &n, nil),
+		cache.SaveSeries(d, "2", []plex.Directory{{RatingKey: "10", Title: "The Expanse"}}, &n, nil),
+		cache.SaveSeasons(d, "10", []plex.Directory{{RatingKey: "11", Type: "season", Index: "1"}, {RatingKey: "12", Type: "season", Index: "2"}}, &n, nil),
+		cache.SaveEpisodes(d, "11", []plex.Video{
+			{RatingKey: "101", Title: "Dulcinea", Index: 1, ViewCount: 1},
+			{RatingKey: "102", Title: "The Big Empty", Index: 2},
+		}, &n, nil),
+		cache.SaveEpisodes(d, "12", []plex.Video{{RatingKey: "201", Title: "Safe", Index: 1}}, &n, nil),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatalf("Failed to fill the cache: %v", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	c := &cli{cfg: config.Defaults(), db: d, store: store.New(d), stdout: &stdout, stderr: &stderr}
+	return c, &stdout, &stderr
+}
+
+func runCommand(c *cli, args ...string) int {
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		panic("unknown command " + args[0])
+	}
+	return c.run(cmd, args[1:])
+}
+
+func TestListJSON(t *testing.T) {
+	c, stdout, _ := newTestCLI(t)
+
+	if code := runCommand(c, "list", "movies", "--json"); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	var movies []listItem
+	if err := json.Unmarshal(stdout.Bytes(), &movies); err != nil {
+		t.Fatalf("Expected JSON, got %q: %v", stdout, err)
+	}
+	if len(movies) != 3 || movies[0].Title != "Alien" || movies[2].Title != "Heat" {
+		t.Fatalf("Expected the movies sorted by title, got %+v", movies)
+	}
+	if m := movies[2]; m.RatingKey != "1" || m.Year != 1995 || m.Duration != 10_200_000 || m.Section != "1" || m.Type != "movie" {
+		t.Errorf("Unexpected movie %+v", m)
+	}
+
+	stdout.Reset()
+	if code := runCommand(c, "list", "--json", "episodes"); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	var episodes []listItem
+	if err := json.Unmarshal(stdout.Bytes(), &episodes); err != nil {
+		t.Fatalf("Expected JSON, got %q: %v", stdout, err)
+	}
+	if len(episodes) != 3 {
+		t.Fatalf("Expected 3 episodes, got %+v", episodes)
+	}
+	if e := episodes[1]; e.Show != "The Expanse" || e.Season != 1 || e.Episode != 2 || e.Section != "2" {
+		t.Errorf("Expected S01E02 of 'The Expanse', got %+v", e)
+	}
+
+	stdout.Reset()
+	runCommand(c, "list", "shows")
+	if got := stdout.String(); got != "10\tThe Expanse\n" {
+		t.Errorf("Unexpected text output %q", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c, _, stderr := newTestCLI(t)
+	// A title made of digits, in a section of its own to leave the others be
+	var n int
+	if err := cache.SaveMovies(c.db, "3", []plex.Video{{RatingKey: "7", Title: "1917", Year: 2019}}, &n, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		key    string
+		code   int
+	}{
+		{"alien", "2", exitOK},           // Exact title beats "Aliens"
+		{"HEAT", "1", exitOK},            // Any case
+		{"expanse", "10", exitOK},        // Part of a title
+		{"42", "42", exitOK},             // Rating key, not synced yet
+		{"101", "101", exitOK},           // Cached rating key
+		{"1", "1", exitOK},               // Cached key over the title containing it
+		{"1917", "7", exitOK},            // Title made of digits
+		{"alie", "", exitNotFound},       // Ambiguous
+		{"godfather", "", exitNotFound},  // No match
+		{"the big empty", "102", exitOK}, // Episode
+	}
+	for _, tt := range tests {
+		v, code := c.resolve(tt.target)
+		if code != tt.code || v.RatingKey != tt.key {
+			t.Errorf("resolve(%q): expected %q (exit %d), got %q (exit %d)", tt.target, tt.key, tt.code, v.RatingKey, code)
+		}
+	}
+	if !strings.Contains(stderr.String(), "3\tmovie\tAliens (1986)") {
+		t.Errorf("Expected the candidates of an ambiguous title, got %q", stderr)
+	}
+
+	show, _ := c.resolve("10")
+	if show.Type != "show" {
+		t.Errorf("Expected a show for its rating key, got %+v", show)
+	}
+	if ep, err := c.nextEpisode("10"); err != nil || ep == nil || ep.RatingKey != "102" {
+		t.Errorf("Expected the first unwatched episode 102, got %+v (%v)", ep, err)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	c, _, _ := newTestCLI(t)
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"list"}, exitUsage},
+		{[]string{"list", "albums"}, exitUsage},
+		{[]string{"list", "movies", "--bogus"}, exitUsage},
+		{[]string{"search"}, exitUsage},
+		{[]string{"search", "godfather"}, exitNotFound},
+		{[]string{"search", "alien"}, exitOK},
+		{[]string{"sync"}, exitAuth}, // Not signed in
+		{[]string{"play", "alien"}, exitAuth},
+		{[]string{"rate", "alien", "9"}, exitAuth},
+	}
+	for _, tt := range tests {
+		if code := runCommand(c, tt.args...); code != tt.code {
+			t.Errorf("%v: expected exit code %d, got %d", tt.args, tt.code, code)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	c, _, _ := newTestCLI(t)
+	down := false
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		requests = append(requests, r.URL.Query().Get("key")+" "+r.URL.Query().Get("rating"))
+	}))
+	defer srv.Close()
+	c.cfg.Plex.Token, c.cfg.Plex.BaseURL = "token", srv.URL
+	c.plex = plex.New(srv.URL, "token", "client-id", appinfo.Default())
+
+	if code := runCommand(c, "rate", "alien", "9"); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	// Queued while the server is down, sent with the next rating
+	down = true
+	if code := runCommand(c, "rate", "the", "expanse", "7.5"); code != exitOK {
+		t.Fatalf("Expected a queued rating to succeed, got exit code %d", code)
+	}
+	if n, _ := cache.NewOutbox(c.db).Len(); n != 1 {
+		t.Errorf("Expected the rating queued, got %d queued actions", n)
+	}
+	down = false
+	runCommand(c, "rate", "1", "4")
+	if want := "[2 9 10 7.5 1 4]"; fmt.Sprint(requests) != want {
+		t.Errorf("Expected ratings %s, got %v", want, requests)
+	}
+
+	for _, args := range [][]string{
+		{"rate", "alien"},
+		{"rate", "alien", "11"},
+		{"rate", "alien", "great"},
+	} {
+		if code := runCommand(c, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	c, stdout, _ := newTestCLI(t)
+
+	code := runCommand(c, "export", "movies", "--fields", "title,year", "--filter", "year:..1990", "--format", "csv")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if want := "title,year\nAlien,1979\nAliens,1986\n"; stdout.String() != want {
+		t.Errorf("Expected %q, got %q", want, stdout.String())
+	}
+
+	path := t.TempDir() + "/library.ndjson"
+	if code := runCommand(c, "export", "-o", path); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 movies, 1 show, 2 seasons and 3 episodes, one object per line
+	if lines := strings.Count(string(data), "\n"); lines != 9 || !strings.HasPrefix(string(data), `{"type":"movie"`) {
+		t.Errorf("Expected 9 NDJSON lines, got:\n%s", data)
+	}
+
+	for _, args := range [][]string{
+		{"export", "albums"},
+		{"export", "--fields", "bitrate"},
+		{"export", "--filter", "year:abc"},
+		{"export", "--format", "xml"},
+	} {
+		if code := runCommand(c, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"time"
 
 	"github.com/Waddenn/plex-client/internal/appinfo"
+	"github.com/Waddenn/plex-client/internal/auth"
 	"github.com/Waddenn/plex-client/internal/cache"
 	"github.com/Waddenn/plex-client/internal/config"
 	"github.com/Waddenn/plex-client/internal/db"
 	"github.com/Waddenn/plex-client/internal/plex"
 	"github.com/Waddenn/plex-client/internal/tui"
+	"github.com/Waddenn/plex-client/internal/tui/browser"
 	"github.com/Waddenn/plex-client/internal/tui/shared"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -23,16 +28,34 @@ func main() {
 		tokenFlag   = flag.String("token", "", "Plex Token")
 		forceSync   = flag.Bool("force-sync", false, "Force full cache sync")
 	)
+	flag.Usage = usage
 	flag.Parse()
 
+	// A command runs without the TUI, see cli.go
+	name := flag.Arg(0)
+	cmd, isCommand := findCommand(name)
+	switch {
+	case name == "help":
+		flag.CommandLine.SetOutput(os.Stdout)
+		usage()
+		return
+	case name != "" && name != "login" && !isCommand:
+		fmt.Fprintf(os.Stderr, "plex-client: unknown command %q\n\n", name)
+		usage()
+		os.Exit(exitUsage)
+	}
+	// Commands reading only the cache skip every request to plex.tv and the server
+	offline := isCommand && !cmd.online
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
 	// Apply flags to config
-	if *baseURLFlag != "" {
+	if *baseURLFlag != "" && *baseURLFlag != cfg.Plex.BaseURL {
 		cfg.Plex.BaseURL = *baseURLFlag
+		cfg.Plex.ServerID = "" // Identified again below
 	}
 	if *tokenFlag != "" {
 		cfg.Plex.Token = *tokenFlag
@@ -48,19 +71,43 @@ func main() {
 		}
 	}
 
-	// Check for commands
-	if len(os.Args) > 1 && os.Args[1] == "login" {
+	if name == "login" {
 		fmt.Println("ℹ️  Login is now handled directly in the TUI.")
 	}
 
-	d, err := db.Open()
+	info := appinfo.Default()
+
+	// A revoked token sends the TUI back to login instead of syncing
+	tokenRejected := !offline && cfg.Plex.Token != "" && !checkToken(cfg, info)
+
+	if !offline && !tokenRejected && cfg.Plex.ServerID == "" && cfg.Plex.BaseURL != "" && cfg.Plex.Token != "" {
+		identifyServer(cfg, info)
+	}
+
+	d, err := db.Open(cfg.Plex.CacheID())
 	if err != nil {
 		log.Fatalf("Database error: %v", err)
 	}
-	defer d.Close()
 
-	info := appinfo.Default()
-	p := plex.New(cfg.Plex.BaseURL, cfg.Plex.Token, cfg.Plex.ClientIdentifier, info)
+	if _, err := browser.ParseSort(cfg.UI.SortBy); err != nil {
+		log.Printf("Warning: invalid ui.sort_by, sorting by title: %v", err)
+	}
+	if r := cfg.Player.ResumeDefault; r != config.ResumeFromOffset && r != config.ResumeStartOver {
+		log.Printf("Warning: invalid player.resume_default %q, resuming by default", r)
+	}
+
+	if uris := cfg.Plex.ConnectionURIs(); len(uris) > 1 && !offline && !tokenRejected {
+		selectConnection(cfg, info, uris)
+	}
+
+	p := plex.New(cfg.Plex.BaseURL, cfg.Plex.ServerToken(), cfg.Plex.ClientIdentifier, info)
+	p.SetConnections(cfg.Plex.ConnectionURIs())
+
+	if isCommand {
+		code := newCLI(cfg, d, p, tokenRejected).run(cmd, flag.Args()[1:])
+		d.Close()
+		os.Exit(code)
+	}
 
 	// Check if we have data
 	hasData := false
@@ -78,36 +125,102 @@ func main() {
 	forceSyncFlag := *forceSync || cfg.Sync.ForceSyncOnStart
 
 	// Only attempt sync if we are authenticated
-	if cfg.Plex.Token != "" {
+	if cfg.Plex.Token != "" && !tokenRejected {
 		if !hasData || forceSyncFlag {
 			fmt.Println("Syncing library for the first time... This might take a while.")
-			if err := cache.Sync(p, d, forceSyncFlag, func(s string, a int) {
+			// Ctrl+C stops the sync; what was synced so far is kept.
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err := cache.SyncContext(ctx, p, d, forceSyncFlag, cfg.Sync.Concurrency, func(s string, a, r int) {
 				// No console output for initial sync progress, TUI will handle it
-			}); err != nil {
+			})
+			stop()
+			if errors.Is(err, context.Canceled) {
+				fmt.Println("Sync cancelled.")
+				d.Close()
+				return
+			} else if err != nil {
 				log.Printf("Sync error: %v", err)
 			}
 			fmt.Println("Done!")
 		}
 	}
 
+	// The model owns the cache from here on and closes it in Close
 	m := tui.NewModel(d, cfg, p, info)
+	if tokenRejected {
+		m.RequireLogin(tui.SessionExpired)
+	}
 	program := tea.NewProgram(&m, tea.WithAltScreen())
 
 	// Pipe background sync to program
-	if cfg.Plex.Token != "" && !(!hasData || forceSyncFlag) && cfg.Sync.AutoSync {
+	if cfg.Plex.Token != "" && !tokenRejected && !(!hasData || forceSyncFlag) && cfg.Sync.AutoSync {
 		go func() {
 			time.Sleep(1 * time.Second) // Give TUI time to start
-			if err := cache.Sync(p, d, false, func(s string, a int) {
-				program.Send(shared.MsgSyncProgress{Status: s, Added: a})
-			}); err != nil {
-				log.Printf("Background sync error: %v", err)
-			}
-			program.Send(shared.MsgSyncProgress{Done: true})
+			// Run through the TUI so the sync can be cancelled from there
+			program.Send(shared.MsgAutoSync{})
 		}()
 	}
 
-	if _, err := program.Run(); err != nil {
+	_, err = program.Run()
+	m.Close()
+	if err != nil {
 		fmt.Printf("Error running TUI: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// checkToken asks plex.tv whether the saved token still works. It reports
+// false only when plex.tv rejects it; being offline is not an error.
+func checkToken(cfg *config.Config, info appinfo.Info) bool {
+	client := auth.NewAuthClient(cfg.Plex.ClientIdentifier, info)
+	client.Client.Timeout = 5 * time.Second
+	_, err := client.GetUser(cfg.Plex.UserToken())
+	return !errors.Is(err, auth.ErrUnauthorized)
+}
+
+// identifyServer records the machine identifier of a server configured
+// before servers were tracked, so it keeps its cache under its own name.
+func identifyServer(cfg *config.Config, info appinfo.Info) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	p := plex.New(cfg.Plex.BaseURL, cfg.Plex.Token, cfg.Plex.ClientIdentifier, info)
+	id, err := p.GetMachineIdentifierContext(ctx)
+	if err != nil || id == "" {
+		return // Offline: keep using the shared cache until next start
+	}
+	if err := db.AdoptLegacyCache(id); err != nil {
+		log.Printf("Warning: failed to move cache for server %s: %v", id, err)
+		return
+	}
+	server, ok := cfg.Plex.Server(id)
+	if !ok {
+		server = config.ServerConfig{MachineIdentifier: id}
+	}
+	server.BaseURL = cfg.Plex.BaseURL
+	cfg.Plex.UseServer(server)
+	if err := config.Save(cfg); err != nil {
+		log.Printf("Warning: failed to save config: %v", err)
+	}
+}
+
+// selectConnection makes the preferred reachable address of the active
+// server its base URL. When none answers, the saved one is kept and the
+// client fails over on its own once the server comes back.
+func selectConnection(cfg *config.Config, info appinfo.Info, uris []string) {
+	p := plex.New(cfg.Plex.BaseURL, cfg.Plex.ServerToken(), cfg.Plex.ClientIdentifier, info)
+	uri, err := p.ProbeConnections(context.Background(), uris, cfg.Plex.ServerID, plex.DefaultProbeTimeout)
+	if err != nil {
+		log.Printf("Warning: server unreachable: %v", err)
+		return
+	}
+	if uri == cfg.Plex.BaseURL {
+		return
+	}
+	server, _ := cfg.Plex.Server(cfg.Plex.ServerID)
+	server.BaseURL = uri
+	cfg.Plex.UseServer(server)
+	if err := config.Save(cfg); err != nil {
+		log.Printf("Warning: failed to save config: %v", err)
+	}
+}
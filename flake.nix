@@ -35,7 +35,7 @@
         version = "0.1.0";
         src = pkgs.lib.cleanSource ./.; # Exclude .git, result, etc.
 
-        vendorHash = "sha256-fuWp7B6gVfoya9lmZOvHgKSlYkhB1tXOLMl4oLQIixk=";
+        vendorHash = "sha256-PUUG5fWHaexYKcu26hH5IeVaJi8NGQYncuPhBpU0Uns=";
 
 
         # Skip tests during build for faster compilation
@@ -44,6 +44,9 @@
         # Ignore vendor directory, let Go manage dependencies
         buildFlags = ["-mod=mod"];
 
+        # Full-text search for the search screen
+        tags = ["sqlite_fts5"];
+
         nativeBuildInputs = [pkgs.makeWrapper];
         
         postInstall = ''
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Waddenn/plex-client/internal/appinfo"
@@ -30,6 +32,45 @@ type PlexResource struct {
 	ClientIdentifier string           `json:"clientIdentifier"`
 	Connections      []PlexConnection `json:"connections"`
 	Owned            bool             `json:"owned"`
+	AccessToken      string           `json:"accessToken"` // Token for this server; differs from the account token on shared servers
+}
+
+// IsServer reports whether the resource is a Plex Media Server.
+func (r PlexResource) IsServer() bool {
+	return strings.Contains(r.Provides, "server") || r.Product == "Plex Media Server"
+}
+
+// BestConnection returns the URI of the preferred connection of the resource,
+// or "" when it advertises none.
+func (r PlexResource) BestConnection() string {
+	conns := r.SortedConnections()
+	if len(conns) == 0 {
+		return ""
+	}
+	return conns[0].Uri
+}
+
+// SortedConnections returns the connections of the resource, the preferred
+// one first.
+func (r PlexResource) SortedConnections() []PlexConnection {
+	conns := append([]PlexConnection(nil), r.Connections...)
+	sort.SliceStable(conns, func(i, j int) bool {
+		return connectionScore(conns[i]) > connectionScore(conns[j])
+	})
+	return conns
+}
+
+func connectionScore(conn PlexConnection) int {
+	if conn.Relay {
+		return 0 // Bandwidth-limited, last resort
+	}
+	if !conn.Local {
+		return 3
+	}
+	if strings.HasPrefix(conn.Address, "172.") {
+		return 1 // Usually a Docker bridge address
+	}
+	return 2
 }
 
 type PlexConnection struct {
@@ -38,6 +79,7 @@ type PlexConnection struct {
 	Port     int    `json:"port"`
 	Uri      string `json:"uri"`
 	Local    bool   `json:"local"`
+	Relay    bool   `json:"relay"`
 }
 
 type AuthClient struct {
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	UserURL       = "https://plex.tv/api/v2/user"
+	HomeUsersURL  = "https://plex.tv/api/v2/home/users"
+	SwitchUserURL = "https://plex.tv/api/v2/home/users/%s/switch"
+)
+
+// ErrUnauthorized is returned by GetUser when plex.tv rejects the token,
+// e.g. after it was revoked.
+var ErrUnauthorized = errors.New("plex.tv token rejected")
+
+// ErrInvalidPIN is returned by SwitchHomeUser when plex.tv rejects the PIN
+// of a protected profile.
+var ErrInvalidPIN = errors.New("invalid PIN")
+
+// HomeUser is a member of the account's Plex Home.
+type HomeUser struct {
+	ID         int    `json:"id"`
+	UUID       string `json:"uuid"`
+	Title      string `json:"title"`
+	Username   string `json:"username"`
+	Thumb      string `json:"thumb"`
+	Admin      bool   `json:"admin"`
+	Guest      bool   `json:"guest"`
+	Restricted bool   `json:"restricted"` // Managed user with content restrictions
+	Protected  bool   `json:"protected"`  // Switching to the user requires its PIN
+}
+
+// PlexUser is the account a token belongs to.
+type PlexUser struct {
+	ID       int    `json:"id"`
+	UUID     string `json:"uuid"`
+	Username string `json:"username"`
+	Title    string `json:"title"`
+	Email    string `json:"email"`
+}
+
+// GetUser returns the account of token. It doubles as a check that the
+// token is still valid.
+func (a *AuthClient) GetUser(token string) (*PlexUser, error) {
+	req, err := http.NewRequest("GET", UserURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	a.setHeaders(req, token)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get user: %s", resp.Status)
+	}
+
+	var user PlexUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetHomeUsers lists the users of the Plex Home the token's account
+// belongs to.
+func (a *AuthClient) GetHomeUsers(token string) ([]HomeUser, error) {
+	req, err := http.NewRequest("GET", HomeUsersURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	a.setHeaders(req, token)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get home users: %s", resp.Status)
+	}
+
+	var home struct {
+		Users []HomeUser `json:"users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&home); err != nil {
+		return nil, err
+	}
+	return home.Users, nil
+}
+
+// SwitchHomeUser signs in as another user of the Plex Home and returns
+// that user's token. pin is required for protected users and ignored
+// otherwise.
+func (a *AuthClient) SwitchHomeUser(token, uuid, pin string) (string, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf(SwitchUserURL, url.PathEscape(uuid)), nil)
+	if err != nil {
+		return "", err
+	}
+	if pin != "" {
+		q := req.URL.Query()
+		q.Add("pin", pin)
+		req.URL.RawQuery = q.Encode()
+	}
+	a.setHeaders(req, token)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", ErrInvalidPIN
+	default:
+		return "", fmt.Errorf("failed to switch user: %s", resp.Status)
+	}
+
+	var user struct {
+		AuthToken string `json:"authToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", err
+	}
+	if user.AuthToken == "" {
+		return "", fmt.Errorf("failed to switch user: no token in response")
+	}
+	return user.AuthToken, nil
+}
+
+func (a *AuthClient) setHeaders(req *http.Request, token string) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", a.UserAgent)
+	req.Header.Set("X-Plex-Token", token)
+	req.Header.Set("X-Plex-Client-Identifier", a.ClientID)
+	req.Header.Set("X-Plex-Product", a.Product)
+	req.Header.Set("X-Plex-Version", a.Version)
+	req.Header.Set("X-Plex-Platform", a.Platform)
+	req.Header.Set("X-Plex-Device", a.Device)
+}
@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
@@ -17,6 +19,7 @@ type mediaInfo struct {
 	VideoCodec      string
 	AudioCodec      string
 	AudioChannels   int
+	Bitrate         int // kbps
 }
 
 // extractMediaInfo extracts media info from the first Media entry.
@@ -30,49 +33,133 @@ func extractMediaInfo(v plex.Video) mediaInfo {
 		VideoCodec:      m.VideoCodec,
 		AudioCodec:      m.AudioCodec,
 		AudioChannels:   m.AudioChannels,
+		Bitrate:         m.Bitrate,
 	}
 }
 
 // Columns returns SQL column names for INSERT statements.
 func (mediaInfo) Columns() string {
-	return "video_resolution, video_codec, audio_codec, audio_channels"
+	return "video_resolution, video_codec, audio_codec, audio_channels, bitrate"
 }
 
 // Placeholders returns SQL placeholders for INSERT statements.
 func (mediaInfo) Placeholders() string {
-	return "?, ?, ?, ?"
+	return "?, ?, ?, ?, ?"
 }
 
 // Values returns the values for SQL INSERT in the same order as Columns().
 func (m mediaInfo) Values() []interface{} {
-	return []interface{}{m.VideoResolution, m.VideoCodec, m.AudioCodec, m.AudioChannels}
+	return []interface{}{m.VideoResolution, m.VideoCodec, m.AudioCodec, m.AudioChannels, m.Bitrate}
 }
 
-// PlexProvider interface allows mocking the Plex client
+// watchState extracts watch progress fields from plex.Video for storage.
+type watchState struct {
+	ViewCount    int
+	ViewOffset   int
+	LastViewedAt int64
+}
+
+func extractWatchState(v plex.Video) watchState {
+	return watchState{ViewCount: v.ViewCount, ViewOffset: v.ViewOffset, LastViewedAt: v.LastViewedAt}
+}
+
+// Columns returns SQL column names for INSERT statements.
+func (watchState) Columns() string {
+	return "view_count, view_offset, last_viewed_at"
+}
+
+// Placeholders returns SQL placeholders for INSERT statements.
+func (watchState) Placeholders() string {
+	return "?, ?, ?"
+}
+
+// Values returns the values for SQL INSERT in the same order as Columns().
+func (w watchState) Values() []interface{} {
+	return []interface{}{w.ViewCount, w.ViewOffset, w.LastViewedAt}
+}
+
+// update refreshes only the watch state of an existing row. Plex does not bump
+// updatedAt when an item is watched, so unchanged rows still need this.
+func (w watchState) update(tx *sql.Tx, table, id string) error {
+	_, err := tx.Exec(`UPDATE `+table+` SET view_count = ?, view_offset = ?, last_viewed_at = ? WHERE id = ?`,
+		w.ViewCount, w.ViewOffset, w.LastViewedAt, id)
+	return err
+}
+
+// PlexProvider interface allows mocking the Plex client.
+// Implementations must return promptly once ctx is done.
 type PlexProvider interface {
-	GetSections() ([]plex.Directory, error)
-	GetSectionAll(key string) ([]plex.Directory, []plex.Video, error) // Updated signature
-	GetSectionDirs(key string) ([]plex.Directory, error)
-	GetChildren(key string) ([]plex.Directory, []plex.Video, error)
+	GetSectionsContext(ctx context.Context) ([]plex.Directory, error)
+	ForEachSectionPage(ctx context.Context, key string, pageSize int, fn func(page *plex.MediaContainer) error) error
+	GetChildrenContext(ctx context.Context, key string) ([]plex.Directory, []plex.Video, error)
+}
+
+// SyncStats counts the items added and removed during a sync.
+type SyncStats struct {
+	Added   int
+	Removed int
+}
+
+func Sync(p PlexProvider, d *sql.DB, force bool, onProgress func(status string, added, removed int)) error {
+	return SyncContext(context.Background(), p, d, force, DefaultConcurrency, onProgress)
+}
+
+// SyncContext is like Sync but stops once ctx is done, returning its error.
+// Every write made so far is committed per show or section, so a cancelled
+// sync leaves the cache consistent and the next sync picks up the rest.
+// concurrency bounds the parallel season and episode requests; values below
+// 1 mean 1.
+func SyncContext(ctx context.Context, p PlexProvider, d *sql.DB, force bool, concurrency int, onProgress func(status string, added, removed int)) error {
+	return syncAll(ctx, p, d, force, concurrency, onProgress, func(section string, err error) {
+		log.Printf("Error syncing section %s: %v", section, err)
+	})
 }
 
-func Sync(p PlexProvider, d *sql.DB, force bool, onProgress func(status string, added int)) error {
-	sections, err := p.GetSections()
+func syncAll(ctx context.Context, p PlexProvider, d *sql.DB, force bool, concurrency int, onProgress func(status string, added, removed int), onSectionError func(section string, err error)) error {
+	sections, err := p.GetSectionsContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	totalAdded := 0
+	var stats SyncStats
+	failed := false
 	for _, s := range sections {
-		if err := SyncSection(p, d, s, force, &totalAdded, onProgress); err != nil {
-			log.Printf("Error syncing section %s: %v", s.Title, err)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := SyncSectionContext(ctx, p, d, s, force, concurrency, &stats, onProgress); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			onSectionError(s.Title, err)
+			failed = true
+		}
+	}
+
+	// Only drop rows of vanished sections once every section synced, so a
+	// transient error never wipes a library.
+	if !failed {
+		removed, err := removeStaleSections(d, sections)
+		if err != nil {
+			log.Printf("Error removing stale sections: %v", err)
+		}
+		if removed > 0 {
+			stats.Removed += removed
+			onProgress("Cleaning up", stats.Added, stats.Removed)
 		}
 	}
 
 	return nil
 }
 
-func SyncSection(p PlexProvider, d *sql.DB, s plex.Directory, force bool, totalAdded *int, onProgress func(status string, added int)) error {
+func SyncSection(p PlexProvider, d *sql.DB, s plex.Directory, force bool, stats *SyncStats, onProgress func(status string, added, removed int)) error {
+	return SyncSectionContext(context.Background(), p, d, s, force, DefaultConcurrency, stats, onProgress)
+}
+
+// SyncSectionContext is like SyncSection but stops once ctx is done and
+// fetches up to concurrency shows at a time. The section's sync marker is
+// only written after a complete pass.
+func SyncSectionContext(ctx context.Context, p PlexProvider, d *sql.DB, s plex.Directory, force bool, concurrency int, stats *SyncStats, onProgress func(status string, added, removed int)) error {
 	// Incremental sync check
 	var lastUpdated int64
 	_ = d.QueryRow("SELECT value FROM metadata WHERE key = ?", "section_"+s.Key).Scan(&lastUpdated)
@@ -84,30 +171,66 @@ func SyncSection(p PlexProvider, d *sql.DB, s plex.Directory, force bool, totalA
 		return nil
 	}
 
+	status := "Updating " + s.Title
+	report := func() { onProgress(status, stats.Added, stats.Removed) }
+
+	// Each page is saved as it arrives; items missing on the server are only
+	// removed once every page was listed.
+	fetched := 0
+	pageProgress := func(page *plex.MediaContainer, n int) {
+		fetched += n
+		if page.TotalSize > 0 {
+			status = fmt.Sprintf("Updating %s %d/%d", s.Title, fetched, page.TotalSize)
+		}
+		report()
+	}
+
 	if s.Type == "movie" {
-		onProgress("Updating "+s.Title, *totalAdded)
-		_, videos, err := p.GetSectionAll(s.Key)
+		report()
+		keep := make(map[string]bool)
+		err := p.ForEachSectionPage(ctx, s.Key, plex.DefaultPageSize, func(page *plex.MediaContainer) error {
+			if err := SaveMovies(d, s.Key, page.Videos, &stats.Added, nil); err != nil {
+				return err
+			}
+			for k := range videoKeys(page.Videos) {
+				keep[k] = true
+			}
+			pageProgress(page, len(page.Videos))
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		if err := SaveMovies(d, videos, totalAdded, func(count int) { onProgress("Updating "+s.Title, count) }); err != nil {
+		removed, err := removeMissing(d, "films", "section_key", s.Key, keep)
+		if err != nil {
 			return err
 		}
+		stats.Removed += removed
+		report()
 	} else if s.Type == "show" {
-		onProgress("Updating "+s.Title, *totalAdded)
-		shows, err := p.GetSectionDirs(s.Key)
+		report()
+		var shows []plex.Directory
+		err := p.ForEachSectionPage(ctx, s.Key, plex.DefaultPageSize, func(page *plex.MediaContainer) error {
+			if err := SaveSeries(d, s.Key, page.Directories, &stats.Added, nil); err != nil {
+				return err
+			}
+			shows = append(shows, page.Directories...)
+			pageProgress(page, len(page.Directories))
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		if err := SaveSeries(d, shows, totalAdded, func(count int) { onProgress("Updating "+s.Title, count) }); err != nil {
+		removed, err := removeMissing(d, "series", "section_key", s.Key, dirKeys(shows))
+		if err != nil {
 			return err
 		}
+		stats.Removed += removed
+		report()
 
 		// Also sync seasons/episodes for these shows
-		for _, show := range shows {
-			if err := SyncShow(p, d, show.RatingKey, totalAdded, func(count int) { onProgress("Updating "+s.Title, count) }); err != nil {
-				log.Printf("Error syncing show %s: %v", show.Title, err)
-			}
+		if err := syncShows(ctx, p, d, shows, concurrency, stats, func(SyncStats) { report() }); err != nil {
+			return err
 		}
 	}
 
@@ -119,7 +242,8 @@ func SyncSection(p PlexProvider, d *sql.DB, s plex.Directory, force bool, totalA
 	return nil
 }
 
-func SaveMovies(d *sql.DB, videos []plex.Video, totalAdded *int, onProgress func(int)) error {
+// SaveMovies stores movies belonging to the library section sectionKey.
+func SaveMovies(d *sql.DB, sectionKey string, videos []plex.Video, totalAdded *int, onProgress func(int)) error {
 	// Use BEGIN IMMEDIATE to avoid deadlocks during concurrent writes
 	tx, err := d.Begin()
 	if err != nil {
@@ -128,15 +252,20 @@ func SaveMovies(d *sql.DB, videos []plex.Video, totalAdded *int, onProgress func
 	defer tx.Rollback()
 
 	var m mediaInfo
-	query := `INSERT OR REPLACE INTO films (id, title, year, part_key, duration, summary, rating, genres, directors, "cast", originallyAvailableAt, content_rating, studio, added_at, updated_at, ` + m.Columns() + `)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + m.Placeholders() + `)`
+	var w watchState
+	query := `INSERT OR REPLACE INTO films (id, title, year, part_key, duration, summary, rating, genres, directors, "cast", originallyAvailableAt, content_rating, studio, added_at, updated_at, section_key, ` + m.Columns() + `, ` + w.Columns() + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + m.Placeholders() + `, ` + w.Placeholders() + `)`
 
 	for _, v := range videos {
 		var existingUpdatedAt int64
-		err := tx.QueryRow("SELECT updated_at FROM films WHERE id = ?", v.RatingKey).Scan(&existingUpdatedAt)
+		var existingSection sql.NullString
+		err := tx.QueryRow("SELECT updated_at, section_key FROM films WHERE id = ?", v.RatingKey).Scan(&existingUpdatedAt, &existingSection)
 
 		// If it exists and hasn't changed, skip
-		if err == nil && v.UpdatedAt > 0 && existingUpdatedAt >= v.UpdatedAt {
+		if err == nil && v.UpdatedAt > 0 && existingUpdatedAt >= v.UpdatedAt && existingSection.String == sectionKey {
+			if err := extractWatchState(v).update(tx, "films", v.RatingKey); err != nil {
+				log.Printf("Error updating watch state for movie %s: %v", v.Title, err)
+			}
 			continue
 		}
 
@@ -166,8 +295,9 @@ func SaveMovies(d *sql.DB, videos []plex.Video, totalAdded *int, onProgress func
 		media := extractMediaInfo(v)
 		args := append([]interface{}{
 			v.RatingKey, v.Title, v.Year, partKey, v.Duration, v.Summary, v.Rating,
-			genres, directors, cast, v.OriginallyAvailableAt, v.ContentRating, v.Studio, v.AddedAt, updatedAt,
+			genres, directors, cast, v.OriginallyAvailableAt, v.ContentRating, v.Studio, v.AddedAt, updatedAt, sectionKey,
 		}, media.Values()...)
+		args = append(args, extractWatchState(v).Values()...)
 
 		_, err = tx.Exec(query, args...)
 		if err != nil {
@@ -177,7 +307,8 @@ func SaveMovies(d *sql.DB, videos []plex.Video, totalAdded *int, onProgress func
 	return tx.Commit()
 }
 
-func SaveSeries(d *sql.DB, shows []plex.Directory, totalAdded *int, onProgress func(int)) error {
+// SaveSeries stores shows belonging to the library section sectionKey.
+func SaveSeries(d *sql.DB, sectionKey string, shows []plex.Directory, totalAdded *int, onProgress func(int)) error {
 	tx, err := d.Begin()
 	if err != nil {
 		return err
@@ -186,9 +317,10 @@ func SaveSeries(d *sql.DB, shows []plex.Directory, totalAdded *int, onProgress f
 
 	for _, show := range shows {
 		var existingUpdatedAt int64
-		err := tx.QueryRow("SELECT updated_at FROM series WHERE id = ?", show.RatingKey).Scan(&existingUpdatedAt)
+		var existingSection sql.NullString
+		err := tx.QueryRow("SELECT updated_at, section_key FROM series WHERE id = ?", show.RatingKey).Scan(&existingUpdatedAt, &existingSection)
 
-		if err == nil && show.UpdatedAt > 0 && existingUpdatedAt >= show.UpdatedAt {
+		if err == nil && show.UpdatedAt > 0 && existingUpdatedAt >= show.UpdatedAt && existingSection.String == sectionKey {
 			continue
 		}
 
@@ -210,9 +342,15 @@ func SaveSeries(d *sql.DB, shows []plex.Directory, totalAdded *int, onProgress f
 			updatedAt = time.Now().Unix()
 		}
 
-		_, err = tx.Exec(`INSERT OR REPLACE INTO series (id, title, summary, rating, genres, directors, "cast", content_rating, studio, added_at, updated_at)
-            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			show.RatingKey, show.Title, show.Summary, show.Rating, genres, directors, cast, show.ContentRating, show.Studio, show.AddedAt, updatedAt)
+		// Upsert rather than REPLACE: a REPLACE deletes the row first, which
+		// would cascade to the show's seasons and episodes.
+		_, err = tx.Exec(`INSERT INTO series (id, title, summary, rating, genres, directors, "cast", content_rating, studio, added_at, updated_at, section_key)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            ON CONFLICT(id) DO UPDATE SET title = excluded.title, summary = excluded.summary, rating = excluded.rating,
+                genres = excluded.genres, directors = excluded.directors, "cast" = excluded."cast",
+                content_rating = excluded.content_rating, studio = excluded.studio, added_at = excluded.added_at,
+                updated_at = excluded.updated_at, section_key = excluded.section_key`,
+			show.RatingKey, show.Title, show.Summary, show.Rating, genres, directors, cast, show.ContentRating, show.Studio, show.AddedAt, updatedAt, sectionKey)
 		if err != nil {
 			log.Printf("Error inserting show %s: %v", show.Title, err)
 		}
@@ -220,6 +358,13 @@ func SaveSeries(d *sql.DB, shows []plex.Directory, totalAdded *int, onProgress f
 	return tx.Commit()
 }
 
+// upsertSeasonQuery inserts or updates a season without deleting it first,
+// so its episodes survive the ON DELETE CASCADE.
+const upsertSeasonQuery = `INSERT INTO seasons (id, series_id, season_index, summary, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET series_id = excluded.series_id, season_index = excluded.season_index,
+		summary = excluded.summary, updated_at = excluded.updated_at`
+
 func SaveSeasons(d *sql.DB, seriesID string, seasons []plex.Directory, added *int, onProgress func(int)) error {
 	tx, err := d.Begin()
 	if err != nil {
@@ -254,9 +399,7 @@ func SaveSeasons(d *sql.DB, seriesID string, seasons []plex.Directory, added *in
 			updatedAt = time.Now().Unix()
 		}
 
-		_, err = tx.Exec(`INSERT OR REPLACE INTO seasons (id, series_id, season_index, summary, updated_at) 
-			VALUES (?, ?, ?, ?, ?)`,
-			season.RatingKey, seriesID, sIndex, season.Summary, updatedAt)
+		_, err = tx.Exec(upsertSeasonQuery, season.RatingKey, seriesID, sIndex, season.Summary, updatedAt)
 		if err != nil {
 			log.Printf("Error inserting season %s: %v", season.Title, err)
 		}
@@ -264,40 +407,46 @@ func SaveSeasons(d *sql.DB, seriesID string, seasons []plex.Directory, added *in
 	return tx.Commit()
 }
 
-func SyncShow(p PlexProvider, d *sql.DB, showID string, added *int, onProgress func(int)) error {
-	seasons, _, err := p.GetChildren(showID)
+// SyncShow fetches the seasons and episodes of a show, storing new ones and
+// removing those no longer present on the server.
+func SyncShow(p PlexProvider, d *sql.DB, showID string, stats *SyncStats, onProgress func(SyncStats)) error {
+	return SyncShowContext(context.Background(), p, d, showID, DefaultConcurrency, stats, onProgress)
+}
+
+// SyncShowContext is like SyncShow but stops once ctx is done. Seasons are
+// fetched in parallel, up to concurrency at a time. The show is written in a
+// single transaction, so a cancelled fetch leaves it untouched.
+func SyncShowContext(ctx context.Context, p PlexProvider, d *sql.DB, showID string, concurrency int, stats *SyncStats, onProgress func(SyncStats)) error {
+	children, err := newChildFetcher(p, concurrency).fetchShow(ctx, showID)
 	if err != nil {
 		return err
 	}
+	return writeShow(d, showID, children, stats, onProgress)
+}
 
+// writeShow stores the fetched seasons and episodes of a show and removes
+// stale ones, in a single transaction.
+func writeShow(d *sql.DB, showID string, c *showChildren, stats *SyncStats, onProgress func(SyncStats)) error {
 	tx, err := d.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	for _, season := range seasons {
-		if season.Type != "season" {
-			continue
+	notify := func(int) {
+		if onProgress != nil {
+			onProgress(*stats)
 		}
+	}
 
+	for i, season := range c.seasons {
 		var existingUpdatedAt int64
 		err := tx.QueryRow("SELECT updated_at FROM seasons WHERE id = ?", season.RatingKey).Scan(&existingUpdatedAt)
 
-		if err == nil && season.UpdatedAt > 0 && existingUpdatedAt >= season.UpdatedAt {
-			// Even if season hasn't changed, we might want to check its episodes if force sync?
-			// But for SyncShow (called during bulk sync or background), let's be conservative.
-			// Actually, if we're here, we usually want to ensure episodes are synced too.
-			// Let's at least process the episodes.
-		}
-
+		// Even if the season hasn't changed, its episodes are still synced below.
 		if err == sql.ErrNoRows {
-			if added != nil {
-				*added++
-				if onProgress != nil {
-					onProgress(*added)
-				}
-			}
+			stats.Added++
+			notify(stats.Added)
 		}
 
 		sIndex, _ := strconv.Atoi(season.Index)
@@ -306,24 +455,37 @@ func SyncShow(p PlexProvider, d *sql.DB, showID string, added *int, onProgress f
 			updatedAt = time.Now().Unix()
 		}
 
-		_, err = tx.Exec(`INSERT OR REPLACE INTO seasons (id, series_id, season_index, summary, updated_at) 
-			VALUES (?, ?, ?, ?, ?)`,
-			season.RatingKey, showID, sIndex, season.Summary, updatedAt)
+		_, err = tx.Exec(upsertSeasonQuery, season.RatingKey, showID, sIndex, season.Summary, updatedAt)
 		if err != nil {
 			log.Printf("Error inserting season %s: %v", season.Title, err)
 			continue
 		}
 
-		_, episodes, err := p.GetChildren(season.RatingKey)
-		if err != nil {
+		if err := c.errs[i]; err != nil {
 			log.Printf("Error fetching episodes for season %s: %v", season.Title, err)
 			continue
 		}
 
-		if err := saveEpisodesInTx(tx, season.RatingKey, episodes, added, onProgress); err != nil {
+		episodes := c.episodes[i]
+		if err := saveEpisodesInTx(tx, season.RatingKey, episodes, &stats.Added, notify); err != nil {
 			log.Printf("Error saving episodes for season %s: %v", season.Title, err)
+			continue
 		}
+
+		removed, err := removeMissingInTx(tx, "episodes", "season_id", season.RatingKey, videoKeys(episodes))
+		if err != nil {
+			log.Printf("Error removing stale episodes for season %s: %v", season.Title, err)
+		}
+		stats.Removed += removed
+	}
+
+	removed, err := removeMissingInTx(tx, "seasons", "series_id", showID, dirKeys(c.seasons))
+	if err != nil {
+		return err
 	}
+	stats.Removed += removed
+	notify(stats.Added)
+
 	return tx.Commit()
 }
 
@@ -343,14 +505,18 @@ func SaveEpisodes(d *sql.DB, seasonID string, episodes []plex.Video, added *int,
 
 func saveEpisodesInTx(tx *sql.Tx, seasonID string, episodes []plex.Video, added *int, onProgress func(int)) error {
 	var m mediaInfo
-	query := `INSERT OR REPLACE INTO episodes (id, season_id, episode_index, title, part_key, duration, summary, rating, updated_at, ` + m.Columns() + `)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ` + m.Placeholders() + `)`
+	var w watchState
+	query := `INSERT OR REPLACE INTO episodes (id, season_id, episode_index, title, part_key, duration, summary, rating, updated_at, ` + m.Columns() + `, ` + w.Columns() + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ` + m.Placeholders() + `, ` + w.Placeholders() + `)`
 
 	for _, e := range episodes {
 		var existingUpdatedAt int64
 		err := tx.QueryRow("SELECT updated_at FROM episodes WHERE id = ?", e.RatingKey).Scan(&existingUpdatedAt)
 
 		if err == nil && e.UpdatedAt > 0 && existingUpdatedAt >= e.UpdatedAt {
+			if err := extractWatchState(e).update(tx, "episodes", e.RatingKey); err != nil {
+				log.Printf("Error updating watch state for episode %s: %v", e.Title, err)
+			}
 			continue
 		}
 
@@ -377,6 +543,7 @@ func saveEpisodesInTx(tx *sql.Tx, seasonID string, episodes []plex.Video, added
 		args := append([]interface{}{
 			e.RatingKey, seasonID, e.Index, e.Title, partKey, e.Duration, e.Summary, e.Rating, updatedAt,
 		}, media.Values()...)
+		args = append(args, extractWatchState(e).Values()...)
 
 		_, err = tx.Exec(query, args...)
 		if err != nil {
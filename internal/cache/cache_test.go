@@ -1,10 +1,19 @@
 package cache
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/Waddenn/plex-client/internal/appinfo"
 	"github.com/Waddenn/plex-client/internal/plex"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -18,21 +27,47 @@ type MockPlexClient struct {
 		Dirs []plex.Directory
 		Vids []plex.Video
 	}
-}
+	Errors map[string]error // Section key -> error returned when listing it
 
-func (m *MockPlexClient) GetSections() ([]plex.Directory, error) {
-	return m.Sections, nil
+	// OnChildren, if set, is called before children are returned.
+	OnChildren func(key string)
 }
 
-func (m *MockPlexClient) GetSectionAll(key string) ([]plex.Directory, []plex.Video, error) {
-	return nil, m.Videos[key], nil
+func (m *MockPlexClient) GetSectionsContext(ctx context.Context) ([]plex.Directory, error) {
+	return m.Sections, nil
 }
 
-func (m *MockPlexClient) GetSectionDirs(key string) ([]plex.Directory, error) {
-	return m.Shows[key], nil
+func (m *MockPlexClient) ForEachSectionPage(ctx context.Context, key string, pageSize int, fn func(page *plex.MediaContainer) error) error {
+	if err := m.Errors[key]; err != nil {
+		return err
+	}
+	videos, shows := m.Videos[key], m.Shows[key]
+	total := len(videos) + len(shows)
+	for start := 0; ; start += pageSize {
+		page := &plex.MediaContainer{Offset: start, TotalSize: total}
+		end := min(start+pageSize, total)
+		if start < len(videos) {
+			page.Videos = videos[start:min(end, len(videos))]
+		}
+		if end > len(videos) {
+			page.Directories = shows[max(start-len(videos), 0) : end-len(videos)]
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if end == total {
+			return nil
+		}
+	}
 }
 
-func (m *MockPlexClient) GetChildren(key string) ([]plex.Directory, []plex.Video, error) {
+func (m *MockPlexClient) GetChildrenContext(ctx context.Context, key string) ([]plex.Directory, []plex.Video, error) {
+	if m.OnChildren != nil {
+		m.OnChildren(key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	c, ok := m.Children[key]
 	if !ok {
 		return nil, nil, nil
@@ -60,7 +95,8 @@ func initTestDB(t *testing.T) *sql.DB {
             content_rating TEXT,
             studio TEXT,
             added_at INTEGER,
-            updated_at INTEGER
+            updated_at INTEGER,
+            section_key TEXT
         );`,
 		`CREATE TABLE IF NOT EXISTS seasons (
             id INTEGER PRIMARY KEY,
@@ -84,6 +120,10 @@ func initTestDB(t *testing.T) *sql.DB {
             video_codec TEXT,
             audio_codec TEXT,
             audio_channels INTEGER,
+            bitrate INTEGER,
+            view_count INTEGER DEFAULT 0,
+            view_offset INTEGER DEFAULT 0,
+            last_viewed_at INTEGER DEFAULT 0,
             FOREIGN KEY(season_id) REFERENCES seasons(id) ON DELETE CASCADE
         );`,
 		`CREATE TABLE IF NOT EXISTS films (
@@ -105,7 +145,12 @@ func initTestDB(t *testing.T) *sql.DB {
             video_resolution TEXT,
             video_codec TEXT,
             audio_codec TEXT,
-            audio_channels INTEGER
+            audio_channels INTEGER,
+            bitrate INTEGER,
+            section_key TEXT,
+            view_count INTEGER DEFAULT 0,
+            view_offset INTEGER DEFAULT 0,
+            last_viewed_at INTEGER DEFAULT 0
         );`,
 		`CREATE TABLE IF NOT EXISTS sections (
 			key TEXT PRIMARY KEY,
@@ -113,6 +158,17 @@ func initTestDB(t *testing.T) *sql.DB {
 			type TEXT,
 			updated_at INTEGER
 		);`,
+		`CREATE TABLE IF NOT EXISTS outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			rating_key TEXT NOT NULL,
+			time_ms INTEGER DEFAULT 0,
+			duration_ms INTEGER DEFAULT 0,
+			state TEXT DEFAULT '',
+			rating REAL DEFAULT 0,
+			created_at INTEGER,
+			reported_at INTEGER DEFAULT 0
+		);`,
 		`CREATE TABLE IF NOT EXISTS metadata (
 			key TEXT PRIMARY KEY,
 			value TEXT
@@ -156,7 +212,7 @@ func TestSyncShows(t *testing.T) {
 		},
 	}
 
-	if err := Sync(mock, db, true, func(s string, a int) {}); err != nil {
+	if err := Sync(mock, db, true, func(s string, a, r int) {}); err != nil {
 		t.Fatalf("Sync failed: %v", err)
 	}
 
@@ -170,6 +226,14 @@ func TestSyncShows(t *testing.T) {
 		t.Errorf("Expected show 'Test Show', got '%s'", title)
 	}
 
+	var sectionKey string
+	if err := db.QueryRow("SELECT section_key FROM series WHERE id=100").Scan(&sectionKey); err != nil {
+		t.Fatalf("Failed to read section_key: %v", err)
+	}
+	if sectionKey != "1" {
+		t.Errorf("Expected show section_key '1', got '%s'", sectionKey)
+	}
+
 	// Verify Season
 	var summary string
 	err = db.QueryRow("SELECT summary FROM seasons WHERE id=101").Scan(&summary)
@@ -190,6 +254,253 @@ func TestSyncShows(t *testing.T) {
 		t.Errorf("Expected episode title 'Pilot', got '%s'", epTitle)
 	}
 }
+func TestSyncRemovesStaleItems(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+	// Foreign keys are per connection; pin one so the cascade applies.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("PRAGMA foreign_keys=ON"); err != nil {
+		t.Fatalf("Failed to enable foreign keys: %v", err)
+	}
+
+	type children = struct {
+		Dirs []plex.Directory
+		Vids []plex.Video
+	}
+	mock := &MockPlexClient{
+		Sections: []plex.Directory{
+			{Key: "1", Title: "Movies", Type: "movie"},
+			{Key: "2", Title: "TV Shows", Type: "show"},
+		},
+		Videos: map[string][]plex.Video{
+			"1": {
+				{RatingKey: "1", Title: "Kept Movie", UpdatedAt: 100},
+				{RatingKey: "2", Title: "Deleted Movie", UpdatedAt: 100},
+			},
+		},
+		Shows: map[string][]plex.Directory{
+			"2": {
+				{RatingKey: "100", Title: "Kept Show", UpdatedAt: 100},
+				{RatingKey: "200", Title: "Deleted Show", UpdatedAt: 100},
+			},
+		},
+		Children: map[string]children{
+			"100": {Dirs: []plex.Directory{{RatingKey: "101", Type: "season", Index: "1", UpdatedAt: 100}}},
+			"101": {Vids: []plex.Video{
+				{RatingKey: "102", Title: "Kept Episode", UpdatedAt: 100},
+				{RatingKey: "103", Title: "Deleted Episode", UpdatedAt: 100},
+			}},
+			"200": {Dirs: []plex.Directory{{RatingKey: "201", Type: "season", Index: "1", UpdatedAt: 100}}},
+			"201": {Vids: []plex.Video{{RatingKey: "202", Title: "Orphan Episode", UpdatedAt: 100}}},
+		},
+	}
+
+	if err := Sync(mock, db, true, func(s string, a, r int) {}); err != nil {
+		t.Fatalf("Initial sync failed: %v", err)
+	}
+
+	// Remove items on the "server"
+	mock.Videos["1"] = mock.Videos["1"][:1]
+	mock.Shows["2"] = mock.Shows["2"][:1]
+	mock.Children["101"] = children{Vids: mock.Children["101"].Vids[:1]}
+
+	var added, removed int
+	if err := Sync(mock, db, true, func(s string, a, r int) { added, removed = a, r }); err != nil {
+		t.Fatalf("Second sync failed: %v", err)
+	}
+
+	if added != 0 {
+		t.Errorf("Expected 0 items added on resync, got %d", added)
+	}
+	// Movie 2, show 200 (cascading to 201/202) and episode 103
+	if removed != 3 {
+		t.Errorf("Expected 3 items removed, got %d", removed)
+	}
+
+	counts := map[string]int{
+		"SELECT count(*) FROM films":                   1,
+		"SELECT count(*) FROM series":                  1,
+		"SELECT count(*) FROM seasons":                 1,
+		"SELECT count(*) FROM episodes":                1,
+		"SELECT count(*) FROM episodes WHERE id = 102": 1,
+	}
+	for q, want := range counts {
+		var got int
+		if err := db.QueryRow(q).Scan(&got); err != nil {
+			t.Fatalf("%s failed: %v", q, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %d, got %d", q, want, got)
+		}
+	}
+}
+
+func TestSyncStream(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	mock := &MockPlexClient{
+		Sections: []plex.Directory{
+			{Key: "1", Title: "Movies", Type: "movie"},
+			{Key: "2", Title: "Broken", Type: "movie"},
+		},
+		Videos: map[string][]plex.Video{
+			"1": {
+				{RatingKey: "1", Title: "Movie 1", UpdatedAt: 100},
+				{RatingKey: "2", Title: "Movie 2", UpdatedAt: 100},
+			},
+		},
+		Errors: map[string]error{"2": errors.New("server unreachable")},
+	}
+
+	var events []SyncEvent
+	for ev := range SyncStream(context.Background(), mock, db, true, DefaultConcurrency) {
+		events = append(events, ev)
+	}
+
+	if len(events) == 0 {
+		t.Fatalf("Expected sync events, got none")
+	}
+	last := events[len(events)-1]
+	if !last.Done || last.Err != nil {
+		t.Errorf("Expected a successful Done event last, got %+v", last)
+	}
+	if last.Added != 2 {
+		t.Errorf("Expected 2 added in Done event, got %d", last.Added)
+	}
+
+	var sectionErrors []string
+	sawProgress := false
+	for _, ev := range events[:len(events)-1] {
+		if ev.Err != nil {
+			sectionErrors = append(sectionErrors, ev.Section)
+		} else if ev.Status == "Updating Movies" {
+			sawProgress = true
+		}
+	}
+	if !sawProgress {
+		t.Errorf("Expected an 'Updating Movies' progress event")
+	}
+	if len(sectionErrors) != 1 || sectionErrors[0] != "Broken" {
+		t.Errorf("Expected one error for section 'Broken', got %v", sectionErrors)
+	}
+}
+
+func TestSyncCancel(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatalf("Failed to enable foreign keys: %v", err)
+	}
+
+	mock := &MockPlexClient{
+		Sections: []plex.Directory{{Key: "2", Title: "Shows", Type: "show", UpdatedAt: 500}},
+		Shows: map[string][]plex.Directory{
+			"2": {
+				{RatingKey: "100", Title: "Show A", Type: "show", UpdatedAt: 100},
+				{RatingKey: "200", Title: "Show B", Type: "show", UpdatedAt: 100},
+			},
+		},
+		Children: map[string]struct {
+			Dirs []plex.Directory
+			Vids []plex.Video
+		}{
+			"100": {Dirs: []plex.Directory{{RatingKey: "101", Type: "season", Index: "1", UpdatedAt: 100}}},
+			"101": {Vids: []plex.Video{{RatingKey: "1011", Title: "A1", UpdatedAt: 100}}},
+			"200": {Dirs: []plex.Directory{{RatingKey: "201", Type: "season", Index: "1", UpdatedAt: 100}}},
+			"201": {Vids: []plex.Video{{RatingKey: "2011", Title: "B1", UpdatedAt: 100}}},
+		},
+	}
+
+	// Cancel while Show B's episodes are being fetched
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mock.OnChildren = func(key string) {
+		if key == "201" {
+			cancel()
+		}
+	}
+
+	// One worker, so Show A is fully written before Show B is fetched
+	err := SyncContext(ctx, mock, db, false, 1, func(s string, a, r int) {})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("Query %q failed: %v", query, err)
+		}
+		return n
+	}
+
+	// Show A completed; Show B's transaction was rolled back as a whole.
+	if n := count("SELECT count(*) FROM episodes WHERE season_id = '101'"); n != 1 {
+		t.Errorf("Expected Show A's episode to be kept, got %d", n)
+	}
+	if n := count("SELECT count(*) FROM seasons WHERE series_id = '200'"); n != 0 {
+		t.Errorf("Expected Show B's season to be rolled back, got %d", n)
+	}
+	if n := count("SELECT count(*) FROM metadata WHERE key = 'section_2'"); n != 0 {
+		t.Errorf("Expected no sync marker for a cancelled section, got %d", n)
+	}
+
+	// The next sync resumes and completes.
+	mock.OnChildren = nil
+	if err := Sync(mock, db, false, func(s string, a, r int) {}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if n := count("SELECT count(*) FROM episodes"); n != 2 {
+		t.Errorf("Expected 2 episodes after resync, got %d", n)
+	}
+	if n := count("SELECT count(*) FROM metadata WHERE key = 'section_2'"); n != 1 {
+		t.Errorf("Expected sync marker after resync, got %d", n)
+	}
+}
+
+func TestSyncPaginated(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	total := plex.DefaultPageSize*2 + 50
+	var videos []plex.Video
+	for i := 1; i <= total; i++ {
+		videos = append(videos, plex.Video{RatingKey: strconv.Itoa(i), Title: "Movie " + strconv.Itoa(i), UpdatedAt: 100})
+	}
+	mock := &MockPlexClient{
+		Sections: []plex.Directory{{Key: "1", Title: "Movies", Type: "movie"}},
+		Videos:   map[string][]plex.Video{"1": videos},
+	}
+
+	var statuses []string
+	err := Sync(mock, db, true, func(s string, a, r int) {
+		if len(statuses) == 0 || statuses[len(statuses)-1] != s {
+			statuses = append(statuses, s)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	var count int
+	db.QueryRow("SELECT count(*) FROM films").Scan(&count)
+	if count != total {
+		t.Errorf("Expected %d movies, got %d", total, count)
+	}
+
+	expected := []string{
+		"Updating Movies",
+		fmt.Sprintf("Updating Movies %d/%d", plex.DefaultPageSize, total),
+		fmt.Sprintf("Updating Movies %d/%d", plex.DefaultPageSize*2, total),
+		fmt.Sprintf("Updating Movies %d/%d", total, total),
+	}
+	if strings.Join(statuses, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected per-page progress %v, got %v", expected, statuses)
+	}
+}
+
 func TestSaveMovies(t *testing.T) {
 	db := initTestDB(t)
 	defer db.Close()
@@ -200,7 +511,7 @@ func TestSaveMovies(t *testing.T) {
 	}
 
 	totalAdded := 0
-	if err := SaveMovies(db, movies, &totalAdded, nil); err != nil {
+	if err := SaveMovies(db, "1", movies, &totalAdded, nil); err != nil {
 		t.Fatalf("SaveMovies failed: %v", err)
 	}
 
@@ -225,12 +536,12 @@ func TestIncrementalSync(t *testing.T) {
 
 	// First save
 	added := 0
-	SaveMovies(db, movies, &added, nil)
+	SaveMovies(db, "1", movies, &added, nil)
 
 	// Second save with SAME UpdatedAt but DIFFERENT Title
 	movies[0].Title = "Updated Title"
 	added = 0
-	if err := SaveMovies(db, movies, &added, nil); err != nil {
+	if err := SaveMovies(db, "1", movies, &added, nil); err != nil {
 		t.Fatalf("Second SaveMovies failed: %v", err)
 	}
 
@@ -247,7 +558,7 @@ func TestIncrementalSync(t *testing.T) {
 	// Third save with NEWER UpdatedAt
 	movies[0].UpdatedAt = 300
 	added = 0
-	if err := SaveMovies(db, movies, &added, nil); err != nil {
+	if err := SaveMovies(db, "1", movies, &added, nil); err != nil {
 		t.Fatalf("Third SaveMovies failed: %v", err)
 	}
 
@@ -256,6 +567,260 @@ func TestIncrementalSync(t *testing.T) {
 		t.Errorf("Expected title 'Updated Title' after UpdatedAt changed, got '%s'", title)
 	}
 }
+func TestWatchState(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	movies := []plex.Video{
+		{RatingKey: "1", Title: "Watched", UpdatedAt: 200, ViewCount: 1, LastViewedAt: 150},
+		{RatingKey: "2", Title: "Unwatched", UpdatedAt: 200},
+	}
+	if err := SaveMovies(db, "1", movies, nil, nil); err != nil {
+		t.Fatalf("SaveMovies failed: %v", err)
+	}
+
+	var viewCount int
+	db.QueryRow("SELECT view_count FROM films WHERE id=1").Scan(&viewCount)
+	if viewCount != 1 {
+		t.Errorf("Expected view_count 1, got %d", viewCount)
+	}
+
+	// Watch state changes without an updatedAt bump must still be stored
+	movies[1].ViewOffset = 60000
+	if err := SaveMovies(db, "1", movies, nil, nil); err != nil {
+		t.Fatalf("Second SaveMovies failed: %v", err)
+	}
+	var viewOffset int
+	db.QueryRow("SELECT view_offset FROM films WHERE id=2").Scan(&viewOffset)
+	if viewOffset != 60000 {
+		t.Errorf("Expected view_offset 60000, got %d", viewOffset)
+	}
+
+	// Local playback updates
+	if err := UpdateViewOffset(db, "1", 90000); err != nil {
+		t.Fatalf("UpdateViewOffset failed: %v", err)
+	}
+	db.QueryRow("SELECT view_offset FROM films WHERE id=1").Scan(&viewOffset)
+	if viewOffset != 90000 {
+		t.Errorf("Expected view_offset 90000, got %d", viewOffset)
+	}
+
+	if err := MarkWatched(db, "2"); err != nil {
+		t.Fatalf("MarkWatched failed: %v", err)
+	}
+	db.QueryRow("SELECT view_count, view_offset FROM films WHERE id=2").Scan(&viewCount, &viewOffset)
+	if viewCount != 1 || viewOffset != 0 {
+		t.Errorf("Expected view_count 1 and view_offset 0 after MarkWatched, got %d and %d", viewCount, viewOffset)
+	}
+}
+
+func TestWatchChange(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	if err := SaveSeasons(db, "100", []plex.Directory{
+		{RatingKey: "101", Type: "season", Index: "1"},
+		{RatingKey: "201", Type: "season", Index: "2"},
+	}, nil, nil); err != nil {
+		t.Fatalf("SaveSeasons failed: %v", err)
+	}
+	if err := SaveEpisodes(db, "101", []plex.Video{
+		{RatingKey: "102", Index: 1, ViewCount: 2, LastViewedAt: 150},
+		{RatingKey: "103", Index: 2, ViewOffset: 60000},
+	}, nil, nil); err != nil {
+		t.Fatalf("SaveEpisodes failed: %v", err)
+	}
+	if err := SaveEpisodes(db, "201", []plex.Video{{RatingKey: "202", Index: 1}}, nil, nil); err != nil {
+		t.Fatalf("SaveEpisodes failed: %v", err)
+	}
+	state := func(id string) (viewCount, viewOffset int, lastViewedAt int64) {
+		t.Helper()
+		if err := db.QueryRow("SELECT view_count, view_offset, last_viewed_at FROM episodes WHERE id = ?", id).Scan(&viewCount, &viewOffset, &lastViewedAt); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// Marking the show watched covers every season
+	if err := SetWatched(db, "100", true); err != nil {
+		t.Fatalf("SetWatched failed: %v", err)
+	}
+	if n, _, last := state("102"); n != 2 || last != 150 {
+		t.Errorf("Expected episode 102 to keep its 2 views from 150, got %d from %d", n, last)
+	}
+	for _, id := range []string{"103", "202"} {
+		if n, offset, _ := state(id); n != 1 || offset != 0 {
+			t.Errorf("Expected episode %s watched, got view_count %d and view_offset %d", id, n, offset)
+		}
+	}
+
+	var requests []string
+	down := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case down:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Query().Get("key") == "404":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			requests = append(requests, r.URL.Path+" "+r.URL.Query().Get("key"))
+		}
+	}))
+	defer srv.Close()
+	r := &WatchStateReporter{
+		Plex:   plex.New(srv.URL, "token", "client-id", appinfo.Default()),
+		DB:     db,
+		Outbox: NewOutbox(db),
+	}
+
+	if queued, err := r.Change(context.Background(), WatchChange{Kind: ChangeUnwatched, RatingKey: "101"}); err != nil || queued {
+		t.Fatalf("Change failed: %v (queued %v)", err, queued)
+	}
+	if n, _, _ := state("103"); n != 0 {
+		t.Errorf("Expected episode 103 unwatched, got view_count %d", n)
+	}
+	if n, _, _ := state("202"); n != 1 {
+		t.Errorf("Expected episode 202 of another season to stay watched, got view_count %d", n)
+	}
+
+	// Offline: the cache changes at once and Plex gets the latest change
+	// of each item once it answers again
+	down = true
+	for _, c := range []WatchChange{
+		{Kind: ChangeWatched, RatingKey: "103"},
+		{Kind: ChangeProgress, RatingKey: "202", OffsetMs: 30000},
+		{Kind: ChangeUnwatched, RatingKey: "103"},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		queued, err := r.Change(ctx, c)
+		cancel()
+		if err == nil || !queued {
+			t.Fatalf("Expected %s to be queued, got %v (queued %v)", c, err, queued)
+		}
+	}
+	if _, offset, _ := state("202"); offset != 30000 {
+		t.Errorf("Expected view_offset 30000 while offline, got %d", offset)
+	}
+	if n, _ := r.Outbox.Len(); n != 2 {
+		t.Errorf("Expected 2 queued changes, got %d", n)
+	}
+
+	down = false
+	if sent, err := r.Outbox.Flush(context.Background(), r.Plex); err != nil || sent != 2 {
+		t.Fatalf("Flush failed: %v (%d sent)", err, sent)
+	}
+	want := "[/:/unscrobble 101 /:/progress 202 /:/unscrobble 103]"
+	if got := fmt.Sprint(requests); got != want {
+		t.Errorf("Expected requests %s, got %s", want, got)
+	}
+
+	if queued, err := r.Change(context.Background(), WatchChange{Kind: ChangeWatched, RatingKey: "404"}); err == nil || queued {
+		t.Errorf("Expected a rejected change not to be queued, got %v (queued %v)", err, queued)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	var requests []string
+	down := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case down:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case q.Get("key") == "404":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/:/timeline":
+			requests = append(requests, fmt.Sprintf("timeline %s %s %s", q.Get("ratingKey"), q.Get("time"), q.Get("state")))
+		default:
+			requests = append(requests, r.URL.Path+" "+q.Get("key")+q.Get("rating"))
+		}
+	}))
+	defer srv.Close()
+	p := plex.New(srv.URL, "token", "client-id", appinfo.Default())
+
+	// Progress reported while offline is kept, one position per item
+	o := NewOutbox(db)
+	for _, a := range []Action{
+		{Kind: ActionTimeline, RatingKey: "1", TimeMs: 10000, DurationMs: 60000, State: "playing"},
+		{Kind: ActionTimeline, RatingKey: "2", TimeMs: 5000, DurationMs: 60000, State: "playing"},
+		{Kind: ActionTimeline, RatingKey: "1", TimeMs: 20000, DurationMs: 60000, State: "paused"},
+		{Kind: ActionRate, RatingKey: "404", Rating: 8},
+		{Kind: ActionScrobble, RatingKey: "2"},
+		{Kind: ActionRate, RatingKey: "3", Rating: 6},
+		{Kind: ActionRate, RatingKey: "3", Rating: 10},
+	} {
+		if err := o.Add(a); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if n, _ := o.Len(); n != 5 {
+		t.Errorf("Expected 5 queued actions, got %d", n)
+	}
+
+	r := &Replayer{Outbox: o, Plex: p, MinDelay: 5 * time.Second, MaxDelay: 20 * time.Second}
+	for _, want := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 20 * time.Second} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		next, err := r.Replay(ctx)
+		cancel()
+		if err == nil || next != want {
+			t.Errorf("Expected to wait %v after a failure, got %v (%v)", want, next, err)
+		}
+	}
+
+	// Back online, in order; the action Plex refuses is dropped
+	down = false
+	if next, err := r.Replay(context.Background()); err != nil || next != 0 {
+		t.Fatalf("Expected the outbox flushed, got %v (%v)", next, err)
+	}
+	want := "[timeline 2 5000 stopped timeline 1 20000 stopped /:/scrobble 2 /:/rate 310]"
+	if got := fmt.Sprint(requests); got != want {
+		t.Errorf("Expected requests %s, got %s", want, got)
+	}
+	if n, _ := o.Len(); n != 0 {
+		t.Errorf("Expected an empty outbox, got %d actions", n)
+	}
+}
+
+func TestOutbox_KeepsLatestReport(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+	o := NewOutbox(db)
+
+	// Reports held up by a slow server reach the outbox out of order
+	start := time.Now().Add(-time.Minute)
+	for _, a := range []Action{
+		{Kind: ActionTimeline, RatingKey: "1", TimeMs: 30000, DurationMs: 60000, State: "stopped", At: start.Add(2 * time.Second)},
+		{Kind: ActionTimeline, RatingKey: "1", TimeMs: 10000, DurationMs: 60000, State: "playing", At: start},
+		{Kind: ActionTimeline, RatingKey: "1", TimeMs: 20000, DurationMs: 60000, State: "paused", At: start.Add(time.Second)},
+	} {
+		if err := o.Add(a); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	var n int
+	var timeMs int64
+	var state string
+	if err := db.QueryRow("SELECT count(*), time_ms, state FROM outbox").Scan(&n, &timeMs, &state); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || timeMs != 30000 || state != "stopped" {
+		t.Errorf("Expected the stopped report at 30000 only, got %d queued, latest %d %s", n, timeMs, state)
+	}
+
+	// A later change replaces it
+	if err := o.Add(Action{Kind: ActionTimeline, RatingKey: "1", TimeMs: 40000, DurationMs: 60000, State: "playing"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	db.QueryRow("SELECT time_ms FROM outbox").Scan(&timeMs)
+	if timeMs != 40000 {
+		t.Errorf("Expected the newer report at 40000, got %d", timeMs)
+	}
+}
+
 func TestSaveSeasonsAndEpisodes(t *testing.T) {
 	db := initTestDB(t)
 	defer db.Close()
@@ -305,7 +870,7 @@ func TestConcurrency(t *testing.T) {
 			movies := []plex.Video{
 				{RatingKey: strconv.Itoa(id), Title: "Concurrent Movie " + strconv.Itoa(id), UpdatedAt: 100},
 			}
-			errChan <- SaveMovies(db, movies, nil, nil)
+			errChan <- SaveMovies(db, "1", movies, nil, nil)
 		}(i)
 	}
 
@@ -314,4 +879,84 @@ func TestConcurrency(t *testing.T) {
 			t.Errorf("Concurrent worker failed: %v", err)
 		}
 	}
+
+	// Sync a TV section through the worker pool and check that every
+	// season and episode is written while the request limit holds.
+	const (
+		shows             = 20
+		seasonsPerShow    = 3
+		episodesPerSeason = 5
+		limit             = 4
+	)
+	mock := &MockPlexClient{
+		Sections: []plex.Directory{{Key: "2", Title: "Shows", Type: "show"}},
+		Shows:    map[string][]plex.Directory{},
+		Children: map[string]struct {
+			Dirs []plex.Directory
+			Vids []plex.Video
+		}{},
+	}
+	for s := 0; s < shows; s++ {
+		showID := strconv.Itoa((s + 1) * 10000)
+		mock.Shows["2"] = append(mock.Shows["2"], plex.Directory{RatingKey: showID, Title: showID, Type: "show", UpdatedAt: 100})
+
+		var seasons []plex.Directory
+		for n := 0; n < seasonsPerShow; n++ {
+			seasonID := strconv.Itoa((s+1)*10000 + (n+1)*100)
+			seasons = append(seasons, plex.Directory{RatingKey: seasonID, Type: "season", Index: strconv.Itoa(n), UpdatedAt: 100})
+
+			var episodes []plex.Video
+			for e := 0; e < episodesPerSeason; e++ {
+				episodes = append(episodes, plex.Video{RatingKey: strconv.Itoa((s+1)*10000 + (n+1)*100 + e + 1), Index: e, UpdatedAt: 100})
+			}
+			mock.Children[seasonID] = struct {
+				Dirs []plex.Directory
+				Vids []plex.Video
+			}{Vids: episodes}
+		}
+		mock.Children[showID] = struct {
+			Dirs []plex.Directory
+			Vids []plex.Video
+		}{Dirs: seasons}
+	}
+
+	var inFlight, maxInFlight int32
+	mock.OnChildren = func(key string) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- SyncContext(context.Background(), mock, db, true, limit, func(s string, a, r int) {})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Sync deadlocked")
+	}
+
+	if m := atomic.LoadInt32(&maxInFlight); m > limit {
+		t.Errorf("Expected at most %d concurrent requests, got %d", limit, m)
+	}
+
+	var count int
+	db.QueryRow("SELECT count(*) FROM seasons").Scan(&count)
+	if count != shows*seasonsPerShow {
+		t.Errorf("Expected %d seasons, got %d", shows*seasonsPerShow, count)
+	}
+	db.QueryRow("SELECT count(*) FROM episodes").Scan(&count)
+	if count != shows*seasonsPerShow*episodesPerSeason {
+		t.Errorf("Expected %d episodes, got %d", shows*seasonsPerShow*episodesPerSeason, count)
+	}
 }
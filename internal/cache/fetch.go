@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+// DefaultConcurrency is the default limit of parallel GetChildren requests.
+const DefaultConcurrency = 4
+
+// childFetcher bounds the number of concurrent GetChildren calls.
+type childFetcher struct {
+	p   PlexProvider
+	sem chan struct{}
+}
+
+func newChildFetcher(p PlexProvider, limit int) *childFetcher {
+	if limit < 1 {
+		limit = 1
+	}
+	return &childFetcher{p: p, sem: make(chan struct{}, limit)}
+}
+
+// get calls GetChildren once a slot is free. The slot is only held for the
+// request itself, so nested fetches cannot deadlock the pool.
+func (f *childFetcher) get(ctx context.Context, key string) ([]plex.Directory, []plex.Video, error) {
+	select {
+	case f.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	defer func() { <-f.sem }()
+	return f.p.GetChildrenContext(ctx, key)
+}
+
+// showChildren holds the seasons of a show and, at the same index, the
+// episodes of each season or the error fetching them.
+type showChildren struct {
+	seasons  []plex.Directory
+	episodes [][]plex.Video
+	errs     []error
+}
+
+// fetchShow fetches the seasons of a show, then their episodes in parallel.
+func (f *childFetcher) fetchShow(ctx context.Context, showID string) (*showChildren, error) {
+	children, _, err := f.get(ctx, showID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &showChildren{}
+	for _, season := range children {
+		if season.Type == "season" {
+			c.seasons = append(c.seasons, season)
+		}
+	}
+	c.episodes = make([][]plex.Video, len(c.seasons))
+	c.errs = make([]error, len(c.seasons))
+
+	var wg sync.WaitGroup
+	for i, season := range c.seasons {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			_, c.episodes[i], c.errs[i] = f.get(ctx, key)
+		}(i, season.RatingKey)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// syncShows fetches the seasons and episodes of shows with a pool of
+// concurrency workers. Writes stay on the calling goroutine, one show per
+// transaction, so SQLite only ever sees a single writer.
+func syncShows(ctx context.Context, p PlexProvider, d *sql.DB, shows []plex.Directory, concurrency int, stats *SyncStats, onProgress func(SyncStats)) error {
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	f := newChildFetcher(p, concurrency)
+	workers := cap(f.sem)
+
+	type result struct {
+		show     plex.Directory
+		children *showChildren
+		err      error
+	}
+	jobs := make(chan plex.Directory)
+	results := make(chan result)
+
+	go func() {
+		defer close(jobs)
+		for _, show := range shows {
+			select {
+			case jobs <- show:
+			case <-workCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for show := range jobs {
+				children, err := f.fetchShow(workCtx, show.RatingKey)
+				select {
+				case results <- result{show: show, children: children, err: err}:
+				case <-workCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// A show fetched in full is still written after a cancel; at most one
+	// per worker can be pending.
+	for r := range results {
+		err := r.err
+		if err == nil {
+			err = writeShow(d, r.show.RatingKey, r.children, stats, onProgress)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Error syncing show %s: %v", r.show.Title, err)
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+// Kinds of Action, named after the Plex endpoint they call.
+const (
+	ActionTimeline   = "timeline"
+	ActionScrobble   = "scrobble"
+	ActionUnscrobble = "unscrobble"
+	ActionProgress   = "progress"
+	ActionRate       = "rate"
+)
+
+// Action is a call to Plex that records watch state or a rating. Those
+// that cannot reach the server wait in the outbox table of the cache.
+type Action struct {
+	ID         int64 // Set once in the outbox
+	Kind       string
+	RatingKey  string
+	TimeMs     int64   // Position, for timeline and progress
+	DurationMs int64   // For timeline
+	State      string  // For timeline: playing, paused or stopped
+	Rating     float64 // For rate
+
+	// When it happened, now when zero. A queued action is not replaced by
+	// an older one, which a report held up by a slow server can be.
+	At time.Time
+}
+
+func (a Action) String() string {
+	switch a.Kind {
+	case ActionScrobble:
+		return fmt.Sprintf("mark %s watched", a.RatingKey)
+	case ActionUnscrobble:
+		return fmt.Sprintf("mark %s unwatched", a.RatingKey)
+	case ActionRate:
+		return fmt.Sprintf("rate %s %g", a.RatingKey, a.Rating)
+	}
+	return fmt.Sprintf("set progress of %s to %dms", a.RatingKey, a.TimeMs)
+}
+
+func (a Action) send(ctx context.Context, p *plex.Client) error {
+	switch a.Kind {
+	case ActionTimeline:
+		return p.ReportProgressContext(ctx, a.RatingKey, a.TimeMs, a.DurationMs, a.State)
+	case ActionScrobble:
+		return p.ScrobbleContext(ctx, a.RatingKey)
+	case ActionUnscrobble:
+		return p.UnscrobbleContext(ctx, a.RatingKey)
+	case ActionProgress:
+		return p.SetProgressContext(ctx, a.RatingKey, a.TimeMs)
+	case ActionRate:
+		return p.RateContext(ctx, a.RatingKey, a.Rating)
+	}
+	return fmt.Errorf("unknown action %q", a.Kind)
+}
+
+// supersedes lists the kinds of queued actions an action of a kind makes
+// pointless for the same item. Only the latest position is worth sending.
+var supersedes = map[string][]string{
+	ActionTimeline:   {ActionTimeline},
+	ActionScrobble:   {ActionScrobble, ActionUnscrobble, ActionProgress},
+	ActionUnscrobble: {ActionScrobble, ActionUnscrobble, ActionProgress},
+	ActionProgress:   {ActionScrobble, ActionUnscrobble, ActionProgress},
+	ActionRate:       {ActionRate},
+}
+
+// retryable reports whether an action that failed with err may succeed
+// later: the server could not be reached or had an internal error, or the
+// attempt was cancelled. A rejected token is retried too, once the user
+// signs in again.
+func retryable(err error) bool {
+	var statusErr *plex.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// Outbox holds the actions Plex could not be reached for in the cache, so
+// they survive restarts until Flush sends them. It is safe for concurrent
+// use; share one per cache so actions are sent once and in order.
+type Outbox struct {
+	DB *sql.DB
+	mu sync.Mutex // Held while flushing
+}
+
+func NewOutbox(d *sql.DB) *Outbox {
+	return &Outbox{DB: d}
+}
+
+// Add queues a, dropping the queued actions of the same item it supersedes.
+// It is dropped itself when one of those happened after it.
+func (o *Outbox) Add(a Action) error {
+	now := time.Now()
+	if a.At.IsZero() {
+		a.At = now
+	}
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, kind := range supersedes[a.Kind] {
+		var newer int
+		if err := tx.QueryRow(`SELECT count(*) FROM outbox WHERE kind = ? AND rating_key = ? AND reported_at > ?`,
+			kind, a.RatingKey, a.At.UnixNano()).Scan(&newer); err != nil {
+			return err
+		}
+		if newer > 0 {
+			return nil
+		}
+	}
+	for _, kind := range supersedes[a.Kind] {
+		if _, err := tx.Exec(`DELETE FROM outbox WHERE kind = ? AND rating_key = ?`, kind, a.RatingKey); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO outbox (kind, rating_key, time_ms, duration_ms, state, rating, created_at, reported_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Kind, a.RatingKey, a.TimeMs, a.DurationMs, a.State, a.Rating, now.Unix(), a.At.UnixNano()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Len returns the number of queued actions.
+func (o *Outbox) Len() (int, error) {
+	var n int
+	err := o.DB.QueryRow(`SELECT count(*) FROM outbox`).Scan(&n)
+	return n, err
+}
+
+// Flush sends the queued actions in order and returns how many it sent. It
+// stops at the first one that fails for a retryable reason, which stays
+// queued with its error returned; others that fail are dropped.
+func (o *Outbox) Flush(ctx context.Context, p *plex.Client) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sent := 0
+	for {
+		var a Action
+		err := o.DB.QueryRow(`SELECT id, kind, rating_key, time_ms, duration_ms, state, rating FROM outbox ORDER BY id LIMIT 1`).Scan(
+			&a.ID, &a.Kind, &a.RatingKey, &a.TimeMs, &a.DurationMs, &a.State, &a.Rating)
+		if err == sql.ErrNoRows {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+		if a.Kind == ActionTimeline {
+			// That playback is over by now: record the position
+			// without reviving its session
+			a.State = "stopped"
+		}
+
+		if err := a.send(ctx, p); err != nil && retryable(err) {
+			return sent, err
+		} else if err != nil {
+			log.Printf("Dropping queued action (%s): %v", a, err)
+		} else {
+			sent++
+		}
+		if _, err := o.DB.Exec(`DELETE FROM outbox WHERE id = ?`, a.ID); err != nil {
+			return sent, err
+		}
+	}
+}
+
+// Replayer flushes an outbox, waiting longer between attempts while the
+// server stays unreachable.
+type Replayer struct {
+	Outbox   *Outbox
+	Plex     *plex.Client
+	MinDelay time.Duration // Wait after the first failure
+	MaxDelay time.Duration // Longest wait between attempts
+
+	mu       sync.Mutex // Replay runs in a command, Delay in Update
+	failures int
+}
+
+// Replay flushes the outbox and returns how long to wait before the next
+// attempt; 0 when nothing is left to send.
+func (r *Replayer) Replay(ctx context.Context) (next time.Duration, err error) {
+	_, err = r.Outbox.Flush(ctx, r.Plex)
+	r.mu.Lock()
+	if err != nil {
+		r.failures++
+	} else {
+		r.failures = 0
+	}
+	r.mu.Unlock()
+	if n, lenErr := r.Outbox.Len(); lenErr != nil || n == 0 {
+		return 0, err
+	}
+	return r.Delay(), err
+}
+
+// Delay returns the wait before the next attempt: MinDelay doubled for
+// each failure since the last success, up to MaxDelay.
+func (r *Replayer) Delay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.MinDelay
+	for i := 1; i < r.failures && d < r.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+	return d
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"database/sql"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+// removeMissing deletes rows of table whose scopeColumn equals scope and whose
+// id is not in keep. Children go away through ON DELETE CASCADE.
+func removeMissing(d *sql.DB, table, scopeColumn, scope string, keep map[string]bool) (int, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	removed, err := removeMissingInTx(tx, table, scopeColumn, scope, keep)
+	if err != nil {
+		return 0, err
+	}
+	return removed, tx.Commit()
+}
+
+func removeMissingInTx(tx *sql.Tx, table, scopeColumn, scope string, keep map[string]bool) (int, error) {
+	rows, err := tx.Query(`SELECT id FROM `+table+` WHERE `+scopeColumn+` = ?`, scope)
+	if err != nil {
+		return 0, err
+	}
+	var stale []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !keep[id] {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range stale {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
+}
+
+// removeStaleSections drops sections that no longer exist on the server along
+// with their movies and shows, and any rows never attributed to a section.
+func removeStaleSections(d *sql.DB, sections []plex.Directory) (int, error) {
+	keep := make(map[string]bool, len(sections))
+	for _, s := range sections {
+		keep[s.Key] = true
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var stale []string
+	rows, err := tx.Query(`SELECT key FROM sections`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !keep[key] {
+			stale = append(stale, key)
+		}
+	}
+	rows.Close()
+
+	removed := 0
+	for _, key := range stale {
+		if _, err := tx.Exec(`DELETE FROM sections WHERE key = ?`, key); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`DELETE FROM metadata WHERE key = ?`, "section_"+key); err != nil {
+			return 0, err
+		}
+		for _, table := range []string{"films", "series"} {
+			res, err := tx.Exec(`DELETE FROM `+table+` WHERE section_key = ?`, key)
+			if err != nil {
+				return 0, err
+			}
+			n, _ := res.RowsAffected()
+			removed += int(n)
+		}
+	}
+
+	for _, table := range []string{"films", "series"} {
+		res, err := tx.Exec(`DELETE FROM ` + table + ` WHERE section_key IS NULL`)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+
+	return removed, tx.Commit()
+}
+
+func videoKeys(videos []plex.Video) map[string]bool {
+	keys := make(map[string]bool, len(videos))
+	for _, v := range videos {
+		keys[v.RatingKey] = true
+	}
+	return keys
+}
+
+func dirKeys(dirs []plex.Directory) map[string]bool {
+	keys := make(map[string]bool, len(dirs))
+	for _, d := range dirs {
+		keys[d.RatingKey] = true
+	}
+	return keys
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SyncEvent is one update of a sync started with SyncStream.
+type SyncEvent struct {
+	Status  string
+	Added   int
+	Removed int
+
+	// Section and Err are set when a single section failed; the sync goes on.
+	Section string
+	Err     error
+
+	// Done marks the last event. Err is then set if the sync could not run at
+	// all, or to the context's error if it was cancelled.
+	Done bool
+}
+
+// SyncStream runs SyncContext in the background and streams its progress.
+// Progress events may be dropped when the reader falls behind, since a later
+// one supersedes them; section errors and the final Done event never are.
+// The channel is closed after the Done event.
+func SyncStream(ctx context.Context, p PlexProvider, d *sql.DB, force bool, concurrency int) <-chan SyncEvent {
+	events := make(chan SyncEvent, 64)
+
+	go func() {
+		defer close(events)
+
+		var last SyncEvent
+		err := syncAll(ctx, p, d, force, concurrency,
+			func(status string, added, removed int) {
+				last = SyncEvent{Status: status, Added: added, Removed: removed}
+				select {
+				case events <- last:
+				default:
+				}
+			},
+			func(section string, err error) {
+				events <- SyncEvent{Section: section, Err: err}
+			},
+		)
+		events <- SyncEvent{Added: last.Added, Removed: last.Removed, Done: true, Err: err}
+	}()
+
+	return events
+}
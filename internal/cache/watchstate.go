@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+// UpdateViewOffset stores the playback position of a movie or episode.
+func UpdateViewOffset(d *sql.DB, ratingKey string, offsetMs int64) error {
+	now := time.Now().Unix()
+	for _, table := range []string{"films", "episodes"} {
+		if _, err := d.Exec(`UPDATE `+table+` SET view_offset = ?, last_viewed_at = ? WHERE id = ?`, offsetMs, now, ratingKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkWatched records a completed view of a movie or episode.
+func MarkWatched(d *sql.DB, ratingKey string) error {
+	now := time.Now().Unix()
+	for _, table := range []string{"films", "episodes"} {
+		if _, err := d.Exec(`UPDATE `+table+` SET view_count = view_count + 1, view_offset = 0, last_viewed_at = ? WHERE id = ?`, now, ratingKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetWatched marks a movie or episode, or every episode of the season or
+// show ratingKey names, as watched or unwatched. Both clear the resume
+// position.
+func SetWatched(d *sql.DB, ratingKey string, watched bool) error {
+	set := `view_count = 0, view_offset = 0`
+	args := []interface{}{}
+	if watched {
+		// Keep the view count and date of items that were already watched
+		set = `view_count = MAX(IFNULL(view_count, 0), 1), view_offset = 0,
+			last_viewed_at = CASE WHEN view_count > 0 THEN last_viewed_at ELSE ? END`
+		args = append(args, time.Now().Unix())
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE films SET `+set+` WHERE id = ?`, append(args, ratingKey)...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE episodes SET `+set+` WHERE id = ? OR season_id = ?
+		OR season_id IN (SELECT id FROM seasons WHERE series_id = ?)`,
+		append(args, ratingKey, ratingKey, ratingKey)...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ChangeKind is what a WatchChange does.
+type ChangeKind int
+
+const (
+	ChangeWatched   ChangeKind = iota // Mark watched
+	ChangeUnwatched                   // Mark unwatched
+	ChangeProgress                    // Set the resume position to OffsetMs
+)
+
+// WatchChange is a change of watch state made by hand rather than by
+// playback, such as marking a whole show watched from the browser.
+type WatchChange struct {
+	Kind      ChangeKind
+	RatingKey string // A movie or episode; a season or show too unless Kind is ChangeProgress
+	OffsetMs  int64
+}
+
+func (c WatchChange) String() string {
+	switch c.Kind {
+	case ChangeWatched:
+		return fmt.Sprintf("mark %s watched", c.RatingKey)
+	case ChangeUnwatched:
+		return fmt.Sprintf("mark %s unwatched", c.RatingKey)
+	}
+	return fmt.Sprintf("set progress of %s to %dms", c.RatingKey, c.OffsetMs)
+}
+
+func (c WatchChange) save(d *sql.DB) error {
+	switch c.Kind {
+	case ChangeWatched, ChangeUnwatched:
+		return SetWatched(d, c.RatingKey, c.Kind == ChangeWatched)
+	}
+	return UpdateViewOffset(d, c.RatingKey, c.OffsetMs)
+}
+
+func (c WatchChange) action() Action {
+	switch c.Kind {
+	case ChangeWatched:
+		return Action{Kind: ActionScrobble, RatingKey: c.RatingKey}
+	case ChangeUnwatched:
+		return Action{Kind: ActionUnscrobble, RatingKey: c.RatingKey}
+	}
+	return Action{Kind: ActionProgress, RatingKey: c.RatingKey, TimeMs: c.OffsetMs}
+}
+
+// WatchStateReporter forwards playback progress to Plex and mirrors it into
+// the local cache right away, so status badges are correct without a sync.
+// With an Outbox, what cannot reach Plex is queued there instead of lost.
+type WatchStateReporter struct {
+	Plex   *plex.Client
+	DB     *sql.DB
+	Outbox *Outbox // May be nil
+}
+
+func (r *WatchStateReporter) ReportProgress(key string, timeMs int64, durationMs int64, state string) error {
+	// Stamped before waiting for the outbox, which may take long
+	a := Action{Kind: ActionTimeline, RatingKey: key, TimeMs: timeMs, DurationMs: durationMs, State: state, At: time.Now()}
+	if err := UpdateViewOffset(r.DB, key, timeMs); err != nil {
+		log.Printf("Error caching progress for %s: %v", key, err)
+	}
+	_, err := r.send(context.Background(), a)
+	return err
+}
+
+func (r *WatchStateReporter) Scrobble(key string) error {
+	a := Action{Kind: ActionScrobble, RatingKey: key, At: time.Now()}
+	if err := MarkWatched(r.DB, key); err != nil {
+		log.Printf("Error caching watched state for %s: %v", key, err)
+	}
+	_, err := r.send(context.Background(), a)
+	return err
+}
+
+// Rate sets the user rating of an item, from 0 to 10.
+func (r *WatchStateReporter) Rate(ctx context.Context, key string, rating float64) (queued bool, err error) {
+	return r.send(ctx, Action{Kind: ActionRate, RatingKey: key, Rating: rating})
+}
+
+// Change applies c to the cache, then sends it to Plex.
+func (r *WatchStateReporter) Change(ctx context.Context, c WatchChange) (queued bool, err error) {
+	if err := c.save(r.DB); err != nil {
+		return false, fmt.Errorf("caching watch state: %w", err)
+	}
+	return r.send(ctx, c.action())
+}
+
+// send sends a to Plex, after the actions queued before it so they cannot
+// undo it. When Plex cannot be reached, a is queued in the outbox and
+// queued is true; err is the failure either way.
+func (r *WatchStateReporter) send(ctx context.Context, a Action) (queued bool, err error) {
+	if r.Outbox == nil {
+		return false, a.send(ctx, r.Plex)
+	}
+	if n, err := r.Outbox.Len(); err == nil && n > 0 {
+		if _, err := r.Outbox.Flush(ctx, r.Plex); err != nil {
+			return r.queue(a, err)
+		}
+	}
+	if err := a.send(ctx, r.Plex); err != nil {
+		if retryable(err) {
+			return r.queue(a, err)
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+func (r *WatchStateReporter) queue(a Action, err error) (bool, error) {
+	if dbErr := r.Outbox.Add(a); dbErr != nil {
+		log.Printf("Error queueing %s: %v", a, dbErr)
+		return false, err
+	}
+	return true, err
+}
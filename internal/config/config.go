@@ -1,26 +1,183 @@
 package config
 
 import (
+	"bytes"
 	"crypto/rand"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	Plex   PlexConfig   `toml:"plex"`
-	Player PlayerConfig `toml:"player"`
-	UI     UIConfig     `toml:"ui"`
-	Sync   SyncConfig   `toml:"sync"`
+	Plex      PlexConfig      `toml:"plex"`
+	Player    PlayerConfig    `toml:"player"`
+	UI        UIConfig        `toml:"ui"`
+	Sync      SyncConfig      `toml:"sync"`
+	Downloads DownloadsConfig `toml:"downloads"`
+	Secrets   SecretsConfig   `toml:"secrets"`
+
+	// secrets holds the tokens; opened by Load, or by the first Save
+	secrets *storedSecrets
 }
 
 type PlexConfig struct {
-	BaseURL          string `toml:"baseurl"`
-	Token            string `toml:"token"`
-	ClientIdentifier string `toml:"client_identifier"`
+	BaseURL          string         `toml:"baseurl"`         // URL of the active server
+	Token            string         `toml:"token,omitempty"` // Kept in the secret store, see SecretsConfig
+	ClientIdentifier string         `toml:"client_identifier"`
+	ServerID         string         `toml:"server_id"` // Machine identifier of the active server
+	Servers          []ServerConfig `toml:"servers"`
+	UserID           string         `toml:"user_id"` // UUID of the active Plex Home user; empty for the account itself
+	Users            []UserConfig   `toml:"users"`
+}
+
+// UserConfig is a Plex Home user the client has switched to.
+type UserConfig struct {
+	UUID  string `toml:"uuid"`
+	Title string `toml:"title"`
+	Token string `toml:"token,omitempty"` // plex.tv token of the user; kept in the secret store
+	// ServerTokens are the user's access tokens, by server machine identifier
+	ServerTokens map[string]string `toml:"server_tokens,omitempty"`
+}
+
+// ServerConfig is a Plex Media Server reachable with the account.
+type ServerConfig struct {
+	Name              string `toml:"name"`
+	MachineIdentifier string `toml:"machine_identifier"`
+	BaseURL           string `toml:"baseurl"`
+	AccessToken       string `toml:"access_token,omitempty"` // Server token, required for servers shared by others; kept in the secret store
+	Owned             bool   `toml:"owned"`
+	// Connections are every address the server advertises, preferred first
+	Connections []ConnectionConfig `toml:"connections,omitempty"`
+}
+
+// ConnectionConfig is one address a server can be reached at.
+type ConnectionConfig struct {
+	URI   string `toml:"uri"`
+	Local bool   `toml:"local"`
+	Relay bool   `toml:"relay"`
+}
+
+// Server returns the saved server with the given machine identifier.
+func (p *PlexConfig) Server(id string) (ServerConfig, bool) {
+	for _, s := range p.Servers {
+		if s.MachineIdentifier == id {
+			return s, true
+		}
+	}
+	return ServerConfig{}, false
+}
+
+// UseServer makes s the active server, adding it to Servers or updating
+// its saved entry. While a home user is active, s.AccessToken is the
+// user's and is kept with the user instead.
+func (p *PlexConfig) UseServer(s ServerConfig) {
+	p.ServerID = s.MachineIdentifier
+	p.BaseURL = s.BaseURL
+	if u := p.user(p.UserID); u != nil {
+		if s.AccessToken != "" {
+			if u.ServerTokens == nil {
+				u.ServerTokens = map[string]string{}
+			}
+			u.ServerTokens[s.MachineIdentifier] = s.AccessToken
+		}
+		s.AccessToken = ""
+		if saved, ok := p.Server(s.MachineIdentifier); ok {
+			s.AccessToken = saved.AccessToken
+		}
+	}
+	for i := range p.Servers {
+		if p.Servers[i].MachineIdentifier == s.MachineIdentifier {
+			p.Servers[i] = s
+			return
+		}
+	}
+	p.Servers = append(p.Servers, s)
+}
+
+// User returns the saved home user with the given UUID.
+func (p *PlexConfig) User(uuid string) (UserConfig, bool) {
+	if u := p.user(uuid); u != nil {
+		return *u, true
+	}
+	return UserConfig{}, false
+}
+
+func (p *PlexConfig) user(uuid string) *UserConfig {
+	if uuid == "" {
+		return nil
+	}
+	for i := range p.Users {
+		if p.Users[i].UUID == uuid {
+			return &p.Users[i]
+		}
+	}
+	return nil
+}
+
+// UseUser makes u the active home user, adding it to Users or updating its
+// saved entry. An empty UUID switches back to the account itself.
+func (p *PlexConfig) UseUser(u UserConfig) {
+	p.UserID = u.UUID
+	if u.UUID == "" {
+		return
+	}
+	if saved := p.user(u.UUID); saved != nil {
+		*saved = u
+		return
+	}
+	p.Users = append(p.Users, u)
+}
+
+// UserToken returns the plex.tv token of the active user: the home user's
+// when one is active, else the account token.
+func (p *PlexConfig) UserToken() string {
+	if u, ok := p.User(p.UserID); ok && u.Token != "" {
+		return u.Token
+	}
+	return p.Token
+}
+
+// CacheID names the cache of the active server and user. Home users keep
+// their own cache as libraries and watch state differ between them.
+func (p *PlexConfig) CacheID() string {
+	if p.UserID == "" {
+		return p.ServerID
+	}
+	return p.ServerID + "_" + p.UserID
+}
+
+// ConnectionURIs returns the addresses of the active server, preferred
+// first, or nil when only its base URL is known.
+func (p *PlexConfig) ConnectionURIs() []string {
+	s, ok := p.Server(p.ServerID)
+	if !ok {
+		return nil
+	}
+	var uris []string
+	for _, c := range s.Connections {
+		uris = append(uris, c.URI)
+	}
+	return uris
+}
+
+// ServerToken returns the token to send to the active server: its own
+// access token when known, else the account token. A home user uses their
+// own tokens.
+func (p *PlexConfig) ServerToken() string {
+	if u, ok := p.User(p.UserID); ok {
+		if t := u.ServerTokens[p.ServerID]; t != "" {
+			return t
+		}
+		return u.Token
+	}
+	if s, ok := p.Server(p.ServerID); ok && s.AccessToken != "" {
+		return s.AccessToken
+	}
+	return p.Token
 }
 
 type PlayerConfig struct {
@@ -33,6 +190,24 @@ type PlayerConfig struct {
 	SubtitlesEnabled bool     `toml:"subtitles_enabled"`
 	SubtitlesLang    string   `toml:"subtitles_lang"`
 	AudioLang        string   `toml:"audio_lang"`
+	// ResumeDefault is the choice focused when asked whether to resume a
+	// partially watched item: "resume" or "start_over"
+	ResumeDefault string `toml:"resume_default"`
+	// ResumeThresholdSec is the progress, in seconds, below which an item
+	// starts over without asking
+	ResumeThresholdSec int `toml:"resume_threshold_seconds"`
+}
+
+// Choices of player.resume_default.
+const (
+	ResumeFromOffset = "resume"
+	ResumeStartOver  = "start_over"
+)
+
+// Resumable reports whether an item watched up to offsetMs should offer to
+// resume, i.e. whether the progress reaches resume_threshold_seconds.
+func (p PlayerConfig) Resumable(offsetMs int64) bool {
+	return offsetMs > 0 && offsetMs >= int64(p.ResumeThresholdSec)*1000
 }
 
 type UIConfig struct {
@@ -46,6 +221,33 @@ type SyncConfig struct {
 	AutoSync                  bool `toml:"auto_sync"`
 	ForceSyncOnStart          bool `toml:"force_sync_on_start"`
 	BackgroundSyncIntervalMin int  `toml:"background_sync_interval_minutes"`
+	Concurrency               int  `toml:"concurrency"` // Parallel season/episode requests
+}
+
+type DownloadsConfig struct {
+	// Dir receives the files downloaded for offline playback; empty for
+	// the downloads directory under the XDG data directory
+	Dir string `toml:"dir"`
+}
+
+// Path returns the directory downloads are saved to, see Dir. A leading ~
+// stands for the home directory.
+func (d DownloadsConfig) Path() (string, error) {
+	if d.Dir == "" {
+		dir, err := DataDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "downloads"), nil
+	}
+	if d.Dir == "~" || strings.HasPrefix(d.Dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, d.Dir[1:]), nil
+	}
+	return d.Dir, nil
 }
 
 // Defaults returns a config with sensible defaults
@@ -56,11 +258,13 @@ func Defaults() *Config {
 			Token:   "",
 		},
 		Player: PlayerConfig{
-			Quality:          "auto",
-			MPVArgs:          []string{},
-			SubtitlesEnabled: true,
-			SubtitlesLang:    "eng",
-			AudioLang:        "eng",
+			Quality:            "auto",
+			MPVArgs:            []string{},
+			SubtitlesEnabled:   true,
+			SubtitlesLang:      "eng",
+			AudioLang:          "eng",
+			ResumeDefault:      ResumeFromOffset,
+			ResumeThresholdSec: 60,
 		},
 		UI: UIConfig{
 			ShowPreview:          true,
@@ -72,6 +276,10 @@ func Defaults() *Config {
 			AutoSync:                  true,
 			ForceSyncOnStart:          false,
 			BackgroundSyncIntervalMin: 0,
+			Concurrency:               4,
+		},
+		Secrets: SecretsConfig{
+			Backend: "auto",
 		},
 	}
 }
@@ -100,9 +308,37 @@ func CacheDir() (string, error) {
 	return dir, nil
 }
 
-// Load loads config from TOML, merging with defaults
+// DataDir returns the directory of files kept across cache clears:
+// $XDG_DATA_HOME/plex-client, or ~/.local/share/plex-client.
+func DataDir() (string, error) {
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(dataDir, "plex-client")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Load loads config from TOML, merging with defaults, and reads the tokens
+// from the secret store. Tokens still in the TOML are moved to the store.
 func Load() (*Config, error) {
+	return load(nil)
+}
+
+// load is Load with the tokens in store, or in the backend chosen by the
+// [secrets] section when nil.
+func load(store SecretStore) (*Config, error) {
 	cfg := Defaults() // Start with defaults
+	if store != nil {
+		cfg.secrets = newStoredSecrets(store)
+	}
 
 	dir, err := ConfigDir()
 	if err != nil {
@@ -119,14 +355,38 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	if cfg.Plex.ClientIdentifier == "" {
-		cfg.Plex.ClientIdentifier = generateClientID()
-		_ = Save(cfg) // Best effort save
+	secrets, err := cfg.secretStore()
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := loadSecrets(cfg, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Plex.ClientIdentifier == "" || migrated {
+		if cfg.Plex.ClientIdentifier == "" {
+			cfg.Plex.ClientIdentifier = generateClientID()
+		}
+		_ = Save(cfg) // Best effort save; plaintext tokens stay until it succeeds
 	}
 
 	return cfg, nil
 }
 
+// secretStore returns the store of the tokens of c, opening the configured
+// backend on first use.
+func (c *Config) secretStore() (*storedSecrets, error) {
+	if c.secrets == nil {
+		store, err := OpenSecretStore(c.Secrets)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: %w", err)
+		}
+		c.secrets = newStoredSecrets(store)
+	}
+	return c.secrets, nil
+}
+
 func generateClientID() string {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -136,20 +396,49 @@ func generateClientID() string {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
 
-// Save saves config to TOML
+// Save saves the tokens to the secret store and the rest of the config to
+// TOML, readable by the user only.
 func Save(cfg *Config) error {
 	dir, err := ConfigDir()
 	if err != nil {
 		return err
 	}
 
-	path := filepath.Join(dir, "config.toml")
+	secrets, err := cfg.secretStore()
+	if err != nil {
+		return err
+	}
+	public, err := saveSecrets(cfg, secrets)
+	if err != nil {
+		return err
+	}
 
-	f, err := os.Create(path)
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(public); err != nil {
+		return err
+	}
+	return writePrivate(filepath.Join(dir, "config.toml"), buf.Bytes())
+}
+
+// writePrivate replaces the file at path with data, with mode 0600. The
+// file is written aside and renamed so a crash never leaves it truncated.
+func writePrivate(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer os.Remove(f.Name()) // No-op once renamed
 
-	return toml.NewEncoder(f).Encode(cfg)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
 }
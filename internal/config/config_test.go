@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempConfig points the config dir at a temporary directory and returns
+// a fresh in-memory store for the secrets.
+func useTempConfig(t *testing.T) (string, *MemoryStore) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	store := NewMemoryStore()
+	return filepath.Join(home, "plex-client", "config.toml"), store
+}
+
+func TestSave_KeepsTokensInSecretStore(t *testing.T) {
+	path, store := useTempConfig(t)
+
+	cfg := Defaults()
+	cfg.secrets = newStoredSecrets(store)
+	cfg.Plex.ClientIdentifier = "client-id"
+	cfg.Plex.Token = "account-token"
+	cfg.Plex.UseServer(ServerConfig{Name: "Home", MachineIdentifier: "home-id", BaseURL: "http://home:32400", AccessToken: "home-token"})
+	cfg.Plex.Users = []UserConfig{{UUID: "kid", Title: "Kid", Token: "kid-token", ServerTokens: map[string]string{"home-id": "kid-home-token"}}}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "-token") {
+		t.Errorf("Expected no tokens in config.toml, got:\n%s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected config.toml with mode 0600, got %v (%v)", info.Mode().Perm(), err)
+	}
+	want := []string{"servers/home-id/token", "token", "users/kid/servers/home-id/token", "users/kid/token"}
+	if got := store.Keys(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected secrets %v, got %v", want, got)
+	}
+	if cfg.Plex.Token != "account-token" {
+		t.Error("Expected Save to leave the tokens of cfg in place")
+	}
+
+	loaded, err := load(store)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Plex.Token != "account-token" || loaded.Plex.Servers[0].AccessToken != "home-token" {
+		t.Errorf("Expected the tokens back, got %+v", loaded.Plex)
+	}
+	if u := loaded.Plex.Users[0]; u.Token != "kid-token" || u.ServerTokens["home-id"] != "kid-home-token" {
+		t.Errorf("Expected the user's tokens back, got %+v", u)
+	}
+}
+
+func TestLoad_MigratesPlaintextToken(t *testing.T) {
+	path, store := useTempConfig(t)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := "[plex]\nbaseurl = \"http://home:32400\"\ntoken = \"plaintext\"\nclient_identifier = \"client-id\"\n"
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := load(store)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Plex.Token != "plaintext" {
+		t.Errorf("Expected the token to load, got %q", cfg.Plex.Token)
+	}
+	if v, err := store.Get("token"); err != nil || v != "plaintext" {
+		t.Errorf("Expected the token in the secret store, got %q (%v)", v, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "plaintext") {
+		t.Errorf("Expected the token removed from config.toml, got:\n%s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected config.toml with mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(PassphraseEnv, "correct horse")
+
+	s, err := newFileStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("token"); err != ErrSecretNotFound {
+		t.Fatalf("Expected ErrSecretNotFound, got %v", err)
+	}
+	if err := s.Set("token", "secret-token"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Error("Expected the file to be encrypted")
+	}
+
+	reopened, _ := newFileStore()
+	if v, err := reopened.Get("token"); err != nil || v != "secret-token" {
+		t.Errorf("Expected the secret back, got %q (%v)", v, err)
+	}
+
+	// A file that cannot be decrypted reads as empty, so that the user can
+	// log in again, and is kept aside once replaced
+	t.Setenv(PassphraseEnv, "wrong")
+	wrong, _ := newFileStore()
+	if _, err := wrong.Get("token"); err != ErrSecretNotFound {
+		t.Errorf("Expected ErrSecretNotFound with a wrong passphrase, got %v", err)
+	}
+	if err := wrong.Set("token", "new-token"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, err := wrong.Get("token"); err != nil || v != "new-token" {
+		t.Errorf("Expected the new secret, got %q (%v)", v, err)
+	}
+	if old, err := os.ReadFile(s.path + ".old"); err != nil || string(old) != string(data) {
+		t.Errorf("Expected the old file kept aside, got %v", err)
+	}
+}
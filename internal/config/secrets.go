@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrSecretNotFound is returned by SecretStore.Get for a missing key.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore keeps the tokens out of config.toml. Keys look like paths,
+// e.g. "token" or "servers/<machine id>/token".
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+	// Name describes the backend for messages
+	Name() string
+}
+
+type SecretsConfig struct {
+	// Backend is "auto" (the system keyring, else the encrypted file),
+	// "keyring", "pass", "command" or "file"
+	Backend string `toml:"backend"`
+	// Commands of the "command" backend; "{key}" is replaced by the key.
+	// get prints the secret, set reads it on stdin.
+	GetCommand    []string `toml:"get_command,omitempty"`
+	SetCommand    []string `toml:"set_command,omitempty"`
+	DeleteCommand []string `toml:"delete_command,omitempty"`
+}
+
+// OpenSecretStore returns the backend configured by s.
+func OpenSecretStore(s SecretsConfig) (SecretStore, error) {
+	switch s.Backend {
+	case "", "auto":
+		if k := newKeyringStore(); k.available() {
+			return k, nil
+		}
+		return newFileStore()
+	case "keyring":
+		return newKeyringStore(), nil
+	case "pass":
+		return passStore(), nil
+	case "command":
+		if len(s.GetCommand) == 0 || len(s.SetCommand) == 0 {
+			return nil, fmt.Errorf("secrets backend %q needs get_command and set_command", s.Backend)
+		}
+		return &commandStore{name: "command", get: s.GetCommand, set: s.SetCommand, del: s.DeleteCommand}, nil
+	case "file":
+		return newFileStore()
+	}
+	return nil, fmt.Errorf("unknown secrets backend %q", s.Backend)
+}
+
+// MemoryStore is a SecretStore that keeps secrets in memory, for tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{secrets: map[string]string{}}
+}
+
+func (s *MemoryStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.secrets[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return v, nil
+}
+
+func (s *MemoryStore) Set(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[key] = value
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, key)
+	return nil
+}
+
+func (s *MemoryStore) Name() string { return "memory" }
+
+// Keys lists the stored keys, sorted.
+func (s *MemoryStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// secretSlot is a token of the config and its key in the store.
+type secretSlot struct {
+	key string
+	get func() string
+	set func(string)
+}
+
+func fieldSlot(key string, field *string) secretSlot {
+	return secretSlot{key, func() string { return *field }, func(v string) { *field = v }}
+}
+
+// secretSlots lists the tokens of cfg: the account token, each server's
+// access token and each home user's tokens.
+func secretSlots(cfg *Config) []secretSlot {
+	slots := []secretSlot{fieldSlot("token", &cfg.Plex.Token)}
+	for i := range cfg.Plex.Servers {
+		s := &cfg.Plex.Servers[i]
+		slots = append(slots, fieldSlot("servers/"+s.MachineIdentifier+"/token", &s.AccessToken))
+	}
+	for i := range cfg.Plex.Users {
+		u := &cfg.Plex.Users[i]
+		slots = append(slots, fieldSlot("users/"+u.UUID+"/token", &u.Token))
+		for _, s := range cfg.Plex.Servers {
+			id := s.MachineIdentifier
+			slots = append(slots, secretSlot{
+				key: "users/" + u.UUID + "/servers/" + id + "/token",
+				get: func() string { return u.ServerTokens[id] },
+				set: func(v string) {
+					if u.ServerTokens == nil {
+						u.ServerTokens = map[string]string{}
+					}
+					u.ServerTokens[id] = v
+				},
+			})
+		}
+	}
+	return slots
+}
+
+// storedSecrets is the store of the tokens of a Config, remembering what it
+// holds so Save only writes changes.
+type storedSecrets struct {
+	store SecretStore
+
+	mu     sync.Mutex
+	stored map[string]string
+}
+
+func newStoredSecrets(store SecretStore) *storedSecrets {
+	return &storedSecrets{store: store, stored: map[string]string{}}
+}
+
+// loadSecrets fills the token fields of cfg from s. Fields already set,
+// i.e. plaintext tokens of an older config, are kept; migrated reports
+// whether there were any.
+func loadSecrets(cfg *Config, s *storedSecrets) (migrated bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	store, stored := s.store, s.stored
+	for _, slot := range secretSlots(cfg) {
+		if slot.get() != "" {
+			migrated = true
+			continue
+		}
+		v, err := store.Get(slot.key)
+		if errors.Is(err, ErrSecretNotFound) {
+			stored[slot.key] = ""
+			continue
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("reading %s from %s: %w", slot.key, store.Name(), err)
+		}
+		slot.set(v)
+		stored[slot.key] = v
+	}
+	return migrated, nil
+}
+
+// saveSecrets writes the token fields of cfg to s and returns a copy of cfg
+// without them, to be encoded.
+func saveSecrets(cfg *Config, s *storedSecrets) (*Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	store, stored := s.store, s.stored
+	for _, slot := range secretSlots(cfg) {
+		v := slot.get()
+		if old, ok := stored[slot.key]; old == v && (ok || v == "") {
+			continue
+		}
+		if v == "" {
+			if err := store.Delete(slot.key); err != nil && !errors.Is(err, ErrSecretNotFound) {
+				return nil, fmt.Errorf("removing %s from %s: %w", slot.key, store.Name(), err)
+			}
+		} else if err := store.Set(slot.key, v); err != nil {
+			return nil, fmt.Errorf("saving %s to %s: %w", slot.key, store.Name(), err)
+		}
+		stored[slot.key] = v
+	}
+
+	public := *cfg
+	public.Plex.Token = ""
+	public.Plex.Servers = append([]ServerConfig(nil), cfg.Plex.Servers...)
+	for i := range public.Plex.Servers {
+		public.Plex.Servers[i].AccessToken = ""
+	}
+	public.Plex.Users = append([]UserConfig(nil), cfg.Plex.Users...)
+	for i := range public.Plex.Users {
+		u := &public.Plex.Users[i]
+		u.Token = ""
+		u.ServerTokens = nil
+	}
+	return &public, nil
+}
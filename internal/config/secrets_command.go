@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// commandStore keeps secrets with external commands, such as pass. The
+// get command prints the secret; the set command reads it on stdin.
+type commandStore struct {
+	name          string
+	get, set, del []string
+	// notFound recognises the get command's error output for a missing key
+	notFound func(stderr string) bool
+}
+
+// passStore keeps secrets in pass, the standard Unix password manager,
+// under plex-client/.
+func passStore() *commandStore {
+	return &commandStore{
+		name: "pass",
+		get:  []string{"pass", "show", "plex-client/{key}"},
+		set:  []string{"pass", "insert", "--multiline", "--force", "plex-client/{key}"},
+		del:  []string{"pass", "rm", "--force", "plex-client/{key}"},
+		notFound: func(stderr string) bool {
+			return strings.Contains(stderr, "is not in the password store")
+		},
+	}
+}
+
+func (s *commandStore) Get(key string) (string, error) {
+	out, stderr, err := s.run(s.get, key, "")
+	if err != nil {
+		// A command that ran and failed is taken to lack the key unless it
+		// says otherwise
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && (s.notFound == nil || s.notFound(stderr)) {
+			return "", ErrSecretNotFound
+		}
+		return "", s.error("get", err, stderr)
+	}
+	// pass keeps the secret on the first line
+	secret, _, _ := strings.Cut(out, "\n")
+	if secret == "" {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func (s *commandStore) Set(key, value string) error {
+	if _, stderr, err := s.run(s.set, key, value+"\n"); err != nil {
+		return s.error("set", err, stderr)
+	}
+	return nil
+}
+
+func (s *commandStore) Delete(key string) error {
+	if len(s.del) == 0 {
+		return s.Set(key, "") // Best effort without a delete command
+	}
+	if _, stderr, err := s.run(s.del, key, ""); err != nil {
+		if s.notFound != nil && s.notFound(stderr) {
+			return ErrSecretNotFound
+		}
+		return s.error("delete", err, stderr)
+	}
+	return nil
+}
+
+func (s *commandStore) Name() string { return s.name }
+
+func (s *commandStore) run(argv []string, key, stdin string) (string, string, error) {
+	args := make([]string, len(argv))
+	for i, a := range argv {
+		args[i] = strings.ReplaceAll(a, "{key}", key)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func (s *commandStore) error(op string, err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("%s %s: %w: %s", s.name, op, err, msg)
+	}
+	return fmt.Errorf("%s %s: %w", s.name, op, err)
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PassphraseEnv names the variable holding the passphrase of the encrypted
+// secrets file. Without it, the file is bound to this machine and user.
+const PassphraseEnv = "PLEX_CLIENT_PASSPHRASE"
+
+const pbkdf2Iterations = 200_000
+
+// fileStore keeps secrets in secrets.enc next to config.toml, encrypted
+// with AES-GCM. It is the fallback when no keyring is available: it keeps
+// tokens out of a config that gets shared, but without a passphrase anyone
+// able to log in as the user can decrypt it.
+type fileStore struct {
+	path       string
+	passphrase string
+
+	mu      sync.Mutex
+	key     []byte // Derived on first use
+	salt    []byte
+	secrets map[string]string
+	stale   bool // The file could not be decrypted; it is kept aside on write
+}
+
+type secretsFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func newFileStore() (*fileStore, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		passphrase = machineSecret()
+	}
+	return &fileStore{path: filepath.Join(dir, "secrets.enc"), passphrase: passphrase}, nil
+}
+
+// machineSecret identifies this machine and user, binding the file to them.
+// The hostname is left out, so that renaming the host keeps the file.
+func machineSecret() string {
+	parts := []string{"plex-client"}
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if b, err := os.ReadFile(path); err == nil {
+			parts = append(parts, strings.TrimSpace(string(b)))
+			break
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		parts = append(parts, u.Uid)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func (s *fileStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	v, ok := s.secrets[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return v, nil
+}
+
+func (s *fileStore) Set(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.secrets[key] = value
+	return s.write()
+}
+
+func (s *fileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.secrets[key]; !ok {
+		return ErrSecretNotFound
+	}
+	delete(s.secrets, key)
+	return s.write()
+}
+
+func (s *fileStore) Name() string { return "encrypted file" }
+
+// load reads and decrypts the file once.
+func (s *fileStore) load() error {
+	if s.secrets != nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.secrets = map[string]string{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f secretsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	if f.Version != 1 {
+		return fmt.Errorf("%s: unsupported version %d", s.path, f.Version)
+	}
+	aead, err := s.cipher(f.Salt)
+	if err != nil {
+		return err
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		// Logging in again is better than not starting at all
+		log.Printf("Warning: %s cannot be decrypted; was it made on another machine or with another %s? Log in again to replace it.", s.path, PassphraseEnv)
+		s.secrets, s.stale = map[string]string{}, true
+		return nil
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.secrets = secrets
+	return nil
+}
+
+func (s *fileStore) write() error {
+	if s.stale {
+		// Keep the old secrets, should the right passphrase come back
+		if err := os.Rename(s.path, s.path+".old"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		s.stale, s.salt = false, nil
+	}
+	if s.salt == nil {
+		s.salt = make([]byte, 16)
+		if _, err := rand.Read(s.salt); err != nil {
+			return err
+		}
+	}
+	aead, err := s.cipher(s.salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	plain, err := json.Marshal(s.secrets)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(secretsFile{
+		Version: 1,
+		Salt:    s.salt,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+	return writePrivate(s.path, data)
+}
+
+// cipher derives the key for salt, reusing it while the salt is the same.
+func (s *fileStore) cipher(salt []byte) (cipher.AEAD, error) {
+	if s.key == nil || string(s.salt) != string(salt) {
+		key, err := pbkdf2.Key(sha256.New, s.passphrase, salt, pbkdf2Iterations, 32)
+		if err != nil {
+			return nil, err
+		}
+		s.key, s.salt = key, salt
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService names the client's entries in the system keyring.
+const keyringService = "plex-client"
+
+// keyringStore keeps secrets in the system keyring: the Secret Service over
+// D-Bus (GNOME Keyring, KWallet) on Linux, the Keychain on macOS and the
+// Credential Manager on Windows.
+type keyringStore struct{}
+
+func newKeyringStore() keyringStore {
+	return keyringStore{}
+}
+
+// available reports whether the keyring answers, e.g. false without a
+// D-Bus session.
+func (keyringStore) available() bool {
+	_, err := keyring.Get(keyringService, "token")
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+func (keyringStore) Get(key string) (string, error) {
+	v, err := keyring.Get(keyringService, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrSecretNotFound
+	}
+	return v, err
+}
+
+func (keyringStore) Set(key, value string) error {
+	return keyring.Set(keyringService, key, value)
+}
+
+func (keyringStore) Delete(key string) error {
+	err := keyring.Delete(keyringService, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrSecretNotFound
+	}
+	return err
+}
+
+func (keyringStore) Name() string { return "system keyring" }
@@ -2,21 +2,27 @@ package db
 
 import (
 	"database/sql"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Waddenn/plex-client/internal/config"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func Open() (*sql.DB, error) {
-	cacheDir, err := config.CacheDir()
+// Open opens the cache of a server, identified by its machine identifier,
+// or by config.PlexConfig.CacheID for a Plex Home user. Each server and
+// user gets its own database so libraries and watch state never mix; an
+// empty id opens the cache used before servers were tracked.
+func Open(serverID string) (*sql.DB, error) {
+	dbPath, err := Path(serverID)
 	if err != nil {
 		return nil, err
 	}
-	dbPath := filepath.Join(cacheDir, "cache.db")
 
-	// Add busy timeout, WAL mode, and immediate transaction lock to connection string
-	dsn := dbPath + "?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+	// Add busy timeout, WAL mode, immediate transaction lock and foreign keys to connection string.
+	// Foreign keys must be enabled per connection for ON DELETE CASCADE to apply.
+	dsn := dbPath + "?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate&_foreign_keys=1"
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
@@ -30,6 +36,54 @@ func Open() (*sql.DB, error) {
 	return db, nil
 }
 
+// Path returns the cache file of a server, see Open.
+func Path(serverID string) (string, error) {
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	if serverID == "" {
+		return filepath.Join(cacheDir, "cache.db"), nil
+	}
+	return filepath.Join(cacheDir, "cache-"+safeName(serverID)+".db"), nil
+}
+
+// AdoptLegacyCache moves the cache used before servers were tracked to the
+// file of serverID, unless that server already has a cache.
+func AdoptLegacyCache(serverID string) error {
+	legacy, err := Path("")
+	if err != nil {
+		return err
+	}
+	target, err := Path(serverID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	if _, err := os.Stat(legacy); os.IsNotExist(err) {
+		return nil
+	}
+	// WAL and shared-memory files must follow the main file
+	for _, suffix := range []string{"-wal", "-shm", ""} {
+		if err := os.Rename(legacy+suffix, target+suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// safeName keeps an identifier usable as part of a file name.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
 func initSchema(db *sql.DB) error {
 	if _, err := db.Exec("PRAGMA foreign_keys=ON;"); err != nil {
 		return err
@@ -63,10 +117,16 @@ func initSchema(db *sql.DB) error {
             video_resolution TEXT,
             video_codec TEXT,
             audio_codec TEXT,
-            audio_channels INTEGER
+            audio_channels INTEGER,
+            bitrate INTEGER,
+            section_key TEXT,
+            view_count INTEGER DEFAULT 0,
+            view_offset INTEGER DEFAULT 0,
+            last_viewed_at INTEGER DEFAULT 0
         );`,
 		`CREATE INDEX IF NOT EXISTS idx_films_title ON films(title);`,
 		`CREATE INDEX IF NOT EXISTS idx_films_year ON films(year);`,
+		`CREATE INDEX IF NOT EXISTS idx_films_section_key ON films(section_key);`,
 
 		`CREATE TABLE IF NOT EXISTS series (
             id INTEGER PRIMARY KEY,
@@ -79,9 +139,11 @@ func initSchema(db *sql.DB) error {
             content_rating TEXT,
             studio TEXT,
             added_at INTEGER,
-            updated_at INTEGER
+            updated_at INTEGER,
+            section_key TEXT
         );`,
 		`CREATE INDEX IF NOT EXISTS idx_series_title ON series(title);`,
+		`CREATE INDEX IF NOT EXISTS idx_series_section_key ON series(section_key);`,
 
 		`CREATE TABLE IF NOT EXISTS seasons (
             id INTEGER PRIMARY KEY,
@@ -107,6 +169,10 @@ func initSchema(db *sql.DB) error {
             video_codec TEXT,
             audio_codec TEXT,
             audio_channels INTEGER,
+            bitrate INTEGER,
+            view_count INTEGER DEFAULT 0,
+            view_offset INTEGER DEFAULT 0,
+            last_viewed_at INTEGER DEFAULT 0,
             FOREIGN KEY(season_id) REFERENCES seasons(id) ON DELETE CASCADE
         );`,
 		`CREATE INDEX IF NOT EXISTS idx_episodes_season_id ON episodes(season_id);`,
@@ -116,6 +182,32 @@ func initSchema(db *sql.DB) error {
 			type TEXT,
 			updated_at INTEGER
 		);`,
+		// Calls to Plex waiting for the server to be reachable, see cache.Outbox
+		`CREATE TABLE IF NOT EXISTS outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			rating_key TEXT NOT NULL,
+			time_ms INTEGER DEFAULT 0,
+			duration_ms INTEGER DEFAULT 0,
+			state TEXT DEFAULT '',
+			rating REAL DEFAULT 0,
+			created_at INTEGER,
+			reported_at INTEGER DEFAULT 0
+		);`,
+		// Files downloaded for offline playback, see download.Manager
+		`CREATE TABLE IF NOT EXISTS downloads (
+			rating_key TEXT PRIMARY KEY,
+			type TEXT DEFAULT '',
+			title TEXT DEFAULT '',
+			part_key TEXT DEFAULT '',
+			path TEXT DEFAULT '',
+			size INTEGER DEFAULT 0,
+			received INTEGER DEFAULT 0,
+			status TEXT NOT NULL,
+			error TEXT DEFAULT '',
+			added_at INTEGER,
+			completed_at INTEGER DEFAULT 0
+		);`,
 	}
 
 	for _, q := range queries {
@@ -123,6 +215,10 @@ func initSchema(db *sql.DB) error {
 			return err
 		}
 	}
+
+	if _, err := EnableSearchIndex(db); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -282,6 +378,55 @@ func migrateSchema(db *sql.DB) error {
 		}
 	}
 
+	// Add section_key to films and series so libraries can be browsed separately,
+	// watch state columns so badges work without the network, and the bitrate
+	// so cached items are only transcoded when over the quality preset.
+	// Existing rows are backfilled by the next sync, so forget the per-section
+	// sync timestamps to force it.
+	forceResync := false
+	newColumns := []struct{ table, column, def string }{
+		{"films", "section_key", "TEXT"},
+		{"series", "section_key", "TEXT"},
+		{"films", "view_count", "INTEGER DEFAULT 0"},
+		{"films", "view_offset", "INTEGER DEFAULT 0"},
+		{"films", "last_viewed_at", "INTEGER DEFAULT 0"},
+		{"episodes", "view_count", "INTEGER DEFAULT 0"},
+		{"episodes", "view_offset", "INTEGER DEFAULT 0"},
+		{"episodes", "last_viewed_at", "INTEGER DEFAULT 0"},
+		{"films", "bitrate", "INTEGER"},
+		{"episodes", "bitrate", "INTEGER"},
+	}
+	for _, c := range newColumns {
+		exists, err := tableExists(tx, c.table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		hasColumn, err := columnExists(tx, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if !hasColumn {
+			if _, err := tx.Exec(`ALTER TABLE ` + c.table + ` ADD COLUMN ` + c.column + ` ` + c.def + `;`); err != nil {
+				return err
+			}
+			forceResync = true
+		}
+	}
+	if forceResync {
+		hasMetadata, err := tableExists(tx, "metadata")
+		if err != nil {
+			return err
+		}
+		if hasMetadata {
+			if _, err := tx.Exec(`DELETE FROM metadata WHERE key LIKE 'section_%';`); err != nil {
+				return err
+			}
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
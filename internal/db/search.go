@@ -0,0 +1,115 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// searchSource is a table mirrored into the search_index FTS5 table.
+// Plex rating keys are unique across a server, so the item id doubles as the
+// index rowid.
+type searchSource struct {
+	table  string
+	kind   string
+	tagged bool // Has cast and genres columns
+}
+
+var searchSources = []searchSource{
+	{"films", "movie", true},
+	{"series", "show", true},
+	{"episodes", "episode", false},
+}
+
+// values returns the index row of an item, reading columns through prefix
+// ("" or "new.").
+func (s searchSource) values(prefix string) string {
+	if !s.tagged {
+		return fmt.Sprintf(`%[1]sid, '%[2]s', %[1]stitle, %[1]ssummary, NULL, NULL`, prefix, s.kind)
+	}
+	return fmt.Sprintf(`%[1]sid, '%[2]s', %[1]stitle, %[1]ssummary, %[1]s"cast", %[1]sgenres`, prefix, s.kind)
+}
+
+func (s searchSource) triggers() []string {
+	return []string{"search_" + s.table + "_ai", "search_" + s.table + "_au", "search_" + s.table + "_ad"}
+}
+
+// EnableSearchIndex creates the full-text search index over films, series and
+// episodes, kept up to date by triggers. It reports false, without error,
+// when SQLite was built without FTS5 (the sqlite_fts5 build tag); searches
+// then fall back to LIKE queries.
+func EnableSearchIndex(db *sql.DB) (bool, error) {
+	var hasFTS5 bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5');`).Scan(&hasFTS5); err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if !hasFTS5 {
+		// An index left by an FTS5 build would make every write fail through
+		// its triggers. Drop them; the index is rebuilt once FTS5 is back.
+		for _, src := range searchSources {
+			for _, name := range src.triggers() {
+				if _, err := tx.Exec(`DROP TRIGGER IF EXISTS ` + name + `;`); err != nil {
+					return false, err
+				}
+			}
+		}
+		return false, tx.Commit()
+	}
+
+	if _, err := tx.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		kind UNINDEXED, title, summary, "cast", genres,
+		tokenize = 'unicode61 remove_diacritics 2'
+	);`); err != nil {
+		return false, err
+	}
+
+	var triggers int
+	if err := tx.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type='trigger' AND name LIKE 'search\_%' ESCAPE '\';`).Scan(&triggers); err != nil {
+		return false, err
+	}
+	if triggers == len(searchSources)*3 {
+		return true, tx.Commit()
+	}
+
+	// First run, or the triggers were dropped by a build without FTS5:
+	// rebuild the index from scratch.
+	if _, err := tx.Exec(`DELETE FROM search_index;`); err != nil {
+		return false, err
+	}
+	const columns = `rowid, kind, title, summary, "cast", genres`
+	for _, src := range searchSources {
+		watched := "title, summary"
+		if src.tagged {
+			watched += `, "cast", genres`
+		}
+		names := src.triggers()
+		queries := []string{
+			fmt.Sprintf(`INSERT INTO search_index (%s) SELECT %s FROM %s;`, columns, src.values(""), src.table),
+			// REPLACE does not fire delete triggers, so inserts clear the row first.
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER INSERT ON %s BEGIN
+				DELETE FROM search_index WHERE rowid = new.id;
+				INSERT INTO search_index (%s) VALUES (%s);
+			END;`, names[0], src.table, columns, src.values("new.")),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE OF %s ON %s BEGIN
+				DELETE FROM search_index WHERE rowid = old.id;
+				INSERT INTO search_index (%s) VALUES (%s);
+			END;`, names[1], watched, src.table, columns, src.values("new.")),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER DELETE ON %s BEGIN
+				DELETE FROM search_index WHERE rowid = old.id;
+			END;`, names[2], src.table),
+		}
+		for _, q := range queries {
+			if _, err := tx.Exec(q); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,388 @@
+package download
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+// How often a running download reports its progress and records it.
+var (
+	progressInterval = 500 * time.Millisecond
+	saveInterval     = 5 * time.Second
+)
+
+// stallTimeout is how long a download waits for data before giving up on
+// the connection. The partial file is kept, so a retry resumes it.
+var stallTimeout = time.Minute
+
+// errStalled fails a download whose server stopped sending.
+var errStalled = errors.New("download stalled: no data received")
+
+// Event reports a change of the download Item: progress, or a new state.
+type Event struct {
+	Item Item
+}
+
+// Manager downloads the queued items of a store one at a time. A file is
+// written next to its final path with a .part suffix and resumed from
+// there with a range request after a quit, a crash or a lost connection.
+type Manager struct {
+	Store *Store
+	Plex  *plex.Client
+	Dir   string // Where files are saved
+
+	events chan Event
+	wake   chan struct{}
+
+	mu     sync.Mutex
+	active string             // Rating key being downloaded
+	cancel context.CancelFunc // Stops it
+}
+
+func NewManager(s *Store, p *plex.Client, dir string) *Manager {
+	return &Manager{Store: s, Plex: p, Dir: dir, events: make(chan Event, 16), wake: make(chan struct{}, 1)}
+}
+
+// Events streams the changes of downloads until Run returns. It must be
+// read while Run is running.
+func (m *Manager) Events() <-chan Event {
+	return m.events
+}
+
+// Add queues movies and episodes and returns how many were not already
+// queued or downloaded.
+func (m *Manager) Add(videos ...plex.Video) (int, error) {
+	added := 0
+	for _, v := range videos {
+		ok, err := m.Store.Add(v.RatingKey, v.Type, label(v))
+		if err != nil {
+			return added, err
+		}
+		if ok {
+			added++
+		}
+	}
+	if added > 0 {
+		m.notify()
+	}
+	return added, nil
+}
+
+// Retry queues the failed download of ratingKey again.
+func (m *Manager) Retry(ratingKey string) error {
+	it, err := m.Store.Get(ratingKey)
+	if err != nil {
+		return err
+	}
+	if _, err := m.Store.Add(it.RatingKey, it.Type, it.Title); err != nil {
+		return err
+	}
+	m.notify()
+	return nil
+}
+
+// Remove stops the download of ratingKey if it is running, and deletes it
+// along with its file.
+func (m *Manager) Remove(ratingKey string) error {
+	m.mu.Lock()
+	if m.active == ratingKey && m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+
+	it, err := m.Store.remove(ratingKey)
+	if err != nil {
+		return err
+	}
+	return removeFiles(it)
+}
+
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run downloads the queued items until ctx is done, then closes Events.
+func (m *Manager) Run(ctx context.Context) {
+	defer close(m.events)
+	for ctx.Err() == nil {
+		it, err := m.Store.next()
+		if err == nil {
+			m.download(ctx, it)
+			continue
+		}
+		if err != sql.ErrNoRows {
+			log.Printf("Error reading downloads: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-m.wake:
+		}
+	}
+}
+
+// download fetches it and records the outcome. Stopped along with Run, it
+// is left to resume on the next one.
+func (m *Manager) download(ctx context.Context, it Item) {
+	itemCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.mu.Lock()
+	m.active, m.cancel = it.RatingKey, cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.active, m.cancel = "", nil
+		m.mu.Unlock()
+	}()
+
+	it.Status, it.Err = StatusDownloading, ""
+	m.update(ctx, it)
+
+	err := m.fetch(itemCtx, &it)
+	if _, getErr := m.Store.Get(it.RatingKey); getErr == sql.ErrNoRows {
+		// Removed meanwhile
+		if err := removeFiles(it); err != nil {
+			log.Printf("Error deleting the download of %s: %v", it.Title, err)
+		}
+		return
+	}
+	switch {
+	case err == nil:
+		it.Status, it.CompletedAt = StatusDone, time.Now().Unix()
+	case ctx.Err() != nil:
+	default:
+		it.Status, it.Err = StatusFailed, err.Error()
+	}
+	m.update(ctx, it)
+}
+
+// update records it and reports it on Events.
+func (m *Manager) update(ctx context.Context, it Item) {
+	if err := m.Store.save(it); err != nil {
+		log.Printf("Error saving the download of %s: %v", it.Title, err)
+	}
+	select {
+	case m.events <- Event{Item: it}:
+	case <-ctx.Done():
+	}
+}
+
+// fetch writes the file of it, resuming a partial one.
+func (m *Manager) fetch(ctx context.Context, it *Item) error {
+	if it.PartKey == "" {
+		v, err := m.Plex.GetMetadataContext(ctx, it.RatingKey)
+		if err != nil {
+			return err
+		}
+		if len(v.Media) == 0 || len(v.Media[0].Part) == 0 {
+			return fmt.Errorf("no media part found for %s", v.Title)
+		}
+		it.PartKey = v.Media[0].Part[0].Key
+		it.Title = label(*v)
+		it.Path = filepath.Join(m.Dir, filePath(*v, it.PartKey))
+		m.update(ctx, *it)
+	}
+	if err := os.MkdirAll(filepath.Dir(it.Path), 0755); err != nil {
+		return err
+	}
+
+	var offset int64
+	if info, err := os.Stat(it.partPath()); err == nil {
+		offset = info.Size()
+	}
+	if it.Size > 0 && offset == it.Size {
+		return os.Rename(it.partPath(), it.Path)
+	}
+
+	// Reading blocks on a connection that went silent, so it is
+	// cancelled once no data arrives for stallTimeout
+	reqCtx, stall := context.WithCancelCause(ctx)
+	defer stall(nil)
+	idle := time.AfterFunc(stallTimeout, func() { stall(errStalled) })
+	defer idle.Stop()
+
+	resp, err := m.Plex.OpenPart(reqCtx, it.PartKey, offset)
+	var statusErr *plex.StatusError
+	if offset > 0 && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The partial file is longer than the server's: start over
+		if err := os.Remove(it.partPath()); err != nil {
+			return err
+		}
+		it.Size, it.Received = 0, 0
+		return m.fetch(ctx, it)
+	}
+	if err != nil {
+		return stalled(reqCtx, err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resp.StatusCode == http.StatusPartialContent {
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != offset {
+			return fmt.Errorf("server resumed at byte %d instead of %d", start, offset)
+		}
+		it.Size = total
+	} else {
+		// The server ignored the range and sends the whole file
+		offset = 0
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		it.Size = max(resp.ContentLength, 0)
+	}
+	it.Received = offset
+
+	f, err := os.OpenFile(it.partPath(), flags, 0644)
+	if err != nil {
+		return err
+	}
+	err = m.copy(reqCtx, f, resp.Body, it, idle)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if it.Size > 0 && it.Received != it.Size {
+		return fmt.Errorf("download ended at byte %d of %d", it.Received, it.Size)
+	}
+	it.Size = it.Received
+	return os.Rename(it.partPath(), it.Path)
+}
+
+// copy writes body to f, counting the bytes in it.Received and reporting
+// them as it goes. Each read with data pushes back the idle timer.
+func (m *Manager) copy(ctx context.Context, f *os.File, body io.Reader, it *Item, idle *time.Timer) error {
+	buf := make([]byte, 256*1024)
+	lastProgress, lastSave := time.Now(), time.Now()
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			idle.Reset(stallTimeout)
+			if _, err := f.Write(buf[:n]); err != nil {
+				return err
+			}
+			it.Received += int64(n)
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return stalled(ctx, readErr)
+		}
+
+		if time.Since(lastSave) >= saveInterval {
+			lastSave = time.Now()
+			if err := m.Store.save(*it); err != nil {
+				log.Printf("Error saving the download of %s: %v", it.Title, err)
+			}
+		}
+		if time.Since(lastProgress) >= progressInterval {
+			lastProgress = time.Now()
+			select {
+			case m.events <- Event{Item: *it}:
+			default: // Progress is dropped rather than waited for
+			}
+		}
+	}
+}
+
+// stalled returns errStalled in place of err when the request was given up
+// for lack of data.
+func stalled(ctx context.Context, err error) error {
+	if context.Cause(ctx) == errStalled {
+		return errStalled
+	}
+	return err
+}
+
+// parseContentRange reads the first byte and the file size of a
+// "bytes first-last/size" header. The size is 0 when the server did not
+// give it.
+func parseContentRange(h string) (start, total int64, err error) {
+	spec, ok := strings.CutPrefix(h, "bytes ")
+	rng, size, found := strings.Cut(spec, "/")
+	first, _, dash := strings.Cut(rng, "-")
+	if !ok || !found || !dash {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	if size == "*" {
+		return start, 0, nil
+	}
+	if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	return start, total, nil
+}
+
+func removeFiles(it Item) error {
+	if it.Path == "" {
+		return nil
+	}
+	for _, p := range []string{it.Path, it.partPath()} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// label names v in the downloads list.
+func label(v plex.Video) string {
+	if v.Type == "episode" && v.GrandparentTitle != "" {
+		return fmt.Sprintf("%s - S%02dE%02d - %s", v.GrandparentTitle, v.ParentIndex, v.Index, v.Title)
+	}
+	if v.Type == "movie" && v.Year > 0 {
+		return fmt.Sprintf("%s (%d)", v.Title, v.Year)
+	}
+	return v.Title
+}
+
+// filePath returns where v is saved under the downloads directory, the
+// way media servers lay out libraries: Movies/Title (Year) [key].mkv and
+// TV/Show/Season 01/Show - S01E02 - Title [key].mkv. The rating key keeps
+// the names of different items apart.
+func filePath(v plex.Video, partKey string) string {
+	ext := path.Ext(partKey)
+	if ext == "" {
+		ext = ".mkv"
+	}
+	name := fmt.Sprintf("%s [%s]%s", sanitize(label(v)), v.RatingKey, ext)
+	if v.Type == "episode" && v.GrandparentTitle != "" {
+		show := sanitize(v.GrandparentTitle)
+		return filepath.Join("TV", show, fmt.Sprintf("Season %02d", v.ParentIndex), name)
+	}
+	return filepath.Join("Movies", name)
+}
+
+var unsafeChars = strings.NewReplacer("/", "-", "\\", "-", ":", " -", "*", "", "?", "", "\"", "'", "<", "", ">", "", "|", "-")
+
+// sanitize makes a title usable as a file name on every platform.
+func sanitize(s string) string {
+	s = strings.Trim(unsafeChars.Replace(s), " .")
+	if s == "" {
+		return "Untitled"
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+	"github.com/Waddenn/plex-client/internal/db"
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+func TestManager(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	d, err := db.Open("download-test")
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer d.Close()
+
+	content := bytes.Repeat([]byte("0123456789"), 100_000)
+	ignoreRange, stall := false, false
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/library/metadata/1":
+			w.Write([]byte(`<MediaContainer><Video ratingKey="1" type="movie" title="Alien: Director's Cut" year="1979"><Media><Part key="/library/parts/11/1/file.mkv"/></Media></Video></MediaContainer>`))
+		case "/library/metadata/2":
+			w.Write([]byte(`<MediaContainer><Video ratingKey="2" type="episode" title="Pilot" grandparentTitle="The Expanse" parentIndex="1" index="1"><Media><Part key="/library/parts/12/1/file.mp4"/></Media></Video></MediaContainer>`))
+		case "/library/parts/11/1/file.mkv", "/library/parts/12/1/file.mp4":
+			ranges = append(ranges, r.Header.Get("Range"))
+			if ignoreRange {
+				r.Header.Del("Range")
+			}
+			if stall {
+				// Send part of the file, then nothing until given up
+				w.Write(content[:200_000])
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				return
+			}
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(NewStore(d), plex.New(srv.URL, "token", "client-id", appinfo.Default()), dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	// wait returns the download of key once it is over
+	wait := func(key string) Item {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-m.Events():
+				if ev.Item.RatingKey == key && (ev.Item.Status == StatusDone || ev.Item.Status == StatusFailed) {
+					return ev.Item
+				}
+			case <-timeout:
+				t.Fatalf("Download of %s did not finish", key)
+			}
+		}
+	}
+	check := func(it Item) {
+		t.Helper()
+		if it.Status != StatusDone {
+			t.Fatalf("Expected %s downloaded, got %s: %s", it.RatingKey, it.Status, it.Err)
+		}
+		got, err := os.ReadFile(it.Path)
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("Expected the whole file at %s, got %d bytes (%v)", it.Path, len(got), err)
+		}
+		if path, ok := m.Store.LocalPath(it.RatingKey); !ok || path != it.Path {
+			t.Errorf("Expected local path %s, got %q", it.Path, path)
+		}
+	}
+
+	if n, err := m.Add(plex.Video{RatingKey: "1", Type: "movie", Title: "Alien"}); err != nil || n != 1 {
+		t.Fatalf("Expected 1 download added, got %d (%v)", n, err)
+	}
+	it := wait("1")
+	check(it)
+	if want := filepath.Join(dir, "Movies", "Alien - Director's Cut (1979) [1].mkv"); it.Path != want {
+		t.Errorf("Expected %s, got %s", want, it.Path)
+	}
+	if n, _ := m.Add(plex.Video{RatingKey: "1", Type: "movie", Title: "Alien"}); n != 0 {
+		t.Errorf("Expected a downloaded item not to be queued again")
+	}
+
+	// An interrupted episode resumes where its partial file ends
+	path := filepath.Join(dir, "TV", "The Expanse", "Season 01", "The Expanse - S01E01 - Pilot [2].mp4")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path+".part", content[:300_000], 0644)
+	m.Store.Add("2", "episode", "Pilot")
+	m.Store.save(Item{RatingKey: "2", Title: "Pilot", PartKey: "/library/parts/12/1/file.mp4", Path: path, Status: StatusDownloading})
+	m.notify()
+	check(wait("2"))
+	if got := ranges[len(ranges)-1]; got != "bytes=300000-" {
+		t.Errorf("Expected the download to resume at byte 300000, got range %q", got)
+	}
+
+	// A server ignoring the range sends the whole file again
+	m.Remove("2")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the file removed with its download, got %v", err)
+	}
+	ignoreRange = true
+	os.WriteFile(path+".part", []byte(strings.Repeat("x", 500)), 0644)
+	m.Store.Add("2", "episode", "Pilot")
+	m.Store.save(Item{RatingKey: "2", Title: "Pilot", PartKey: "/library/parts/12/1/file.mp4", Path: path, Status: StatusQueued})
+	m.notify()
+	check(wait("2"))
+
+	// A connection that goes silent fails the download; a retry resumes it
+	defer func(d time.Duration) { stallTimeout = d }(stallTimeout)
+	stallTimeout = 100 * time.Millisecond
+	m.Remove("2")
+	ignoreRange, stall = false, true
+	m.Store.Add("2", "episode", "Pilot")
+	m.Store.save(Item{RatingKey: "2", Title: "Pilot", PartKey: "/library/parts/12/1/file.mp4", Path: path, Status: StatusQueued})
+	m.notify()
+	if it := wait("2"); it.Status != StatusFailed || it.Err != errStalled.Error() {
+		t.Fatalf("Expected the download of 2 to stall, got %s: %s", it.Status, it.Err)
+	}
+	stall = false
+	m.Retry("2")
+	check(wait("2"))
+	if got := ranges[len(ranges)-1]; got != "bytes=200000-" {
+		t.Errorf("Expected the stalled download to resume at byte 200000, got range %q", got)
+	}
+
+	// Failures are kept for a retry
+	m.Add(plex.Video{RatingKey: "3", Type: "movie", Title: "Missing"})
+	if it := wait("3"); it.Status != StatusFailed || it.Err == "" {
+		t.Errorf("Expected the download of 3 to fail, got %+v", it)
+	}
+	if n, _ := m.Add(plex.Video{RatingKey: "3", Type: "movie", Title: "Missing"}); n != 1 {
+		t.Errorf("Expected a failed download to be queued again")
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		start, total int64
+		ok           bool
+	}{
+		{"bytes 100-999/1000", 100, 1000, true},
+		{"bytes 0-99/*", 0, 0, true},
+		{"bytes */1000", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, err := parseContentRange(tt.header)
+		if (err == nil) != tt.ok || start != tt.start || total != tt.total {
+			t.Errorf("%q: expected %d/%d (ok %v), got %d/%d (%v)", tt.header, tt.start, tt.total, tt.ok, start, total, err)
+		}
+	}
+}
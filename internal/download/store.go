@@ -0,0 +1,141 @@
+// Package download saves movies and episodes to disk for offline playback.
+package download
+
+import (
+	"database/sql"
+	"os"
+	"time"
+)
+
+// States of a download.
+const (
+	StatusQueued      = "queued"
+	StatusDownloading = "downloading"
+	StatusDone        = "done"
+	StatusFailed      = "failed"
+)
+
+// Item is a movie or episode in the downloads table of a cache.
+type Item struct {
+	RatingKey   string
+	Type        string // movie or episode
+	Title       string
+	PartKey     string // Set once the download starts
+	Path        string // Where the finished file goes; set with PartKey
+	Size        int64  // Bytes; 0 until the server tells
+	Received    int64  // Bytes on disk
+	Status      string
+	Err         string // Why it failed
+	AddedAt     int64
+	CompletedAt int64
+}
+
+// Progress returns the share of the file received, from 0 to 1.
+func (it Item) Progress() float64 {
+	if it.Status == StatusDone {
+		return 1
+	}
+	if it.Size <= 0 {
+		return 0
+	}
+	return float64(it.Received) / float64(it.Size)
+}
+
+// partPath is where the file is written until it is complete.
+func (it Item) partPath() string {
+	return it.Path + ".part"
+}
+
+// Store keeps the downloads of the server and user a cache belongs to.
+type Store struct {
+	DB *sql.DB
+}
+
+func NewStore(d *sql.DB) *Store {
+	return &Store{DB: d}
+}
+
+const itemColumns = `rating_key, type, title, part_key, path, size, received, status, error, added_at, completed_at`
+
+func scanItem(row interface{ Scan(...interface{}) error }) (Item, error) {
+	var it Item
+	err := row.Scan(&it.RatingKey, &it.Type, &it.Title, &it.PartKey, &it.Path, &it.Size, &it.Received,
+		&it.Status, &it.Err, &it.AddedAt, &it.CompletedAt)
+	return it, err
+}
+
+// Add queues the movie or episode ratingKey and reports whether it was
+// new. A failed download is queued again; others are left as they are.
+func (s *Store) Add(ratingKey, typ, title string) (bool, error) {
+	res, err := s.DB.Exec(`INSERT INTO downloads (rating_key, type, title, status, added_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(rating_key) DO UPDATE SET status = excluded.status, error = '' WHERE status = ?`,
+		ratingKey, typ, title, StatusQueued, time.Now().Unix(), StatusFailed)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Get returns the download of ratingKey, or sql.ErrNoRows.
+func (s *Store) Get(ratingKey string) (Item, error) {
+	return scanItem(s.DB.QueryRow(`SELECT `+itemColumns+` FROM downloads WHERE rating_key = ?`, ratingKey))
+}
+
+// List returns every download, oldest first.
+func (s *Store) List() ([]Item, error) {
+	rows, err := s.DB.Query(`SELECT ` + itemColumns + ` FROM downloads ORDER BY added_at, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Item
+	for rows.Next() {
+		it, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// next returns the oldest download left to do. One interrupted while
+// downloading, by a quit or a crash, comes first.
+func (s *Store) next() (Item, error) {
+	return scanItem(s.DB.QueryRow(`SELECT `+itemColumns+` FROM downloads WHERE status IN (?, ?)
+		ORDER BY status = ? DESC, added_at, rowid LIMIT 1`, StatusQueued, StatusDownloading, StatusDownloading))
+}
+
+// save records the state of it.
+func (s *Store) save(it Item) error {
+	_, err := s.DB.Exec(`UPDATE downloads SET title = ?, part_key = ?, path = ?, size = ?, received = ?, status = ?, error = ?, completed_at = ?
+		WHERE rating_key = ?`,
+		it.Title, it.PartKey, it.Path, it.Size, it.Received, it.Status, it.Err, it.CompletedAt, it.RatingKey)
+	return err
+}
+
+// remove drops the download of ratingKey and returns it.
+func (s *Store) remove(ratingKey string) (Item, error) {
+	it, err := s.Get(ratingKey)
+	if err != nil {
+		return it, err
+	}
+	_, err = s.DB.Exec(`DELETE FROM downloads WHERE rating_key = ?`, ratingKey)
+	return it, err
+}
+
+// LocalPath returns the downloaded file of ratingKey, if it is complete and
+// still on disk.
+func (s *Store) LocalPath(ratingKey string) (string, bool) {
+	var path string
+	err := s.DB.QueryRow(`SELECT path FROM downloads WHERE rating_key = ? AND status = ?`, ratingKey, StatusDone).Scan(&path)
+	if err != nil || path == "" {
+		return "", false
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
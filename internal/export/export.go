@@ -0,0 +1,386 @@
+// Package export writes the cached library as JSON, NDJSON or CSV.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+)
+
+// Format is an output format of Write.
+type Format string
+
+const (
+	JSON   Format = "json"   // One array of objects
+	NDJSON Format = "ndjson" // One object per line
+	CSV    Format = "csv"    // A header row, then one row per item
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case JSON, NDJSON, CSV:
+		return f, nil
+	case "jsonl":
+		return NDJSON, nil
+	}
+	return "", fmt.Errorf("unknown format %q (use json, ndjson or csv)", s)
+}
+
+// FormatOf guesses the format from the extension of a file name, and
+// reports whether it could.
+func FormatOf(name string) (Format, bool) {
+	f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(name), "."))
+	return f, err == nil
+}
+
+// Kind is a type of item to export.
+type Kind string
+
+const (
+	Movies   Kind = "movies"
+	Shows    Kind = "shows"
+	Seasons  Kind = "seasons"
+	Episodes Kind = "episodes"
+)
+
+// Kinds lists every kind, in export order.
+var Kinds = []Kind{Movies, Shows, Seasons, Episodes}
+
+func ParseKind(s string) (Kind, error) {
+	for _, k := range Kinds {
+		if strings.EqualFold(s, string(k)) {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown kind %q (use movies, shows, seasons or episodes)", s)
+}
+
+// Field is an exported column.
+type Field struct {
+	Name  string
+	value func(v plex.Video) interface{} // string, int, int64, float64 or []string
+}
+
+func media(v plex.Video) plex.Media {
+	if len(v.Media) == 0 {
+		return plex.Media{}
+	}
+	return v.Media[0]
+}
+
+func tags(list []plex.Tag) []string {
+	out := make([]string, 0, len(list))
+	for _, t := range list {
+		out = append(out, t.Tag)
+	}
+	return out
+}
+
+// show and season place episodes and seasons within their show.
+func show(v plex.Video) interface{} {
+	if v.Type == "season" {
+		return v.ParentTitle
+	}
+	return v.GrandparentTitle
+}
+
+func season(v plex.Video) interface{} {
+	switch v.Type {
+	case "season":
+		return v.Index
+	case "episode":
+		return v.ParentIndex
+	}
+	return 0
+}
+
+// Fields lists every field, in their default order.
+var Fields = []Field{
+	{"type", func(v plex.Video) interface{} { return v.Type }},
+	{"ratingKey", func(v plex.Video) interface{} { return v.RatingKey }},
+	{"title", func(v plex.Video) interface{} { return v.Title }},
+	{"show", show},
+	{"season", season},
+	{"episode", func(v plex.Video) interface{} {
+		if v.Type != "episode" {
+			return 0
+		}
+		return v.Index
+	}},
+	{"year", func(v plex.Video) interface{} { return v.Year }},
+	{"duration", func(v plex.Video) interface{} { return v.Duration }},
+	{"resolution", func(v plex.Video) interface{} { return media(v).VideoResolution }},
+	{"videoCodec", func(v plex.Video) interface{} { return media(v).VideoCodec }},
+	{"audioCodec", func(v plex.Video) interface{} { return media(v).AudioCodec }},
+	{"audioChannels", func(v plex.Video) interface{} { return media(v).AudioChannels }},
+	{"genres", func(v plex.Video) interface{} { return tags(v.Genre) }},
+	{"directors", func(v plex.Video) interface{} { return tags(v.Director) }},
+	{"cast", func(v plex.Video) interface{} {
+		out := make([]string, 0, len(v.Role))
+		for _, r := range v.Role {
+			out = append(out, r.Tag)
+		}
+		return out
+	}},
+	{"studio", func(v plex.Video) interface{} { return v.Studio }},
+	{"contentRating", func(v plex.Video) interface{} { return v.ContentRating }},
+	{"rating", func(v plex.Video) interface{} { return v.Rating }},
+	{"released", func(v plex.Video) interface{} { return v.OriginallyAvailableAt }},
+	{"addedAt", func(v plex.Video) interface{} { return v.AddedAt }},
+	{"episodes", func(v plex.Video) interface{} { return v.LeafCount }},
+	{"viewCount", func(v plex.Video) interface{} { return v.ViewCount }},
+	{"viewOffset", func(v plex.Video) interface{} { return v.ViewOffset }},
+	{"lastViewedAt", func(v plex.Video) interface{} { return v.LastViewedAt }},
+	{"section", func(v plex.Video) interface{} { return v.LibrarySectionID }},
+	{"summary", func(v plex.Video) interface{} { return v.Summary }},
+}
+
+// DefaultFields are exported when none are chosen.
+var DefaultFields = []string{
+	"type", "ratingKey", "title", "show", "season", "episode", "year",
+	"resolution", "videoCodec", "audioCodec", "audioChannels", "genres",
+}
+
+// FieldNames lists the names of every field.
+func FieldNames() []string {
+	names := make([]string, 0, len(Fields))
+	for _, f := range Fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// ParseFields parses a comma-separated list of field names, matched without
+// regard to case. An empty list selects DefaultFields and "all" every field.
+func ParseFields(s string) ([]Field, error) {
+	var names []string
+	switch strings.TrimSpace(s) {
+	case "":
+		names = DefaultFields
+	case "all":
+		return Fields, nil
+	default:
+		names = strings.Split(s, ",")
+	}
+
+	fields := make([]Field, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, f := range Fields {
+			if strings.EqualFold(f.Name, name) {
+				fields = append(fields, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field %q (fields: %s)", name, strings.Join(FieldNames(), ", "))
+		}
+	}
+	return fields, nil
+}
+
+// Options select what Write exports.
+type Options struct {
+	Kinds   []Kind  // Every kind when empty
+	Fields  []Field // DefaultFields when empty
+	Section string  // Only this library section when set
+	// Match, when set, keeps the items it returns true for; see
+	// query.Parse for the filter language of the TUI.
+	Match func(plex.Video) bool
+}
+
+// Write exports the cached items selected by opts to w and returns how many
+// it wrote.
+func Write(w io.Writer, st *store.Store, format Format, opts Options) (int, error) {
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = Kinds
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		var err error
+		if fields, err = ParseFields(""); err != nil {
+			return 0, err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	enc, err := newEncoder(bw, format, fields)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, k := range kinds {
+		items, err := list(st, k, opts.Section)
+		if err != nil {
+			return n, fmt.Errorf("listing %s: %w", k, err)
+		}
+		for _, v := range items {
+			if opts.Match != nil && !opts.Match(v) {
+				continue
+			}
+			values := make([]interface{}, len(fields))
+			for i, f := range fields {
+				values[i] = f.value(v)
+			}
+			if err := enc.encode(values); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	if err := enc.close(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+func list(st *store.Store, k Kind, section string) ([]plex.Video, error) {
+	var items []plex.Video
+	var err error
+	switch k {
+	case Movies:
+		return st.ListLibrary("movie", section)
+	case Shows:
+		return st.ListLibrary("show", section)
+	case Seasons:
+		items, err = st.ListAllSeasons()
+	case Episodes:
+		items, err = st.ListAllEpisodes()
+	default:
+		return nil, fmt.Errorf("unknown kind %q", k)
+	}
+	if err != nil || section == "" {
+		return items, err
+	}
+	kept := items[:0]
+	for _, v := range items {
+		if v.LibrarySectionID == section {
+			kept = append(kept, v)
+		}
+	}
+	return kept, nil
+}
+
+// encoder writes rows of field values in one format.
+type encoder interface {
+	encode(values []interface{}) error
+	close() error
+}
+
+func newEncoder(w io.Writer, format Format, fields []Field) (encoder, error) {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	switch format {
+	case JSON:
+		return &jsonEncoder{w: w, names: names, array: true}, nil
+	case NDJSON:
+		return &jsonEncoder{w: w, names: names}, nil
+	case CSV:
+		cw := csv.NewWriter(w)
+		return &csvEncoder{w: cw}, cw.Write(names)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// jsonEncoder writes objects with their fields in the chosen order, as an
+// indented array or one per line.
+type jsonEncoder struct {
+	w     io.Writer
+	names []string
+	array bool
+	count int
+}
+
+func (e *jsonEncoder) encode(values []interface{}) error {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range e.names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		value, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+
+	prefix := ""
+	if e.array {
+		prefix = "[\n  "
+		if e.count > 0 {
+			prefix = ",\n  "
+		}
+	}
+	e.count++
+	suffix := ""
+	if !e.array {
+		suffix = "\n"
+	}
+	_, err := io.WriteString(e.w, prefix+b.String()+suffix)
+	return err
+}
+
+func (e *jsonEncoder) close() error {
+	if !e.array {
+		return nil
+	}
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) encode(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = csvValue(v)
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvValue formats a field value for a CSV cell. Lists are joined with
+// ", " as in the cache.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ", ")
+	}
+	return fmt.Sprint(v)
+}
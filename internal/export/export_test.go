@@ -0,0 +1,209 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/db"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+)
+
+// newTestStore returns a store over a fresh cache file holding two movies
+// and a show with two seasons.
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	d, err := db.Open("export-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	var n int
+	movies := []plex.Video{
+		{
+			RatingKey: "1", Title: "Heat", Year: 1995, Duration: 10_200_000, Rating: 8.3,
+			Genre: []plex.Tag{{Tag: "Crime"}, {Tag: "Thriller"}},
+			Role:  []plex.Role{{Tag: "Al Pacino", Role: "Vincent Hanna"}},
+			Media: []plex.Media{{VideoResolution: "1080", VideoCodec: "h264", AudioCodec: "dca", AudioChannels: 6}},
+		},
+		{
+			RatingKey: "2", Title: `Alien, "Director's Cut"`, Year: 1979, ViewCount: 1,
+			Genre: []plex.Tag{{Tag: "Horror"}},
+			Media: []plex.Media{{VideoResolution: "4k", VideoCodec: "hevc", AudioCodec: "truehd", AudioChannels: 8}},
+		},
+	}
+	steps := []error{
+		func() error {
+			_, err := d.Exec(`INSERT INTO sections (key, title, type, updated_at) VALUES ('1', 'Movies', 'movie', 0), ('2', 'TV', 'show', 0)`)
+			return err
+		}(),
+		cache.SaveMovies(d, "1", movies, &n, nil),
+		cache.SaveSeries(d, "2", []plex.Directory{{RatingKey: "10", Title: "The Expanse", Genre: []plex.Tag{{Tag: "Drama"}}}}, &n, nil),
+		cache.SaveSeasons(d, "10", []plex.Directory{{RatingKey: "11", Type: "season", Index: "1"}, {RatingKey: "12", Type: "season", Index: "2"}}, &n, nil),
+		cache.SaveEpisodes(d, "11", []plex.Video{
+			{RatingKey: "101", Title: "Dulcinea", Index: 1, ViewCount: 1, Media: []plex.Media{{VideoResolution: "1080", VideoCodec: "h264", AudioCodec: "eac3", AudioChannels: 6}}},
+			{RatingKey: "102", Title: "The Big Empty", Index: 2},
+		}, &n, nil),
+		cache.SaveEpisodes(d, "12", []plex.Video{{RatingKey: "201", Title: "Safe", Index: 1}}, &n, nil),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatalf("Failed to fill the cache: %v", err)
+		}
+	}
+	return store.New(d)
+}
+
+// decode reads an export back into rows of field name to CSV cell text.
+func decode(t *testing.T, format Format, data []byte) []map[string]string {
+	t.Helper()
+	var rows []map[string]string
+	fromJSON := func(obj map[string]interface{}) map[string]string {
+		row := map[string]string{}
+		for k, v := range obj {
+			switch v := v.(type) {
+			case []interface{}:
+				var list []string
+				for _, s := range v {
+					list = append(list, s.(string))
+				}
+				row[k] = strings.Join(list, ", ")
+			case float64:
+				row[k] = csvValue(v)
+			default:
+				row[k] = v.(string)
+			}
+		}
+		return row
+	}
+
+	switch format {
+	case JSON:
+		var objs []map[string]interface{}
+		if err := json.Unmarshal(data, &objs); err != nil {
+			t.Fatalf("Invalid JSON %q: %v", data, err)
+		}
+		for _, obj := range objs {
+			rows = append(rows, fromJSON(obj))
+		}
+	case NDJSON:
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		for sc.Scan() {
+			var obj map[string]interface{}
+			if err := json.Unmarshal(sc.Bytes(), &obj); err != nil {
+				t.Fatalf("Invalid NDJSON line %q: %v", sc.Text(), err)
+			}
+			rows = append(rows, fromJSON(obj))
+		}
+	case CSV:
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			t.Fatalf("Invalid CSV %q: %v", data, err)
+		}
+		for _, rec := range records[1:] {
+			row := map[string]string{}
+			for i, name := range records[0] {
+				row[name] = rec[i]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func TestWrite_RoundTrip(t *testing.T) {
+	st := newTestStore(t)
+	fields, err := ParseFields("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []map[string]string
+	for _, format := range []Format{JSON, NDJSON, CSV} {
+		var buf bytes.Buffer
+		n, err := Write(&buf, st, format, Options{Fields: fields})
+		if err != nil {
+			t.Fatalf("%s: Write failed: %v", format, err)
+		}
+		rows := decode(t, format, buf.Bytes())
+		if n != 8 || len(rows) != n {
+			t.Fatalf("%s: expected 8 items, wrote %d and read back %d", format, n, len(rows))
+		}
+		if want == nil {
+			want = rows
+		} else if !reflect.DeepEqual(rows, want) {
+			t.Errorf("%s: expected the same rows as JSON, got\n%v\nwant\n%v", format, rows, want)
+		}
+	}
+
+	alien := want[0]
+	for k, v := range map[string]string{
+		"type": "movie", "title": `Alien, "Director's Cut"`, "year": "1979", "resolution": "4k",
+		"videoCodec": "hevc", "audioCodec": "truehd", "audioChannels": "8", "genres": "Horror",
+		"viewCount": "1", "section": "1",
+	} {
+		if alien[k] != v {
+			t.Errorf("Expected %s %q for Alien, got %q", k, v, alien[k])
+		}
+	}
+	if heat := want[1]; heat["genres"] != "Crime, Thriller" || heat["cast"] != "Al Pacino" || heat["rating"] != "8.3" || heat["duration"] != "10200000" {
+		t.Errorf("Unexpected row for Heat: %v", heat)
+	}
+	if season := want[3]; season["type"] != "season" || season["show"] != "The Expanse" || season["season"] != "1" || season["episodes"] != "2" {
+		t.Errorf("Unexpected row for season 1: %v", season)
+	}
+	if ep := want[5]; ep["title"] != "Dulcinea" || ep["show"] != "The Expanse" || ep["season"] != "1" || ep["episode"] != "1" || ep["audioChannels"] != "6" {
+		t.Errorf("Unexpected row for the first episode: %v", ep)
+	}
+}
+
+func TestWrite_Selection(t *testing.T) {
+	st := newTestStore(t)
+	fields, err := ParseFields("Title, year")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := Write(&buf, st, CSV, Options{
+		Kinds:  []Kind{Movies, Episodes},
+		Fields: fields,
+		Match:  func(v plex.Video) bool { return v.ViewCount == 0 },
+	})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	want := "title,year\nHeat,1995\nThe Big Empty,0\nSafe,0\n"
+	if n != 3 || buf.String() != want {
+		t.Errorf("Expected %q, got %q (%d items)", want, buf.String(), n)
+	}
+
+	buf.Reset()
+	if n, err := Write(&buf, st, JSON, Options{Kinds: []Kind{Episodes}, Section: "1"}); err != nil || n != 0 || buf.String() != "[]\n" {
+		t.Errorf("Expected an empty array for episodes of a movie section, got %q (%d items, %v)", buf.String(), n, err)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields("")
+	if err != nil || len(fields) != len(DefaultFields) || fields[0].Name != "type" {
+		t.Errorf("Expected the default fields, got %v (%v)", fields, err)
+	}
+	if _, err := ParseFields("title,bitrate"); err == nil || !strings.Contains(err.Error(), `"bitrate"`) {
+		t.Errorf("Expected an unknown field error, got %v", err)
+	}
+	if f, ok := FormatOf("library.jsonl"); !ok || f != NDJSON {
+		t.Errorf("Expected ndjson for .jsonl, got %q", f)
+	}
+	if _, ok := FormatOf("library.xlsx"); ok {
+		t.Error("Expected no format for .xlsx")
+	}
+}
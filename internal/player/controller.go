@@ -0,0 +1,387 @@
+package player
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by commands sent once mpv has quit.
+var ErrClosed = errors.New("mpv is not running")
+
+// Event is a message mpv sent on its own: the change of an observed
+// property, or an event such as file-loaded or end-file.
+type Event struct {
+	Name            string      // "property-change", "file-loaded", "end-file", ...
+	Property        string      // Changed property, for property-change
+	Data            interface{} // New value, for property-change; nil when unavailable
+	Reason          string      // Why the file ended, for end-file: eof, stop, quit, error, ...
+	PlaylistEntryID int         // Entry starting or ending, for start-file and end-file
+}
+
+// Float returns the value of a numeric property change.
+func (e Event) Float() (float64, bool) {
+	v, ok := e.Data.(float64)
+	return v, ok
+}
+
+// Bool returns the value of a flag property change.
+func (e Event) Bool() (bool, bool) {
+	v, ok := e.Data.(bool)
+	return v, ok
+}
+
+// Controller drives a running mpv over its JSON IPC socket: commands are
+// sent with the typed methods or Command, and events reach subscribers.
+// It is safe for concurrent use.
+type Controller struct {
+	conn net.Conn
+	done chan struct{} // Closed once the connection is gone
+
+	mu       sync.Mutex
+	nextID   int
+	pending  map[int]chan reply
+	subs     map[*subscriber]struct{}
+	observed map[string]int         // Observe id of each property
+	values   map[string]interface{} // Last value of each observed property
+}
+
+type reply struct {
+	err  string
+	data interface{}
+}
+
+// Dial connects to the IPC socket of mpv, waiting for mpv to create it
+// until ctx is done.
+func Dial(ctx context.Context, socketPath string) (*Controller, error) {
+	var d net.Dialer
+	for {
+		conn, err := d.DialContext(ctx, "unix", socketPath)
+		if err == nil {
+			return NewController(conn), nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connecting to mpv: %w", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// NewController controls the mpv at the other end of conn.
+func NewController(conn net.Conn) *Controller {
+	c := &Controller{
+		conn:     conn,
+		done:     make(chan struct{}),
+		pending:  map[int]chan reply{},
+		subs:     map[*subscriber]struct{}{},
+		observed: map[string]int{},
+		values:   map[string]interface{}{},
+	}
+	go c.read()
+	return c
+}
+
+// Done is closed once mpv has quit or the connection was closed.
+func (c *Controller) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close drops the connection; mpv keeps running.
+func (c *Controller) Close() error {
+	return c.conn.Close()
+}
+
+// Command sends a raw mpv command, such as "set_property", "pause", true,
+// and returns its result.
+func (c *Controller) Command(args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return nil, ErrClosed
+	default:
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan reply, 1)
+	c.pending[id] = ch
+	data, err := json.Marshal(map[string]interface{}{"command": args, "request_id": id})
+	if err == nil {
+		_, err = c.conn.Write(append(data, '\n'))
+	}
+	if err != nil {
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != "success" {
+			return nil, fmt.Errorf("mpv %v: %s", args[0], r.err)
+		}
+		return r.data, nil
+	case <-c.done:
+		return nil, ErrClosed
+	}
+}
+
+// Seek moves playback by offset, backwards when negative.
+func (c *Controller) Seek(offset time.Duration) error {
+	_, err := c.Command("seek", offset.Seconds(), "relative")
+	return err
+}
+
+// SeekTo moves playback to pos from the start.
+func (c *Controller) SeekTo(pos time.Duration) error {
+	_, err := c.Command("seek", pos.Seconds(), "absolute")
+	return err
+}
+
+// SetPause pauses or resumes playback.
+func (c *Controller) SetPause(paused bool) error {
+	_, err := c.Command("set_property", "pause", paused)
+	return err
+}
+
+// CycleSubs switches to the next subtitle track, or off after the last.
+func (c *Controller) CycleSubs() error {
+	_, err := c.Command("cycle", "sub")
+	return err
+}
+
+// CycleAudio switches to the next audio track.
+func (c *Controller) CycleAudio() error {
+	_, err := c.Command("cycle", "audio")
+	return err
+}
+
+// SetAudioTrack selects the audio track with the given id, as numbered in
+// mpv's track-list.
+func (c *Controller) SetAudioTrack(id int) error {
+	_, err := c.Command("set_property", "aid", id)
+	return err
+}
+
+// ShowText shows text on mpv's OSD for d.
+func (c *Controller) ShowText(text string, d time.Duration) error {
+	_, err := c.Command("show-text", text, d.Milliseconds())
+	return err
+}
+
+// LoadOptions applies to a file loaded with LoadFile.
+type LoadOptions struct {
+	Start time.Duration // Position to start from
+	Title string        // Window and OSD title
+}
+
+// LoadFile replaces the current file with url. The options are set as
+// properties first, which works with every mpv version whatever the
+// arguments of loadfile.
+func (c *Controller) LoadFile(url string, opts LoadOptions) error {
+	start := "none"
+	if opts.Start > 0 {
+		start = fmt.Sprintf("%.2f", opts.Start.Seconds())
+	}
+	if _, err := c.Command("set_property", "start", start); err != nil {
+		return err
+	}
+	if opts.Title != "" {
+		if _, err := c.Command("set_property", "force-media-title", opts.Title); err != nil {
+			return err
+		}
+	}
+	_, err := c.Command("loadfile", url, "replace")
+	return err
+}
+
+// Quit makes mpv exit.
+func (c *Controller) Quit() error {
+	_, err := c.Command("quit")
+	if errors.Is(err, ErrClosed) {
+		return nil // Gone before answering
+	}
+	return err
+}
+
+// Observe asks mpv to report changes of the given properties as
+// property-change events, starting with their current value. Properties
+// already observed are skipped; Value has their last value.
+func (c *Controller) Observe(names ...string) error {
+	for _, name := range names {
+		c.mu.Lock()
+		if _, ok := c.observed[name]; ok {
+			c.mu.Unlock()
+			continue
+		}
+		id := len(c.observed) + 1
+		c.observed[name] = id
+		c.mu.Unlock()
+		if _, err := c.Command("observe_property", id, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value returns the last value reported for an observed property.
+func (c *Controller) Value(name string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[name]
+	return v, ok
+}
+
+// Subscribe returns the events mpv sends from now on, in order, until
+// cancel is called or mpv quits; the channel is closed then. Events wait
+// for slow subscribers rather than being dropped.
+func (c *Controller) Subscribe() (events <-chan Event, cancel func()) {
+	s := &subscriber{out: make(chan Event), wake: make(chan struct{}, 1), stop: make(chan struct{})}
+	c.mu.Lock()
+	c.subs[s] = struct{}{}
+	c.mu.Unlock()
+	go s.run(c.done)
+
+	var once sync.Once
+	return s.out, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			delete(c.subs, s)
+			c.mu.Unlock()
+			close(s.stop)
+		})
+	}
+}
+
+// read dispatches what mpv sends until the connection ends.
+func (c *Controller) read() {
+	defer func() {
+		c.mu.Lock()
+		close(c.done)
+		c.mu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024) // track-list can be long
+	for scanner.Scan() {
+		var msg struct {
+			RequestID       *int        `json:"request_id"`
+			Error           string      `json:"error"`
+			Data            interface{} `json:"data"`
+			Event           string      `json:"event"`
+			Name            string      `json:"name"`
+			Reason          string      `json:"reason"`
+			PlaylistEntryID int         `json:"playlist_entry_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if msg.Event == "" {
+			if msg.RequestID != nil {
+				if ch, ok := c.pending[*msg.RequestID]; ok {
+					delete(c.pending, *msg.RequestID)
+					ch <- reply{err: msg.Error, data: msg.Data}
+				}
+			}
+			c.mu.Unlock()
+			continue
+		}
+		ev := Event{Name: msg.Event, Reason: msg.Reason, PlaylistEntryID: msg.PlaylistEntryID}
+		if msg.Event == "property-change" {
+			ev.Property, ev.Data = msg.Name, msg.Data
+			c.values[msg.Name] = msg.Data
+		}
+		for s := range c.subs {
+			s.push(ev)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// subscriber queues the events of one Subscribe call, so a slow reader
+// holds up neither mpv's replies nor other subscribers.
+type subscriber struct {
+	out  chan Event
+	wake chan struct{}
+	stop chan struct{}
+
+	mu    sync.Mutex
+	queue []Event
+}
+
+func (s *subscriber) push(ev Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, ev)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) run(done <-chan struct{}) {
+	defer close(s.out)
+	for {
+		closed := false
+		select {
+		case <-s.wake:
+		case <-done:
+			closed = true
+		case <-s.stop:
+			return
+		}
+
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+		for _, ev := range queue {
+			select {
+			case s.out <- ev:
+			case <-s.stop:
+				return
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// SelectedTrack describes the selected track of a type ("audio" or "sub")
+// in the value of the track-list property, e.g. "eng (Commentary)"; empty
+// when none is.
+func SelectedTrack(trackList interface{}, typ string) string {
+	tracks, _ := trackList.([]interface{})
+	for _, t := range tracks {
+		track, _ := t.(map[string]interface{})
+		if track["type"] != typ || track["selected"] != true {
+			continue
+		}
+		var parts []string
+		if lang, _ := track["lang"].(string); lang != "" {
+			parts = append(parts, lang)
+		}
+		if title, _ := track["title"].(string); title != "" {
+			parts = append(parts, "("+title+")")
+		}
+		if len(parts) == 0 {
+			if id, ok := track["id"].(float64); ok {
+				return fmt.Sprintf("#%d", int(id))
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
@@ -0,0 +1,376 @@
+package player
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/config"
+)
+
+// fakeMPV answers commands on a Unix socket the way mpv does, reporting the
+// values of observed properties from Values.
+type fakeMPV struct {
+	Values map[string]interface{}
+
+	path string
+	ln   net.Listener
+
+	mu       sync.Mutex
+	conn     net.Conn
+	commands []string
+}
+
+func newFakeMPV(t *testing.T, values map[string]interface{}) *fakeMPV {
+	t.Helper()
+	f := &fakeMPV{Values: values, path: filepath.Join(t.TempDir(), "mpv.sock")}
+	ln, err := net.Listen("unix", f.path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	f.ln = ln
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeMPV) serve() {
+	conn, err := f.ln.Accept()
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	f.conn = conn
+	f.mu.Unlock()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req struct {
+			Command   []interface{} `json:"command"`
+			RequestID int           `json:"request_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, fmt.Sprint(req.Command))
+		f.mu.Unlock()
+
+		status := "success"
+		if req.Command[0] == "get_property" {
+			status = "property unavailable"
+		}
+		f.send(map[string]interface{}{"request_id": req.RequestID, "error": status})
+		if req.Command[0] == "observe_property" {
+			name := req.Command[2].(string)
+			f.send(map[string]interface{}{"event": "property-change", "id": req.Command[1], "name": name, "data": f.Values[name]})
+		}
+	}
+}
+
+// send writes a message to the client, as mpv would.
+func (f *fakeMPV) send(msg map[string]interface{}) {
+	data, _ := json.Marshal(msg)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conn.Write(append(data, '\n'))
+}
+
+// quit drops the connection like an exiting mpv.
+func (f *fakeMPV) quit() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conn.Close()
+}
+
+func (f *fakeMPV) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func dialFake(t *testing.T, f *fakeMPV) *Controller {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, f.path)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestController_Commands(t *testing.T) {
+	f := newFakeMPV(t, nil)
+	c := dialFake(t, f)
+
+	calls := []func() error{
+		func() error { return c.SetPause(true) },
+		func() error { return c.Seek(10 * time.Second) },
+		func() error { return c.Seek(-5 * time.Second) },
+		func() error { return c.SeekTo(90 * time.Second) },
+		c.CycleSubs,
+		c.CycleAudio,
+		func() error { return c.SetAudioTrack(2) },
+		func() error { return c.ShowText("Next up", 1500*time.Millisecond) },
+		func() error {
+			return c.LoadFile("/tmp/pilot.mkv", LoadOptions{Start: 90 * time.Second, Title: "Pilot"})
+		},
+		func() error { return c.LoadFile("/tmp/next.mkv", LoadOptions{}) },
+		c.Quit,
+	}
+	for i, call := range calls {
+		if err := call(); err != nil {
+			t.Fatalf("Call %d failed: %v", i, err)
+		}
+	}
+	want := []string{
+		"[set_property pause true]",
+		"[seek 10 relative]",
+		"[seek -5 relative]",
+		"[seek 90 absolute]",
+		"[cycle sub]",
+		"[cycle audio]",
+		"[set_property aid 2]",
+		"[show-text Next up 1500]",
+		"[set_property start 90.00]",
+		"[set_property force-media-title Pilot]",
+		"[loadfile /tmp/pilot.mkv replace]",
+		"[set_property start none]",
+		"[loadfile /tmp/next.mkv replace]",
+		"[quit]",
+	}
+	if got := f.Commands(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected commands\n%v\ngot\n%v", want, got)
+	}
+
+	if _, err := c.Command("get_property", "chapter"); err == nil || !strings.Contains(err.Error(), "property unavailable") {
+		t.Errorf("Expected mpv's error, got %v", err)
+	}
+}
+
+func TestController_Events(t *testing.T) {
+	f := newFakeMPV(t, map[string]interface{}{"pause": false})
+	c := dialFake(t, f)
+
+	first, _ := c.Subscribe()
+	second, cancel := c.Subscribe()
+	cancel()
+	if _, ok := <-second; ok {
+		t.Error("Expected a cancelled subscription to be closed")
+	}
+
+	if err := c.Observe("pause", "pause"); err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+	f.send(map[string]interface{}{"event": "file-loaded"})
+	f.send(map[string]interface{}{"event": "end-file", "reason": "eof", "playlist_entry_id": 3})
+	f.quit()
+
+	var got []Event
+	for ev := range first {
+		got = append(got, ev)
+	}
+	want := []Event{
+		{Name: "property-change", Property: "pause", Data: false},
+		{Name: "file-loaded"},
+		{Name: "end-file", Reason: "eof", PlaylistEntryID: 3},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+	if n := len(f.Commands()); n != 1 {
+		t.Errorf("Expected pause observed once, got %d commands", n)
+	}
+	if v, ok := c.Value("pause"); !ok || v != false {
+		t.Errorf("Expected pause to be false, got %v", v)
+	}
+
+	<-c.Done()
+	if err := c.SetPause(true); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed once mpv quit, got %v", err)
+	}
+	if err := c.Quit(); err != nil {
+		t.Errorf("Expected quitting a gone mpv to succeed, got %v", err)
+	}
+}
+
+type fakeReporter struct {
+	delay time.Duration // Of each progress report, like a slow server
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *fakeReporter) ReportProgress(key string, timeMs int64, durationMs int64, state string) error {
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, fmt.Sprintf("progress %s %d/%d %s", key, timeMs, durationMs, state))
+	return nil
+}
+
+func (r *fakeReporter) Scrobble(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, "scrobble "+key)
+	return nil
+}
+
+func (r *fakeReporter) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// runMonitor monitors c from item 42, returning what ended and the keys
+// loaded next.
+func runMonitor(c *Controller, r Reporter) (<-chan Result, chan<- string) {
+	ended := make(chan Result)
+	loading := make(chan string, 1)
+	go func() {
+		monitor(c, "42", loading, r, ended)
+		close(ended)
+	}()
+	return ended, loading
+}
+
+// waitObserved waits for mpv to answer the monitor's observe commands.
+func waitObserved(c *Controller) {
+	// pause is observed last
+	for _, ok := c.Value("pause"); !ok; _, ok = c.Value("pause") {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	tests := []struct {
+		position  float64
+		completed bool
+		final     string
+	}{
+		{95, true, "scrobble 42"},
+		{30, false, "progress 42 30000/100000 stopped"},
+	}
+	for _, tt := range tests {
+		f := newFakeMPV(t, map[string]interface{}{"time-pos": tt.position, "duration": 100.0, "pause": true})
+		c := dialFake(t, f)
+		r := &fakeReporter{delay: 20 * time.Millisecond}
+
+		ended, _ := runMonitor(c, r)
+		waitObserved(c)
+		f.quit()
+
+		if got := <-ended; got != (Result{RatingKey: "42", Completed: tt.completed}) {
+			t.Errorf("At %v: expected completed %v, got %+v", tt.position, tt.completed, got)
+		}
+		if _, ok := <-ended; ok {
+			t.Errorf("At %v: expected one result", tt.position)
+		}
+		// The pause, reported in the background, is sent before the end
+		paused := fmt.Sprintf("progress 42 %d/100000 paused", int(tt.position*1000))
+		if got := r.Calls(); fmt.Sprint(got) != fmt.Sprint([]string{paused, tt.final}) {
+			t.Errorf("At %v: expected %q then %q, got %v", tt.position, paused, tt.final, got)
+		}
+	}
+}
+
+func TestMonitor_Load(t *testing.T) {
+	f := newFakeMPV(t, map[string]interface{}{"time-pos": 95.0, "duration": 100.0, "pause": false})
+	c := dialFake(t, f)
+	r := &fakeReporter{}
+
+	ended, loading := runMonitor(c, r)
+	waitObserved(c)
+
+	// The first item plays to the end and mpv stays open
+	f.send(map[string]interface{}{"event": "end-file", "reason": "eof"})
+	if got := <-ended; got != (Result{RatingKey: "42", Completed: true}) {
+		t.Errorf("Expected 42 completed, got %+v", got)
+	}
+
+	// The next one is loaded and stopped early
+	loading <- "43"
+	f.send(map[string]interface{}{"event": "start-file", "playlist_entry_id": 2})
+	f.send(map[string]interface{}{"event": "file-loaded"})
+	f.send(map[string]interface{}{"event": "property-change", "name": "duration", "data": 200.0})
+	f.send(map[string]interface{}{"event": "property-change", "name": "time-pos", "data": 20.0})
+	f.quit()
+	if got := <-ended; got != (Result{RatingKey: "43"}) {
+		t.Errorf("Expected 43 not completed, got %+v", got)
+	}
+	if _, ok := <-ended; ok {
+		t.Error("Expected no more results once mpv quit")
+	}
+
+	got := r.Calls()
+	for _, want := range []string{"scrobble 42", "progress 43 20000/200000 stopped"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("Expected %q, got %v", want, got)
+		}
+	}
+}
+
+func TestSession_Load(t *testing.T) {
+	f := newFakeMPV(t, nil)
+	cfg := config.Defaults()
+	cfg.Plex.Token = "secret"
+	s := &Session{Controller: dialFake(t, f), cfg: cfg, loading: make(chan string, 1)}
+
+	if err := s.Load("Pilot", "http://pms:32400/library/parts/1/file.mkv", "42", 0); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Loaded again before mpv started it
+	if err := s.Load("Episode 2", "/data/episode2.mkv", "43", 90_000); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if key := <-s.loading; key != "43" {
+		t.Errorf("Expected the last item loaded, got %s", key)
+	}
+	want := []string{
+		"[set_property title Pilot]",
+		"[set_property start none]",
+		"[set_property force-media-title Pilot]",
+		"[loadfile http://pms:32400/library/parts/1/file.mkv?X-Plex-Token=secret replace]",
+		"[set_property title Episode 2]",
+		"[set_property start 90.00]",
+		"[set_property force-media-title Episode 2]",
+		"[loadfile /data/episode2.mkv replace]",
+	}
+	if got := f.Commands(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected commands\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestProgressQueue(t *testing.T) {
+	r := &fakeReporter{delay: 20 * time.Millisecond}
+	q := &progressQueue{p: r}
+
+	// Reported faster than the server answers
+	for i := 1; i <= 5; i++ {
+		q.report("42", int64(i*1000), 100000, "playing")
+	}
+	q.wait()
+	q.report("42", 6000, 100000, "paused")
+	q.wait()
+
+	got := r.Calls()
+	if len(got) < 2 || len(got) > 3 {
+		t.Fatalf("Expected the waiting reports to be coalesced, got %v", got)
+	}
+	// The first may be replaced before it is picked up
+	want := []string{"progress 42 5000/100000 playing", "progress 42 6000/100000 paused"}
+	if tail := got[len(got)-2:]; fmt.Sprint(tail) != fmt.Sprint(want) {
+		t.Errorf("Expected the latest reports last and in order, got %v", got)
+	}
+}
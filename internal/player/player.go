@@ -1,23 +1,68 @@
 package player
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Waddenn/plex-client/internal/config"
-	"github.com/Waddenn/plex-client/internal/plex"
 )
 
-func Play(title, url string, ratingKey string, startTimeMs int64, cfg *config.Config, pClient *plex.Client, extraArgs ...string) (bool, error) {
-	fullURL := fmt.Sprintf("%s?X-Plex-Token=%s", url, cfg.Plex.Token)
+// Reporter receives playback progress. *plex.Client satisfies it.
+type Reporter interface {
+	ReportProgress(key string, timeMs int64, durationMs int64, state string) error
+	Scrobble(key string) error
+}
+
+// Play runs mpv on url, a stream of the server or a downloaded file, and
+// reports the progress of ratingKey to reporter. It returns whether the
+// item was watched to the end.
+func Play(title, url string, ratingKey string, startTimeMs int64, cfg *config.Config, reporter Reporter, extraArgs ...string) (bool, error) {
+	s, err := Start(title, url, ratingKey, startTimeMs, cfg, reporter, extraArgs...)
+	if err != nil {
+		return false, err
+	}
+	r, ok := <-s.Ended()
+	if ok {
+		// mpv stays open once the item ended
+		s.Quit()
+	}
+	if err := s.Wait(); err != nil {
+		return false, err
+	}
+	return r.Completed, nil
+}
+
+// Result tells how playback of an item ended.
+type Result struct {
+	RatingKey string
+	Completed bool // Watched to the end
+}
 
+// Session is a running mpv. It stays open once an item ended, so that the
+// next one can be played in the same window with Load, until Quit.
+type Session struct {
+	// Controller drives mpv; nil when its IPC socket could not be reached,
+	// in which case progress is not reported either
+	Controller *Controller
+
+	cfg     *config.Config
+	cmd     *exec.Cmd
+	exited  chan struct{} // Closed once mpv exited, with err set
+	err     error
+	ended   chan Result
+	loading chan string // Rating key of the item loaded next
+	cleanup func()
+}
+
+// Start launches mpv like Play and returns once it can be controlled,
+// leaving playback running. Read how items end from Ended, then call Wait.
+func Start(title, url string, ratingKey string, startTimeMs int64, cfg *config.Config, reporter Reporter, extraArgs ...string) (*Session, error) {
 	// Create a temporary IPC socket path
 	ipcSocket := filepath.Join(os.TempDir(), fmt.Sprintf("plex-mpv-%d.sock", time.Now().UnixNano()))
 
@@ -42,9 +87,22 @@ func Play(title, url string, ratingKey string, startTimeMs int64, cfg *config.Co
 		args = append(args, fmt.Sprintf("--start=%.2f", seconds))
 	}
 
+	s := &Session{
+		cfg:     cfg,
+		exited:  make(chan struct{}),
+		ended:   make(chan Result),
+		loading: make(chan string, 1),
+	}
+	cleanups := []func(){func() { os.Remove(ipcSocket) }}
+	s.cleanup = func() {
+		for _, f := range cleanups {
+			f()
+		}
+	}
+
 	// Setup ModernX environment if available
 	if cfgPath, ok := setupModernX(); ok {
-		defer os.RemoveAll(cfgPath)
+		cleanups = append(cleanups, func() { os.RemoveAll(cfgPath) })
 		args = append(args, fmt.Sprintf("--config-dir=%s", cfgPath))
 	}
 
@@ -57,28 +115,125 @@ func Play(title, url string, ratingKey string, startTimeMs int64, cfg *config.Co
 	}
 
 	args = append(args, extraArgs...)
-	args = append(args, fullURL)
+	args = append(args, s.streamURL(url))
 
-	cmd := exec.Command("mpv", args...)
+	s.cmd = exec.Command("mpv", args...)
+	if err := s.cmd.Start(); err != nil {
+		s.cleanup()
+		return nil, fmt.Errorf("mpv failed: %w", err)
+	}
 
-	// Start monitoring routine
-	doneCh := make(chan bool)
-	go monitorProgress(ipcSocket, ratingKey, pClient, doneCh)
+	// Stop waiting for the socket if mpv exits first
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() {
+		s.err = s.cmd.Wait()
+		close(s.exited)
+		cancel()
+	}()
 
-	err := cmd.Run()
+	ctrl, err := Dial(ctx, ipcSocket)
+	if err != nil {
+		// Nothing to follow: the item ends with mpv
+		go func() {
+			<-s.exited
+			s.ended <- Result{RatingKey: ratingKey}
+			close(s.ended)
+		}()
+		return s, nil
+	}
+	s.Controller = ctrl
+	go func() {
+		monitor(ctrl, ratingKey, s.loading, reporter, s.ended)
+		close(s.ended)
+	}()
+	return s, nil
+}
+
+// streamURL authenticates url with the server token, unless it is a file.
+func (s *Session) streamURL(url string) string {
+	if isLocal(url) {
+		return url
+	}
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&" // Transcode URLs already carry a query string
+	}
+	return fmt.Sprintf("%s%sX-Plex-Token=%s", url, sep, s.cfg.Plex.ServerToken())
+}
 
-	// Wait for monitor to decide if we finished
-	completed := <-doneCh
+// Ended delivers how each item ended, in order, and is closed once mpv
+// exited. It must be read until then, unless Wait is called.
+func (s *Session) Ended() <-chan Result {
+	return s.ended
+}
 
-	if err != nil {
-		return false, fmt.Errorf("mpv failed: %w", err)
+// Running reports whether mpv has not exited yet.
+func (s *Session) Running() bool {
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// Load plays url in place of the current item, or once the last one ended,
+// keeping the window, the volume and the selected tracks. Progress is
+// reported for ratingKey from the moment mpv starts it.
+func (s *Session) Load(title, url string, ratingKey string, startTimeMs int64) error {
+	if s.Controller == nil {
+		return ErrClosed
+	}
+	// Replace a key mpv did not get to load
+	select {
+	case <-s.loading:
+	default:
+	}
+	s.loading <- ratingKey
+	if _, err := s.Controller.Command("set_property", "title", title); err != nil {
+		return err
+	}
+	return s.Controller.LoadFile(s.streamURL(url), LoadOptions{
+		Start: time.Duration(startTimeMs) * time.Millisecond,
+		Title: title,
+	})
+}
+
+// Quit makes mpv exit, ending the current item.
+func (s *Session) Quit() error {
+	if s.Controller == nil {
+		return s.cmd.Process.Signal(os.Interrupt)
 	}
-	return completed, nil
+	return s.Controller.Quit()
+}
+
+// Wait waits for mpv to exit, dropping the results not read from Ended.
+func (s *Session) Wait() error {
+	<-s.exited
+	// Wait for monitor to report the last item
+	for range s.ended {
+	}
+	if s.Controller != nil {
+		s.Controller.Close()
+	}
+	s.cleanup()
+
+	if s.err != nil {
+		return fmt.Errorf("mpv failed: %w", s.err)
+	}
+	return nil
+}
+
+// isLocal reports whether url is a file on disk rather than a stream.
+func isLocal(url string) bool {
+	return !strings.Contains(url, "://")
 }
 
 func baseArgs(title, ipcSocket string) []string {
 	return []string{
 		"--force-window=yes",
+		"--idle=yes", // Stay open for the next item of the queue
 		"--fullscreen",
 		"--target-colorspace-hint", // Essential for fixing faded colors
 		"--panscan=1.0",            // Scaling: Fill screen by cropping black bars
@@ -209,89 +364,139 @@ func setupModernX() (string, bool) {
 	return tmpDir, true
 }
 
-func monitorProgress(socketPath string, ratingKey string, p *plex.Client, doneCh chan<- bool) {
-	// Default to false
-	finalStatus := false
-	defer func() { doneCh <- finalStatus }()
-
-	// Wait for socket to be created
-	for i := 0; i < 20; i++ {
-		if _, err := os.Stat(socketPath); err == nil {
-			break
-		}
-		time.Sleep(500 * time.Millisecond)
-	}
-
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
+// monitor reports the progress of the items mpv plays to p, starting with
+// ratingKey and switching to the next key from loading when mpv starts a
+// new file. How each item ended is sent to ended, until mpv quits.
+func monitor(c *Controller, ratingKey string, loading <-chan string, p Reporter, ended chan<- Result) {
+	events, cancel := c.Subscribe()
+	defer cancel()
+	if err := c.Observe("time-pos", "duration", "pause"); err != nil {
+		ended <- Result{RatingKey: ratingKey}
 		return
 	}
-	defer conn.Close()
 
-	// Observe properties
-	sendIPC(conn, []interface{}{"observe_property", 1, "time-pos"})
-	sendIPC(conn, []interface{}{"observe_property", 2, "duration"})
-	sendIPC(conn, []interface{}{"observe_property", 3, "pause"})
-
-	scanner := bufio.NewScanner(conn)
 	var duration float64
 	var currentTime float64
 	var paused bool
+	active := true // An item is loaded or loading
 	lastReport := time.Now()
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		var event struct {
-			Event string      `json:"event"`
-			Name  string      `json:"name"`
-			Data  interface{} `json:"data"`
-		}
-		if err := json.Unmarshal(line, &event); err != nil {
-			continue
+	reports := &progressQueue{p: p}
+
+	// finish reports where the item stopped, scrobbling it when watched
+	// far enough, after the reports still on their way
+	finish := func() {
+		reports.wait()
+		completed := false
+		if duration > 0 && currentTime > 0 && (currentTime/duration) > 0.90 {
+			p.Scrobble(ratingKey)
+			completed = true
+		} else if duration > 0 {
+			// Report point where we stopped
+			p.ReportProgress(ratingKey, int64(currentTime*1000), int64(duration*1000), "stopped")
 		}
+		ended <- Result{RatingKey: ratingKey, Completed: completed}
+		active, duration, currentTime = false, 0, 0
+	}
 
-		if event.Event == "property-change" {
-			switch event.Name {
+	for event := range events {
+		switch event.Name {
+		case "start-file", "file-loaded":
+			// The file given to Load starts; a file failing to load ends
+			// without file-loaded
+			select {
+			case key := <-loading:
+				ratingKey = key
+			default:
+			}
+			active = true
+		case "end-file":
+			if active {
+				finish()
+			}
+		case "property-change":
+			switch event.Property {
 			case "duration":
-				if v, ok := event.Data.(float64); ok {
+				if v, ok := event.Float(); ok {
 					duration = v
 				}
 			case "time-pos":
-				if v, ok := event.Data.(float64); ok {
+				if v, ok := event.Float(); ok {
 					currentTime = v
 				}
 			case "pause":
-				if v, ok := event.Data.(bool); ok {
+				if v, ok := event.Bool(); ok {
 					paused = v
 					state := "playing"
 					if paused {
 						state = "paused"
 					}
 					// Report immediate state change
-					go p.ReportProgress(ratingKey, int64(currentTime*1000), int64(duration*1000), state)
+					if active {
+						reports.report(ratingKey, int64(currentTime*1000), int64(duration*1000), state)
+					}
 					lastReport = time.Now()
 				}
 			}
+		}
 
-			// Report every 10 seconds if playing
-			if !paused && duration > 0 && currentTime > 0 && time.Since(lastReport) > 10*time.Second {
-				go p.ReportProgress(ratingKey, int64(currentTime*1000), int64(duration*1000), "playing")
-				lastReport = time.Now()
-			}
+		// Report every 10 seconds if playing
+		if active && !paused && duration > 0 && currentTime > 0 && time.Since(lastReport) > 10*time.Second {
+			reports.report(ratingKey, int64(currentTime*1000), int64(duration*1000), "playing")
+			lastReport = time.Now()
 		}
 	}
 
 	// When loop ends (mpv closed), check if we watched enough to scrobble
-	if duration > 0 && currentTime > 0 && (currentTime/duration) > 0.90 {
-		p.Scrobble(ratingKey)
-		finalStatus = true
-	} else if duration > 0 {
-		// Report point where we stopped
-		p.ReportProgress(ratingKey, int64(currentTime*1000), int64(duration*1000), "stopped")
+	if active {
+		finish()
+	}
+}
+
+// progressQueue sends progress reports in the background one at a time, in
+// order, so that a slow server neither piles them up nor gets an older
+// position last. While one is sent, only the latest of the next waits.
+type progressQueue struct {
+	p Reporter
+
+	mu      sync.Mutex
+	next    func()        // Latest report waiting
+	running bool          // A report is being sent
+	idle    chan struct{} // Closed once the running reports are sent
+}
+
+func (q *progressQueue) report(key string, timeMs, durationMs int64, state string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.next = func() { q.p.ReportProgress(key, timeMs, durationMs, state) }
+	if !q.running {
+		q.running = true
+		q.idle = make(chan struct{})
+		go q.run()
+	}
+}
+
+func (q *progressQueue) run() {
+	for {
+		q.mu.Lock()
+		send := q.next
+		q.next = nil
+		if send == nil {
+			q.running = false
+			close(q.idle)
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+		send()
 	}
 }
 
-func sendIPC(conn net.Conn, cmd []interface{}) {
-	data, _ := json.Marshal(map[string]interface{}{"command": cmd})
-	conn.Write(append(data, '\n'))
+// wait waits for the queued reports to be sent.
+func (q *progressQueue) wait() {
+	q.mu.Lock()
+	idle, running := q.idle, q.running
+	q.mu.Unlock()
+	if running {
+		<-idle
+	}
 }
@@ -0,0 +1,158 @@
+package plex
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultProbeTimeout bounds each /identity request of ProbeConnections.
+const DefaultProbeTimeout = 3 * time.Second
+
+// SetConnections sets the URIs the server can be reached at, in order of
+// preference. When a request to the current URL fails to connect, the
+// client moves on to the next URI and keeps using it.
+func (c *Client) SetConnections(uris []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connections = append([]string(nil), uris...)
+}
+
+// URL returns the server URL currently in use.
+func (c *Client) URL() string {
+	return c.baseURL()
+}
+
+func (c *Client) baseURL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.BaseURL
+}
+
+func (c *Client) connectionCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.connections)
+}
+
+// failover switches away from failed, the URL a request could not reach,
+// and returns the URL to retry with. It returns "" when there is no other
+// connection to try.
+func (c *Client) failover(failed string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !sameURL(c.BaseURL, failed) {
+		return c.BaseURL // Another request already moved on
+	}
+	if len(c.connections) < 2 {
+		return ""
+	}
+	next := c.connections[0]
+	for i, u := range c.connections {
+		if sameURL(u, failed) {
+			next = c.connections[(i+1)%len(c.connections)]
+			break
+		}
+	}
+	if sameURL(next, failed) {
+		return ""
+	}
+	c.BaseURL = next
+	return next
+}
+
+func sameURL(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+// rebase points req at base, keeping its path and query.
+func rebase(req *http.Request, base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	req.Host = ""
+	return nil
+}
+
+// ProbeConnections requests /identity on every URI in parallel and returns
+// the most preferred one that answers, uris being in order of preference.
+// A non-empty machineID also rejects servers with another identity, such as
+// a LAN address now taken by a different machine.
+func (c *Client) ProbeConnections(ctx context.Context, uris []string, machineID string, timeout time.Duration) (string, error) {
+	if len(uris) == 0 {
+		return "", fmt.Errorf("no connections to probe")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops probes still running once the answer is known
+
+	type result struct {
+		idx int
+		err error
+	}
+	results := make(chan result, len(uris))
+	for i, u := range uris {
+		go func(i int, u string) {
+			results <- result{idx: i, err: c.probe(ctx, u, machineID, timeout)}
+		}(i, u)
+	}
+
+	done := make([]bool, len(uris))
+	errs := make([]error, len(uris))
+	for range uris {
+		r := <-results
+		done[r.idx] = true
+		errs[r.idx] = r.err
+
+		// The answer is known once every preferred URI has finished
+		for i := range uris {
+			if !done[i] {
+				break
+			}
+			if errs[i] == nil {
+				return uris[i], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no connection reachable: %w", errs[0])
+}
+
+// probe checks a single URI. It bypasses Do so an unreachable URI fails
+// fast instead of being retried.
+func (c *Client) probe(ctx context.Context, base, machineID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(base, "/")+"/identity", nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("identity of %s: status %d", base, resp.StatusCode)
+	}
+
+	var mc MediaContainer
+	if err := xml.NewDecoder(resp.Body).Decode(&mc); err != nil {
+		return fmt.Errorf("identity of %s: %w", base, err)
+	}
+	if machineID != "" && mc.MachineIdentifier != machineID {
+		return fmt.Errorf("%s is server %s, not %s", base, mc.MachineIdentifier, machineID)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package plex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+)
+
+// closedURL returns the URL of a server that no longer accepts connections.
+func closedURL() string {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+func identityServer(machineID string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/identity" {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(delay)
+		fmt.Fprintf(w, `<MediaContainer machineIdentifier="%s" />`, machineID)
+	}))
+}
+
+func TestDo_FailsOverToNextConnection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<MediaContainer><Directory key="1" title="Movies" /></MediaContainer>`)
+	}))
+	defer srv.Close()
+
+	dead := closedURL()
+	c := New(dead, "token", "client-id", appinfo.Default())
+	c.SetConnections([]string{dead, srv.URL})
+
+	start := time.Now()
+	sections, err := c.GetSections()
+	if err != nil {
+		t.Fatalf("GetSections failed: %v", err)
+	}
+	if len(sections) != 1 {
+		t.Errorf("Expected 1 section, got %d", len(sections))
+	}
+	if c.URL() != srv.URL {
+		t.Errorf("Expected the client to keep using %s, got %s", srv.URL, c.URL())
+	}
+	// Switching connections does not wait for the retry backoff.
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("Expected an immediate failover, took %v", elapsed)
+	}
+}
+
+// countingTransport counts the requests sent to each host.
+type countingTransport struct {
+	mu    sync.Mutex
+	hosts map[string]int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.hosts[req.URL.Host]++
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestForEachSectionPage_FailsOverOnce(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := r.Header.Get("X-Plex-Container-Start")
+		fmt.Fprintf(w, `<MediaContainer totalSize="3"><Video ratingKey="%s" title="Movie" /></MediaContainer>`, start)
+	}))
+	defer srv.Close()
+
+	dead := closedURL()
+	c := New(dead, "token", "client-id", appinfo.Default())
+	c.SetConnections([]string{dead, srv.URL})
+	transport := &countingTransport{hosts: map[string]int{}}
+	c.Client.Transport = transport
+
+	pages := 0
+	err := c.ForEachSectionPage(context.Background(), "1", 1, func(*MediaContainer) error {
+		pages++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachSectionPage failed: %v", err)
+	}
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	// Only the first page tries the unreachable URL
+	if n := transport.hosts[strings.TrimPrefix(dead, "http://")]; n != 1 {
+		t.Errorf("Expected 1 request to the unreachable URL, got %d", n)
+	}
+}
+
+func TestDo_NoOtherConnection(t *testing.T) {
+	dead := closedURL()
+	c := New(dead, "token", "client-id", appinfo.Default())
+	c.SetConnections([]string{dead})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetSectionsContext(ctx); err == nil {
+		t.Fatal("Expected an error without a reachable connection")
+	}
+	if c.URL() != dead {
+		t.Errorf("Expected the URL to stay %s, got %s", dead, c.URL())
+	}
+}
+
+func TestProbeConnections(t *testing.T) {
+	slow := identityServer("server-a", 100*time.Millisecond)
+	defer slow.Close()
+	fast := identityServer("server-a", 0)
+	defer fast.Close()
+	other := identityServer("server-b", 0)
+	defer other.Close()
+	dead := closedURL()
+
+	c := New("", "token", "client-id", appinfo.Default())
+	ctx := context.Background()
+
+	// The preferred reachable URI wins even when a later one answers first.
+	got, err := c.ProbeConnections(ctx, []string{dead, slow.URL, fast.URL}, "server-a", time.Second)
+	if err != nil || got != slow.URL {
+		t.Errorf("Expected %s, got %q (%v)", slow.URL, got, err)
+	}
+
+	// A URI answering for another server is skipped.
+	got, err = c.ProbeConnections(ctx, []string{other.URL, fast.URL}, "server-a", time.Second)
+	if err != nil || got != fast.URL {
+		t.Errorf("Expected %s, got %q (%v)", fast.URL, got, err)
+	}
+
+	if _, err := c.ProbeConnections(ctx, []string{dead, other.URL}, "server-a", time.Second); err == nil {
+		t.Error("Expected an error when no URI is the server")
+	}
+
+	// A slow URI is given up on after the timeout.
+	got, err = c.ProbeConnections(ctx, []string{slow.URL, fast.URL}, "server-a", 20*time.Millisecond)
+	if err != nil || got != fast.URL {
+		t.Errorf("Expected %s after the timeout, got %q (%v)", fast.URL, got, err)
+	}
+}
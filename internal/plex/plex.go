@@ -1,23 +1,29 @@
 package plex
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Waddenn/plex-client/internal/appinfo"
 )
 
 type Client struct {
-	BaseURL           string
+	BaseURL           string // Server URL in use; changes on failover, read it with URL
 	Token             string
 	MachineIdentifier string
 	Headers           map[string]string
 	Client            *http.Client
+
+	mu          sync.RWMutex // Guards BaseURL and connections
+	connections []string     // Alternative server URLs, see SetConnections
 }
 
 func New(baseURL, token, clientIdentifier string, info appinfo.Info) *Client {
@@ -38,6 +44,8 @@ func New(baseURL, token, clientIdentifier string, info appinfo.Info) *Client {
 
 type MediaContainer struct {
 	MachineIdentifier string      `xml:"machineIdentifier,attr"`
+	Offset            int         `xml:"offset,attr"`
+	TotalSize         int         `xml:"totalSize,attr"` // Set on paginated responses
 	Directories       []Directory `xml:"Directory"`
 	Videos            []Video     `xml:"Video"`
 }
@@ -59,6 +67,11 @@ type Directory struct {
 	Role          []Role  `xml:"Role"`
 	UpdatedAt     int64   `xml:"updatedAt,attr"`
 	AddedAt       int64   `xml:"addedAt,attr"`
+
+	LeafCount       int `xml:"leafCount,attr"`       // Episodes of a season or show
+	ViewedLeafCount int `xml:"viewedLeafCount,attr"` // Watched ones among them
+
+	LibrarySectionID string `xml:"librarySectionID,attr"`
 }
 
 type Video struct {
@@ -76,8 +89,12 @@ type Video struct {
 	OriginallyAvailableAt string  `xml:"originallyAvailableAt,attr"`
 	Type                  string  `xml:"type,attr"`
 	GrandparentTitle      string  `xml:"grandparentTitle,attr"`
+	ParentTitle           string  `xml:"parentTitle,attr"`
+	LeafCount             int     `xml:"leafCount,attr"`       // Episodes of a season or show
+	ViewedLeafCount       int     `xml:"viewedLeafCount,attr"` // Watched ones among them
 	ViewOffset            int     `xml:"viewOffset,attr"`
 	ViewCount             int     `xml:"viewCount,attr"`
+	LastViewedAt          int64   `xml:"lastViewedAt,attr"`
 	Studio                string  `xml:"studio,attr"`
 	ContentRating         string  `xml:"contentRating,attr"`
 	Media                 []Media `xml:"Media"`
@@ -87,6 +104,7 @@ type Video struct {
 	Role                  []Role  `xml:"Role"`
 	AddedAt               int64   `xml:"addedAt,attr"`
 	UpdatedAt             int64   `xml:"updatedAt,attr"`
+	LibrarySectionID      string  `xml:"librarySectionID,attr"`
 }
 
 type Media struct {
@@ -95,6 +113,7 @@ type Media struct {
 	AudioCodec      string `xml:"audioCodec,attr"`
 	AudioChannels   int    `xml:"audioChannels,attr"`
 	AspectRatio     string `xml:"aspectRatio,attr"`
+	Bitrate         int    `xml:"bitrate,attr"` // kbps
 	Part            []Part `xml:"Part"`
 }
 
@@ -111,8 +130,43 @@ type Role struct {
 	Role string `xml:"role,attr"`
 }
 
+// ErrUnauthorized matches, with errors.Is, the errors of requests the
+// server refused because the token is missing, revoked or expired.
+var ErrUnauthorized = errors.New("plex token rejected")
+
+// ErrForbidden matches the errors of requests the server refused although
+// the token is valid, such as a home user without access to a library.
+var ErrForbidden = errors.New("plex access denied")
+
+// AuthError is returned for a request the server answered with 401 or 403.
+// It matches ErrUnauthorized for a 401 and ErrForbidden for a 403.
+type AuthError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *AuthError) Error() string {
+	if e.StatusCode == http.StatusForbidden {
+		return fmt.Sprintf("plex api error: %d for %s: access denied", e.StatusCode, e.URL)
+	}
+	return fmt.Sprintf("plex api error: %d for %s: token rejected", e.StatusCode, e.URL)
+}
+
+func (e *AuthError) Is(target error) bool {
+	if e.StatusCode == http.StatusForbidden {
+		return target == ErrForbidden
+	}
+	return target == ErrUnauthorized
+}
+
 // Do sends an HTTP request with standard headers and retry logic.
+// Retries and their backoff stop as soon as the request's context is done.
+// A 401 or 403 answer is returned as an *AuthError.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.do(c.Client, req)
+}
+
+func (c *Client) do(client *http.Client, req *http.Request) (*http.Response, error) {
 	// Add standard headers
 	for k, v := range c.Headers {
 		req.Header.Set(k, v)
@@ -122,12 +176,21 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		req.Header.Set("X-Plex-Token", c.Token)
 	}
 
+	ctx := req.Context()
 	maxRetries := 3
 	var lastErr error
 	canRetryBody := req.Body == nil || req.GetBody != nil
 
-	for i := 0; i <= maxRetries; i++ {
-		if i > 0 {
+	retries, switches := 0, 0
+	failedOver := false
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if !failedOver {
+				if retries == maxRetries {
+					break
+				}
+				retries++
+			}
 			if !canRetryBody {
 				return nil, fmt.Errorf("request to %s cannot be retried (non-rewindable body)", req.URL.String())
 			}
@@ -139,14 +202,29 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 				req.Body = body
 			}
 
-			// Exponential backoff: 0.5s, 1s, 2s
-			backoff := time.Duration(math.Pow(2, float64(i-1))) * 500 * time.Millisecond
-			time.Sleep(backoff)
+			// Exponential backoff: 0.5s, 1s, 2s; none when trying another connection
+			if !failedOver {
+				backoff := time.Duration(math.Pow(2, float64(retries-1))) * 500 * time.Millisecond
+				if err := sleepContext(ctx, backoff); err != nil {
+					return nil, fmt.Errorf("request to %s: %w", req.URL.String(), err)
+				}
+			}
+			failedOver = false
 		}
 
-		resp, err := c.Client.Do(req)
+		resp, err := client.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("request to %s: %w", req.URL.String(), ctx.Err())
+			}
 			lastErr = err
+			// Unreachable: move on to the server's next connection first
+			if switches < c.connectionCount()-1 {
+				if next := c.failover(req.URL.Scheme + "://" + req.URL.Host); next != "" && rebase(req, next) == nil {
+					switches++
+					failedOver = true
+				}
+			}
 			continue
 		}
 
@@ -155,13 +233,20 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 			resp.Body.Close()
 			if resp.StatusCode == http.StatusTooManyRequests {
 				if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
-					time.Sleep(retryAfter)
+					if err := sleepContext(ctx, retryAfter); err != nil {
+						return nil, fmt.Errorf("request to %s: %w", req.URL.String(), err)
+					}
 				}
 			}
 			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
 			continue
 		}
 
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			resp.Body.Close()
+			return nil, &AuthError{StatusCode: resp.StatusCode, URL: req.URL.String()}
+		}
+
 		return resp, nil
 	}
 
@@ -169,46 +254,99 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 }
 
 func (c *Client) GetSections() ([]Directory, error) {
-	url := fmt.Sprintf("%s/library/sections", c.BaseURL)
+	return c.GetSectionsContext(context.Background())
+}
+
+func (c *Client) GetSectionsContext(ctx context.Context) ([]Directory, error) {
+	url := fmt.Sprintf("%s/library/sections", c.baseURL())
 	var mc MediaContainer
-	if err := c.getXML(url, &mc); err != nil {
+	if err := c.getXML(ctx, url, &mc); err != nil {
 		return nil, err
 	}
 	return mc.Directories, nil
 }
 
 func (c *Client) GetSectionAll(key string) ([]Directory, []Video, error) {
-	url := fmt.Sprintf("%s/library/sections/%s/all", c.BaseURL, key)
-	var mc MediaContainer
-	if err := c.getXML(url, &mc); err != nil {
+	return c.GetSectionAllContext(context.Background(), key)
+}
+
+func (c *Client) GetSectionAllContext(ctx context.Context, key string) ([]Directory, []Video, error) {
+	var dirs []Directory
+	var videos []Video
+	err := c.ForEachSectionPage(ctx, key, DefaultPageSize, func(page *MediaContainer) error {
+		dirs = append(dirs, page.Directories...)
+		videos = append(videos, page.Videos...)
+		return nil
+	})
+	if err != nil {
 		return nil, nil, err
 	}
 	// Return both. For movies, Dirs will be empty. For Shows, Videos might be empty (or contain episodes if flattened? usually Shows are Dirs)
-	return mc.Directories, mc.Videos, nil
+	return dirs, videos, nil
+}
+
+// DefaultPageSize is the number of items requested per page of a section listing.
+const DefaultPageSize = 200
+
+// ForEachSectionPage lists /library/sections/{key}/all in pages of pageSize
+// items and calls fn with each one. Iteration stops at the first error.
+func (c *Client) ForEachSectionPage(ctx context.Context, key string, pageSize int, fn func(page *MediaContainer) error) error {
+	for start := 0; ; {
+		// Resolved per page, so that pages after a failover skip the
+		// unreachable URL
+		url := fmt.Sprintf("%s/library/sections/%s/all", c.baseURL(), key)
+		var mc MediaContainer
+		if err := c.getXMLPage(ctx, url, start, pageSize, &mc); err != nil {
+			return err
+		}
+		if err := fn(&mc); err != nil {
+			return err
+		}
+
+		n := len(mc.Directories) + len(mc.Videos)
+		start += n
+		// Servers that ignore the container headers send everything at once
+		// without a totalSize.
+		if n == 0 || n < pageSize || mc.TotalSize == 0 || start >= mc.TotalSize {
+			return nil
+		}
+	}
 }
 
 func (c *Client) GetOnDeck(key string) ([]Video, error) {
-	url := fmt.Sprintf("%s/library/sections/%s/onDeck", c.BaseURL, key)
+	return c.GetOnDeckContext(context.Background(), key)
+}
+
+func (c *Client) GetOnDeckContext(ctx context.Context, key string) ([]Video, error) {
+	url := fmt.Sprintf("%s/library/sections/%s/onDeck", c.baseURL(), key)
 	var mc MediaContainer
-	if err := c.getXML(url, &mc); err != nil {
+	if err := c.getXML(ctx, url, &mc); err != nil {
 		return nil, err
 	}
 	return mc.Videos, nil
 }
 
 func (c *Client) GetChildren(key string) ([]Directory, []Video, error) {
-	url := fmt.Sprintf("%s/library/metadata/%s/children", c.BaseURL, key)
+	return c.GetChildrenContext(context.Background(), key)
+}
+
+func (c *Client) GetChildrenContext(ctx context.Context, key string) ([]Directory, []Video, error) {
+	url := fmt.Sprintf("%s/library/metadata/%s/children", c.baseURL(), key)
 	var mc MediaContainer
-	if err := c.getXML(url, &mc); err != nil {
+	if err := c.getXML(ctx, url, &mc); err != nil {
 		return nil, nil, err
 	}
 	return mc.Directories, mc.Videos, nil
 }
 
 func (c *Client) GetMetadata(key string) (*Video, error) {
-	url := fmt.Sprintf("%s/library/metadata/%s", c.BaseURL, key)
+	return c.GetMetadataContext(context.Background(), key)
+}
+
+func (c *Client) GetMetadataContext(ctx context.Context, key string) (*Video, error) {
+	url := fmt.Sprintf("%s/library/metadata/%s", c.baseURL(), key)
 	var mc MediaContainer
-	if err := c.getXML(url, &mc); err != nil {
+	if err := c.getXML(ctx, url, &mc); err != nil {
 		return nil, err
 	}
 	if len(mc.Videos) > 0 {
@@ -218,19 +356,28 @@ func (c *Client) GetMetadata(key string) (*Video, error) {
 }
 
 func (c *Client) GetSectionDirs(key string) ([]Directory, error) {
-	url := fmt.Sprintf("%s/library/sections/%s/all", c.BaseURL, key)
-	var mc MediaContainer
-	if err := c.getXML(url, &mc); err != nil {
-		return nil, err
-	}
-	return mc.Directories, nil
+	return c.GetSectionDirsContext(context.Background(), key)
 }
 
-func (c *Client) getXML(url string, target interface{}) error {
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) GetSectionDirsContext(ctx context.Context, key string) ([]Directory, error) {
+	dirs, _, err := c.GetSectionAllContext(ctx, key)
+	return dirs, err
+}
+
+func (c *Client) getXML(ctx context.Context, url string, target interface{}) error {
+	return c.getXMLPage(ctx, url, 0, 0, target)
+}
+
+// getXMLPage is getXML for paginated endpoints; size 0 requests everything.
+func (c *Client) getXMLPage(ctx context.Context, url string, start, size int, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
+	if size > 0 {
+		req.Header.Set("X-Plex-Container-Start", strconv.Itoa(start))
+		req.Header.Set("X-Plex-Container-Size", strconv.Itoa(size))
+	}
 
 	resp, err := c.Do(req)
 	if err != nil {
@@ -246,38 +393,105 @@ func (c *Client) getXML(url string, target interface{}) error {
 }
 
 func (c *Client) ReportProgress(key string, timeMs int64, durationMs int64, state string) error {
+	return c.ReportProgressContext(context.Background(), key, timeMs, durationMs, state)
+}
+
+func (c *Client) ReportProgressContext(ctx context.Context, key string, timeMs int64, durationMs int64, state string) error {
 	metadataPath := fmt.Sprintf("/library/metadata/%s", key)
 	// Headers will inject token, so remove from URL
 	url := fmt.Sprintf("%s/:/timeline?ratingKey=%s&key=%s&state=%s&time=%d&duration=%d",
-		c.BaseURL, key, metadataPath, state, timeMs, durationMs)
+		c.baseURL(), key, metadataPath, state, timeMs, durationMs)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := c.Do(req)
+	return c.send(req, "timeline")
+}
+
+func (c *Client) Scrobble(key string) error {
+	return c.ScrobbleContext(context.Background(), key)
+}
+
+func (c *Client) ScrobbleContext(ctx context.Context, key string) error {
+	// Headers will inject token, so remove from URL
+	url := fmt.Sprintf("%s/:/scrobble?key=%s&identifier=com.plexapp.plugins.library",
+		c.baseURL(), key)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("plex timeline error: %d for %s", resp.StatusCode, url)
+	return c.send(req, "scrobble")
+}
+
+// Unscrobble marks an item unwatched. For a season or show it applies to
+// all of its episodes.
+func (c *Client) Unscrobble(key string) error {
+	return c.UnscrobbleContext(context.Background(), key)
+}
+
+func (c *Client) UnscrobbleContext(ctx context.Context, key string) error {
+	url := fmt.Sprintf("%s/:/unscrobble?key=%s&identifier=com.plexapp.plugins.library",
+		c.baseURL(), key)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
 	}
-	return nil
+	return c.send(req, "unscrobble")
 }
 
-func (c *Client) Scrobble(key string) error {
-	// Headers will inject token, so remove from URL
-	url := fmt.Sprintf("%s/:/scrobble?key=%s&identifier=com.plexapp.plugins.library",
-		c.BaseURL, key)
+// SetProgress sets the resume position of a movie or episode without a
+// playback session; 0 clears it.
+func (c *Client) SetProgress(key string, timeMs int64) error {
+	return c.SetProgressContext(context.Background(), key, timeMs)
+}
+
+func (c *Client) SetProgressContext(ctx context.Context, key string, timeMs int64) error {
+	url := fmt.Sprintf("%s/:/progress?key=%s&identifier=com.plexapp.plugins.library&time=%d&state=stopped",
+		c.baseURL(), key, timeMs)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
+	return c.send(req, "progress")
+}
+
+// Rate sets the user rating of an item, from 0 to 10 (five stars); -1
+// removes it.
+func (c *Client) Rate(key string, rating float64) error {
+	return c.RateContext(context.Background(), key, rating)
+}
 
+func (c *Client) RateContext(ctx context.Context, key string, rating float64) error {
+	url := fmt.Sprintf("%s/:/rate?key=%s&identifier=com.plexapp.plugins.library&rating=%s",
+		c.baseURL(), key, strconv.FormatFloat(rating, 'f', -1, 64))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	return c.send(req, "rate")
+}
+
+// StatusError is returned by calls that expect a 200 answer and got another
+// status, other than 401 or 403 which give an *AuthError.
+type StatusError struct {
+	Op         string // "timeline", "scrobble", ...
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("plex %s error: %d for %s", e.Op, e.StatusCode, e.URL)
+}
+
+// send runs req, discarding the body of the answer.
+func (c *Client) send(req *http.Request, op string) error {
 	resp, err := c.Do(req)
 	if err != nil {
 		return err
@@ -285,11 +499,36 @@ func (c *Client) Scrobble(key string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("plex scrobble error: %d for %s", resp.StatusCode, url)
+		return &StatusError{Op: op, StatusCode: resp.StatusCode, URL: req.URL.String()}
 	}
 	return nil
 }
 
+// OpenPart requests the file of a media part from byte offset on, to
+// download it. The answer is 206 when the server resumed at offset and 200
+// when it sends the whole file; the caller closes its body. Unlike other
+// calls it has no overall timeout, as a file takes long to transfer: cancel
+// ctx to stop.
+func (c *Client) OpenPart(ctx context.Context, partKey string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL()+partKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	client := &http.Client{Transport: c.Client.Transport, CheckRedirect: c.Client.CheckRedirect, Jar: c.Client.Jar}
+	resp, err := c.do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, &StatusError{Op: "download", StatusCode: resp.StatusCode, URL: req.URL.String()}
+	}
+	return resp, nil
+}
+
 type PlayQueue struct {
 	PlayQueueID                 string  `xml:"playQueueID,attr"`
 	PlayQueueSelectedItemID     string  `xml:"playQueueSelectedItemID,attr"`
@@ -304,12 +543,16 @@ type PlayQueueContainer struct {
 }
 
 func (c *Client) GetMachineIdentifier() (string, error) {
+	return c.GetMachineIdentifierContext(context.Background())
+}
+
+func (c *Client) GetMachineIdentifierContext(ctx context.Context) (string, error) {
 	if c.MachineIdentifier != "" {
 		return c.MachineIdentifier, nil
 	}
 	// Fetch root to get identifier
 	var mc MediaContainer
-	if err := c.getXML(c.BaseURL, &mc); err != nil {
+	if err := c.getXML(ctx, c.baseURL(), &mc); err != nil {
 		return "", err
 	}
 	c.MachineIdentifier = mc.MachineIdentifier
@@ -317,7 +560,11 @@ func (c *Client) GetMachineIdentifier() (string, error) {
 }
 
 func (c *Client) CreatePlayQueue(item Video) (*PlayQueueContainer, error) {
-	machineID, err := c.GetMachineIdentifier()
+	return c.CreatePlayQueueContext(context.Background(), item)
+}
+
+func (c *Client) CreatePlayQueueContext(ctx context.Context, item Video) (*PlayQueueContainer, error) {
+	machineID, err := c.GetMachineIdentifierContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get machine identifier: %w", err)
 	}
@@ -342,9 +589,9 @@ func (c *Client) CreatePlayQueue(item Video) (*PlayQueueContainer, error) {
 
 	params.Set("uri", uri)
 
-	endpoint := fmt.Sprintf("%s/playQueues?%s", c.BaseURL, params.Encode())
+	endpoint := fmt.Sprintf("%s/playQueues?%s", c.baseURL(), params.Encode())
 
-	req, err := http.NewRequest("POST", endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -386,3 +633,15 @@ func parseRetryAfter(value string) (time.Duration, bool) {
 	}
 	return 0, false
 }
+
+// sleepContext waits for d, returning early with the context's error.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,188 @@
+package plex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+)
+
+func TestDo_CancelStopsRetries(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := c.GetSectionsContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	// Without cancellation the backoff alone would take 3.5s.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Do to return promptly after cancel, took %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected 1 request before cancel, got %d", n)
+	}
+}
+
+func TestDo_Unauthorized(t *testing.T) {
+	tests := []struct {
+		status      int
+		want, other error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized, ErrForbidden},
+		{http.StatusForbidden, ErrForbidden, ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		var requests int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(tt.status)
+		}))
+
+		c := New(srv.URL, "token", "client-id", appinfo.Default())
+		_, err := c.GetSections()
+		srv.Close()
+		if !errors.Is(err, tt.want) || errors.Is(err, tt.other) {
+			t.Errorf("%d: expected %v only, got %v", tt.status, tt.want, err)
+		}
+		var authErr *AuthError
+		if !errors.As(err, &authErr) || authErr.StatusCode != tt.status {
+			t.Errorf("%d: expected an *AuthError with that status, got %v", tt.status, err)
+		}
+		if n := atomic.LoadInt32(&requests); n != 1 {
+			t.Errorf("%d: expected no retry, got %d requests", tt.status, n)
+		}
+	}
+}
+
+func TestWatchStateCalls(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Path+"?"+r.URL.RawQuery)
+		if r.URL.Query().Get("key") == "404" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+	if err := c.Unscrobble("10"); err != nil {
+		t.Fatalf("Unscrobble failed: %v", err)
+	}
+	if err := c.SetProgress("11", 90_000); err != nil {
+		t.Fatalf("SetProgress failed: %v", err)
+	}
+	if err := c.Rate("12", 7.5); err != nil {
+		t.Fatalf("Rate failed: %v", err)
+	}
+	want := []string{
+		"/:/unscrobble?key=10&identifier=com.plexapp.plugins.library",
+		"/:/progress?key=11&identifier=com.plexapp.plugins.library&time=90000&state=stopped",
+		"/:/rate?key=12&identifier=com.plexapp.plugins.library&rating=7.5",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected requests %v, got %v", want, got)
+	}
+
+	var statusErr *StatusError
+	if err := c.Scrobble("404"); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || statusErr.Op != "scrobble" {
+		t.Errorf("Expected a scrobble *StatusError with status 404, got %v", err)
+	}
+}
+
+func TestGetSectionAll_Paginates(t *testing.T) {
+	const total = 450
+	var pages int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		start, _ := strconv.Atoi(r.Header.Get("X-Plex-Container-Start"))
+		size, _ := strconv.Atoi(r.Header.Get("X-Plex-Container-Size"))
+		if size != DefaultPageSize {
+			t.Errorf("Expected page size %d, got %d", DefaultPageSize, size)
+		}
+		fmt.Fprintf(w, `<MediaContainer offset="%d" totalSize="%d">`, start, total)
+		for i := start; i < start+size && i < total; i++ {
+			fmt.Fprintf(w, `<Video ratingKey="%d" />`, i)
+		}
+		fmt.Fprint(w, `</MediaContainer>`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+	_, videos, err := c.GetSectionAll("1")
+	if err != nil {
+		t.Fatalf("GetSectionAll failed: %v", err)
+	}
+	if len(videos) != total {
+		t.Errorf("Expected %d videos, got %d", total, len(videos))
+	}
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	if videos[total-1].RatingKey != strconv.Itoa(total-1) {
+		t.Errorf("Expected last video %d, got %s", total-1, videos[total-1].RatingKey)
+	}
+}
+
+func TestGetSectionAll_UnpaginatedServer(t *testing.T) {
+	var pages int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		fmt.Fprint(w, `<MediaContainer><Directory ratingKey="1" /><Directory ratingKey="2" /></MediaContainer>`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+	dirs, err := c.GetSectionDirs("2")
+	if err != nil {
+		t.Fatalf("GetSectionDirs failed: %v", err)
+	}
+	if len(dirs) != 2 || pages != 1 {
+		t.Errorf("Expected 2 dirs in 1 page, got %d in %d", len(dirs), pages)
+	}
+}
+
+func TestSearchHubs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hubs/search" || r.URL.Query().Get("query") != "star wars" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		fmt.Fprint(w, `<MediaContainer>
+			<Hub type="movie" title="Movies"><Video ratingKey="1" type="movie" title="Star Wars" /></Hub>
+			<Hub type="show" title="Shows"><Directory ratingKey="2" type="show" title="Star Wars: Andor" librarySectionID="3" /></Hub>
+		</MediaContainer>`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+	hubs, err := c.SearchHubs("star wars", 10)
+	if err != nil {
+		t.Fatalf("SearchHubs failed: %v", err)
+	}
+	if len(hubs) != 2 {
+		t.Fatalf("Expected 2 hubs, got %d", len(hubs))
+	}
+	if hubs[0].Type != "movie" || len(hubs[0].Videos) != 1 || hubs[0].Videos[0].Title != "Star Wars" {
+		t.Errorf("Unexpected movie hub: %+v", hubs[0])
+	}
+	if hubs[1].Type != "show" || len(hubs[1].Directories) != 1 || hubs[1].Directories[0].LibrarySectionID != "3" {
+		t.Errorf("Unexpected show hub: %+v", hubs[1])
+	}
+}
@@ -0,0 +1,38 @@
+package plex
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Hub is one group of results (movies, shows, episodes, ...) from /hubs/search.
+type Hub struct {
+	Type          string      `xml:"type,attr"`
+	HubIdentifier string      `xml:"hubIdentifier,attr"`
+	Title         string      `xml:"title,attr"`
+	Directories   []Directory `xml:"Directory"`
+	Videos        []Video     `xml:"Video"`
+}
+
+// SearchHubs searches every library of the server, returning at most limit
+// results per hub.
+func (c *Client) SearchHubs(query string, limit int) ([]Hub, error) {
+	return c.SearchHubsContext(context.Background(), query, limit)
+}
+
+func (c *Client) SearchHubsContext(ctx context.Context, query string, limit int) ([]Hub, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("limit", strconv.Itoa(limit))
+	endpoint := fmt.Sprintf("%s/hubs/search?%s", c.baseURL(), params.Encode())
+
+	var mc struct {
+		Hubs []Hub `xml:"Hub"`
+	}
+	if err := c.getXML(ctx, endpoint, &mc); err != nil {
+		return nil, err
+	}
+	return mc.Hubs, nil
+}
@@ -0,0 +1,197 @@
+package plex
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Quality describes the limits passed to the Plex universal transcoder.
+// A zero Quality means direct play (no transcoding).
+type Quality struct {
+	Width      int // Max video width in pixels
+	Height     int // Max video height in pixels
+	MaxBitrate int // Max video bitrate in kbps
+}
+
+// Original reports whether the quality asks for the untouched source file.
+func (q Quality) Original() bool {
+	return q.Height == 0 && q.MaxBitrate == 0
+}
+
+// Resolution returns the "WxH" form expected by the transcoder.
+func (q Quality) Resolution() string {
+	return fmt.Sprintf("%dx%d", q.Width, q.Height)
+}
+
+var qualityResolutions = map[string][2]int{
+	"2160p": {3840, 2160},
+	"1080p": {1920, 1080},
+	"720p":  {1280, 720},
+	"480p":  {720, 480},
+	"360p":  {640, 360},
+}
+
+// Default bitrates (kbps) used when a preset only names a resolution.
+var qualityDefaultBitrates = map[string]int{
+	"2160p": 40000,
+	"1080p": 8000,
+	"720p":  4000,
+	"480p":  2000,
+	"360p":  720,
+}
+
+// ParseQuality parses a quality preset such as "original", "1080p",
+// "720p-4mbps" or "480p-1500kbps". "auto" and "" map to direct play.
+func ParseQuality(s string) (Quality, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "auto" || s == "original" {
+		return Quality{}, nil
+	}
+
+	res, rate, hasRate := strings.Cut(s, "-")
+	dims, ok := qualityResolutions[res]
+	if !ok {
+		return Quality{}, fmt.Errorf("unknown quality resolution %q", res)
+	}
+	q := Quality{Width: dims[0], Height: dims[1], MaxBitrate: qualityDefaultBitrates[res]}
+
+	if hasRate {
+		kbps, err := parseBitrate(rate)
+		if err != nil {
+			return Quality{}, fmt.Errorf("invalid quality %q: %w", s, err)
+		}
+		q.MaxBitrate = kbps
+	}
+	return q, nil
+}
+
+// parseBitrate converts "8mbps", "1500kbps" or "1.5mbps" to kbps.
+func parseBitrate(s string) (int, error) {
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "mbps"):
+		s = strings.TrimSuffix(s, "mbps")
+		mult = 1000
+	case strings.HasSuffix(s, "kbps"):
+		s = strings.TrimSuffix(s, "kbps")
+	default:
+		return 0, fmt.Errorf("bitrate %q must end in mbps or kbps", s)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("bitrate %q is not a positive number", s)
+	}
+	return int(v * mult), nil
+}
+
+// sourceHeight maps a Plex videoResolution attribute to a pixel height.
+func sourceHeight(res string) int {
+	switch strings.ToLower(res) {
+	case "4k":
+		return 2160
+	case "sd":
+		return 480
+	}
+	h, _ := strconv.Atoi(strings.TrimSuffix(strings.ToLower(res), "p"))
+	return h
+}
+
+// NeedsTranscode reports whether v exceeds the limits of q. An unknown
+// resolution is treated as exceeding the limit; an unknown bitrate, which
+// the cache may lack, is not once the resolution fits.
+func NeedsTranscode(v Video, q Quality) bool {
+	if q.Original() {
+		return false
+	}
+	if len(v.Media) == 0 {
+		return true
+	}
+	m := v.Media[0]
+	h := sourceHeight(m.VideoResolution)
+	if h == 0 || h > q.Height {
+		return true
+	}
+	return m.Bitrate > q.MaxBitrate
+}
+
+// TranscodeDecision is the response of the universal decision endpoint.
+type TranscodeDecision struct {
+	GeneralDecisionCode   int    `xml:"generalDecisionCode,attr"`
+	GeneralDecisionText   string `xml:"generalDecisionText,attr"`
+	TranscodeDecisionCode int    `xml:"transcodeDecisionCode,attr"`
+	TranscodeDecisionText string `xml:"transcodeDecisionText,attr"`
+}
+
+// PlaybackURL returns the URL to hand to the player for v.
+// Direct play is used when quality is original or the source already fits
+// within its limits; otherwise the server is asked for a transcode decision
+// and an HLS stream URL from the universal transcoder is returned.
+// The returned URL does not contain the token.
+func (c *Client) PlaybackURL(v Video, quality string) (string, error) {
+	return c.PlaybackURLContext(context.Background(), v, quality)
+}
+
+func (c *Client) PlaybackURLContext(ctx context.Context, v Video, quality string) (string, error) {
+	if len(v.Media) == 0 || len(v.Media[0].Part) == 0 {
+		return "", fmt.Errorf("no media part found for %s", v.Title)
+	}
+	directURL := c.baseURL() + v.Media[0].Part[0].Key
+
+	q, err := ParseQuality(quality)
+	if err != nil {
+		return "", err
+	}
+	if !NeedsTranscode(v, q) {
+		return directURL, nil
+	}
+
+	params := c.transcodeParams(v.RatingKey, q)
+	decisionURL := fmt.Sprintf("%s/video/:/transcode/universal/decision?%s", c.baseURL(), params.Encode())
+	var decision TranscodeDecision
+	if err := c.getXML(ctx, decisionURL, &decision); err != nil {
+		return "", fmt.Errorf("transcode decision failed: %w", err)
+	}
+	// 1xxx codes are success; anything else means the server refused.
+	if decision.GeneralDecisionCode != 0 && decision.GeneralDecisionCode/1000 != 1 {
+		return "", fmt.Errorf("transcode refused: %s (%d)", decision.GeneralDecisionText, decision.GeneralDecisionCode)
+	}
+
+	return fmt.Sprintf("%s/video/:/transcode/universal/start.m3u8?%s", c.baseURL(), params.Encode()), nil
+}
+
+func (c *Client) transcodeParams(ratingKey string, q Quality) url.Values {
+	params := url.Values{}
+	params.Set("path", "/library/metadata/"+ratingKey)
+	params.Set("mediaIndex", "0")
+	params.Set("partIndex", "0")
+	params.Set("protocol", "hls")
+	params.Set("fastSeek", "1")
+	params.Set("directPlay", "0")
+	params.Set("directStream", "1")
+	params.Set("directStreamAudio", "1")
+	params.Set("videoQuality", "100")
+	params.Set("videoResolution", q.Resolution())
+	params.Set("maxVideoBitrate", strconv.Itoa(q.MaxBitrate))
+	params.Set("subtitles", "auto")
+	params.Set("session", newSessionID())
+	// The transcoder reads client identity from the query string for
+	// segment requests, which mpv issues without our headers.
+	for _, k := range []string{"X-Plex-Client-Identifier", "X-Plex-Product", "X-Plex-Platform", "X-Plex-Device", "X-Plex-Version"} {
+		if v := c.Headers[k]; v != "" {
+			params.Set(k, v)
+		}
+	}
+	return params
+}
+
+func newSessionID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "plex-client-session"
+	}
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,152 @@
+package plex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+)
+
+func TestParseQuality(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Quality
+		wantErr bool
+	}{
+		{in: "original", want: Quality{}},
+		{in: "auto", want: Quality{}},
+		{in: "", want: Quality{}},
+		{in: "1080p-8mbps", want: Quality{Width: 1920, Height: 1080, MaxBitrate: 8000}},
+		{in: "720p-4mbps", want: Quality{Width: 1280, Height: 720, MaxBitrate: 4000}},
+		{in: "480p-1500kbps", want: Quality{Width: 720, Height: 480, MaxBitrate: 1500}},
+		{in: "720p", want: Quality{Width: 1280, Height: 720, MaxBitrate: 4000}},
+		{in: "999p", wantErr: true},
+		{in: "720p-fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuality(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseQuality(%q): expected error, got %+v", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseQuality(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseQuality(%q): expected %+v, got %+v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func testVideo(res string, bitrate int) Video {
+	return Video{
+		RatingKey: "42",
+		Title:     "Test Movie",
+		Media: []Media{{
+			VideoResolution: res,
+			Bitrate:         bitrate,
+			Part:            []Part{{Key: "/library/parts/7/file.mkv"}},
+		}},
+	}
+}
+
+func TestPlaybackURL_DirectPlay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s for direct play", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+
+	tests := []struct {
+		v       Video
+		quality string
+	}{
+		{testVideo("720", 3000), "original"},
+		{testVideo("720", 3000), "1080p-8mbps"},
+		{testVideo("720", 0), "1080p-8mbps"}, // Bitrate not cached
+	}
+	for _, tt := range tests {
+		got, err := c.PlaybackURL(tt.v, tt.quality)
+		if err != nil {
+			t.Fatalf("PlaybackURL(%q) failed: %v", tt.quality, err)
+		}
+		if want := srv.URL + "/library/parts/7/file.mkv"; got != want {
+			t.Errorf("PlaybackURL(%q): expected %s, got %s", tt.quality, want, got)
+		}
+	}
+}
+
+func TestPlaybackURL_Transcode(t *testing.T) {
+	var decisionQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/video/:/transcode/universal/decision" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-Plex-Token") != "token" {
+			t.Errorf("Expected token header on decision request")
+		}
+		decisionQuery = r.URL.Query()
+		w.Write([]byte(`<MediaContainer generalDecisionCode="1001" generalDecisionText="Direct play not available" />`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+
+	got, err := c.PlaybackURL(testVideo("1080", 12000), "720p-4mbps")
+	if err != nil {
+		t.Fatalf("PlaybackURL failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"path":                     "/library/metadata/42",
+		"protocol":                 "hls",
+		"directPlay":               "0",
+		"videoResolution":          "1280x720",
+		"maxVideoBitrate":          "4000",
+		"X-Plex-Client-Identifier": "client-id",
+	}
+	for k, v := range expected {
+		if decisionQuery.Get(k) != v {
+			t.Errorf("Expected decision param %s=%q, got %q", k, v, decisionQuery.Get(k))
+		}
+	}
+	if decisionQuery.Get("session") == "" {
+		t.Errorf("Expected a session param on decision request")
+	}
+
+	if !strings.HasPrefix(got, srv.URL+"/video/:/transcode/universal/start.m3u8?") {
+		t.Fatalf("Expected start.m3u8 URL, got %s", got)
+	}
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("Invalid playback URL: %v", err)
+	}
+	if u.Query().Get("session") != decisionQuery.Get("session") {
+		t.Errorf("Expected start URL to reuse decision session")
+	}
+	if u.Query().Get("X-Plex-Token") != "" {
+		t.Errorf("Expected playback URL without token")
+	}
+}
+
+func TestPlaybackURL_TranscodeRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<MediaContainer generalDecisionCode="2000" generalDecisionText="Transcoder unavailable" />`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token", "client-id", appinfo.Default())
+	if _, err := c.PlaybackURL(testVideo("4k", 0), "1080p-8mbps"); err == nil {
+		t.Errorf("Expected error when the server refuses to transcode")
+	}
+}
@@ -0,0 +1,295 @@
+// Package query implements the filter language of the library browser,
+// shared with exports.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+// Filter reports whether a video matches a parsed filter query.
+type Filter func(plex.Video) bool
+
+// MatchAll is the filter of an empty query.
+func MatchAll(plex.Video) bool { return true }
+
+// queryTerm is one whitespace-separated token of a filter query,
+// e.g. `genre:horror`, `-watched` or `actor:"Cate Blanchett"`.
+type queryTerm struct {
+	key    string // Lowercased key before ':', empty for bare words
+	value  string
+	quoted bool // Value was (partly) quoted, so it is never a keyword
+	negate bool // Leading '-'
+}
+
+// Parse parses the browser filter language into a predicate.
+// All terms must match. Supported terms:
+//
+//	genre:X  actor:X  director:X  studio:X  title:X   substring of the tag
+//	content:X (cr:X)                                  content rating, e.g. PG-13
+//	codec:X  vcodec:X  acodec:X                       media codecs, e.g. hevc, aac
+//	res:X                                             4k, 1080, 720, sd
+//	year:N  year:A..B  year:A..  year:..B  year:>N    same forms for rating:
+//	watched  unwatched  inprogress
+//
+// Any other word matches the title or a director, and a leading '-'
+// negates a term. Values containing spaces must be quoted.
+func Parse(query string) (Filter, error) {
+	terms, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return MatchAll, nil
+	}
+
+	preds := make([]Filter, 0, len(terms))
+	for _, t := range terms {
+		p, err := t.predicate()
+		if err != nil {
+			return nil, err
+		}
+		if t.negate {
+			inner := p
+			p = func(v plex.Video) bool { return !inner(v) }
+		}
+		preds = append(preds, p)
+	}
+	return func(v plex.Video) bool {
+		for _, p := range preds {
+			if !p(v) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func tokenizeQuery(query string) ([]queryTerm, error) {
+	var terms []queryTerm
+	var cur queryTerm
+	var buf strings.Builder
+	inToken, inQuote := false, false
+
+	flush := func() {
+		cur.value = buf.String()
+		// A lone '-' typed before the rest of a term is not a term yet.
+		if inToken && (cur.key != "" || cur.value != "" || cur.quoted) {
+			terms = append(terms, cur)
+		}
+		cur = queryTerm{}
+		buf.Reset()
+		inToken = false
+	}
+
+	for _, r := range query {
+		switch {
+		case inQuote:
+			if r == '"' {
+				inQuote = false
+			} else {
+				buf.WriteRune(r)
+			}
+		case r == ' ' || r == '\t':
+			flush()
+		case r == '"':
+			inToken, inQuote, cur.quoted = true, true, true
+		case r == '-' && !inToken:
+			inToken, cur.negate = true, true
+		case r == ':' && cur.key == "" && !cur.quoted && buf.Len() > 0:
+			cur.key = strings.ToLower(buf.String())
+			buf.Reset()
+		default:
+			inToken = true
+			buf.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	return terms, nil
+}
+
+func (t queryTerm) predicate() (Filter, error) {
+	value := strings.ToLower(t.value)
+	if t.key == "" {
+		if !t.quoted {
+			if p, ok := watchFilters[value]; ok {
+				return p, nil
+			}
+		}
+		return func(v plex.Video) bool {
+			return containsFold(v.Title, value) || anyTag(v.Director, value)
+		}, nil
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%s: missing value", t.key)
+	}
+
+	switch t.key {
+	case "genre":
+		return func(v plex.Video) bool { return anyTag(v.Genre, value) }, nil
+	case "director":
+		return func(v plex.Video) bool { return anyTag(v.Director, value) }, nil
+	case "actor", "cast":
+		return func(v plex.Video) bool {
+			for _, r := range v.Role {
+				if containsFold(r.Tag, value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "studio":
+		return func(v plex.Video) bool { return containsFold(v.Studio, value) }, nil
+	case "title":
+		return func(v plex.Video) bool { return containsFold(v.Title, value) }, nil
+	case "content", "cr":
+		return func(v plex.Video) bool { return strings.EqualFold(v.ContentRating, value) }, nil
+	case "res", "resolution":
+		res, ok := normalizeResolution(value)
+		if !ok {
+			return nil, fmt.Errorf("res: unknown resolution %q (use 4k, 1080, 720 or sd)", t.value)
+		}
+		return func(v plex.Video) bool {
+			for _, m := range v.Media {
+				if got, _ := normalizeResolution(strings.ToLower(m.VideoResolution)); got == res {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "codec", "vcodec", "acodec":
+		key := t.key
+		return func(v plex.Video) bool {
+			for _, m := range v.Media {
+				if key != "acodec" && strings.EqualFold(m.VideoCodec, value) {
+					return true
+				}
+				if key != "vcodec" && strings.EqualFold(m.AudioCodec, value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "year":
+		lo, hi, err := parseRange(value)
+		if err != nil {
+			return nil, fmt.Errorf("year: %w", err)
+		}
+		return func(v plex.Video) bool {
+			return v.Year != 0 && float64(v.Year) >= lo && float64(v.Year) <= hi
+		}, nil
+	case "rating":
+		lo, hi, err := parseRange(value)
+		if err != nil {
+			return nil, fmt.Errorf("rating: %w", err)
+		}
+		return func(v plex.Video) bool { return v.Rating >= lo && v.Rating <= hi }, nil
+	}
+	return nil, fmt.Errorf("unknown filter %q (quote text containing ':')", t.key)
+}
+
+var watchFilters = map[string]Filter{
+	"watched":    func(v plex.Video) bool { return v.ViewCount > 0 },
+	"unwatched":  func(v plex.Video) bool { return v.ViewCount == 0 },
+	"inprogress": func(v plex.Video) bool { return v.ViewOffset > 0 },
+}
+
+// parseRange parses "N", "A..B", "A..", "..B", ">N", ">=N", "<N" and "<=N"
+// into an inclusive [lo, hi] range.
+func parseRange(s string) (lo, hi float64, err error) {
+	const inf = 1e18
+	num := func(x string) (float64, error) {
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", x)
+		}
+		return f, nil
+	}
+
+	if a, b, ok := strings.Cut(s, ".."); ok {
+		lo, hi = -inf, inf
+		if a == "" && b == "" {
+			return 0, 0, fmt.Errorf("empty range")
+		}
+		if a != "" {
+			if lo, err = num(a); err != nil {
+				return 0, 0, err
+			}
+		}
+		if b != "" {
+			if hi, err = num(b); err != nil {
+				return 0, 0, err
+			}
+		}
+		if lo > hi {
+			return 0, 0, fmt.Errorf("range %s is reversed", s)
+		}
+		return lo, hi, nil
+	}
+
+	// Strict bounds nudge by a small epsilon; years are whole and ratings
+	// have one decimal, so this never excludes a real value.
+	const eps = 1e-6
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		rest, ok := strings.CutPrefix(s, op)
+		if !ok {
+			continue
+		}
+		n, err := num(rest)
+		if err != nil {
+			return 0, 0, err
+		}
+		switch op {
+		case ">=":
+			return n, inf, nil
+		case "<=":
+			return -inf, n, nil
+		case ">":
+			return n + eps, inf, nil
+		default:
+			return -inf, n - eps, nil
+		}
+	}
+
+	n, err := num(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, n, nil
+}
+
+// normalizeResolution maps the spellings users type and Plex reports
+// ("2160p", "uhd", "1080p", "480") onto Plex's videoResolution values.
+func normalizeResolution(s string) (string, bool) {
+	switch strings.TrimSuffix(s, "p") {
+	case "4k", "2160", "uhd":
+		return "4k", true
+	case "1080", "fhd":
+		return "1080", true
+	case "720", "hd":
+		return "720", true
+	case "sd", "576", "480", "360":
+		return "sd", true
+	}
+	return "", false
+}
+
+func anyTag(tags []plex.Tag, sub string) bool {
+	for _, t := range tags {
+		if containsFold(t.Tag, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether sub (already lowercased) is within s.
+func containsFold(s, sub string) bool {
+	return strings.Contains(strings.ToLower(s), sub)
+}
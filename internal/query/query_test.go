@@ -0,0 +1,138 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+func TestParse(t *testing.T) {
+	alien := plex.Video{
+		Title:         "Alien",
+		Year:          1979,
+		Rating:        8.4,
+		ContentRating: "R",
+		Studio:        "20th Century Fox",
+		Genre:         []plex.Tag{{Tag: "Horror"}, {Tag: "Science Fiction"}},
+		Director:      []plex.Tag{{Tag: "Ridley Scott"}},
+		Role:          []plex.Role{{Tag: "Sigourney Weaver"}},
+		Media:         []plex.Media{{VideoResolution: "1080", VideoCodec: "h264", AudioCodec: "dca"}},
+		ViewCount:     2,
+	}
+	carol := plex.Video{
+		Title:         "Carol",
+		Year:          2015,
+		Rating:        7.2,
+		ContentRating: "R",
+		Studio:        "Number 9 Films",
+		Genre:         []plex.Tag{{Tag: "Drama"}, {Tag: "Romance"}},
+		Director:      []plex.Tag{{Tag: "Todd Haynes"}},
+		Role:          []plex.Role{{Tag: "Cate Blanchett"}, {Tag: "Rooney Mara"}},
+		Media:         []plex.Media{{VideoResolution: "4k", VideoCodec: "hevc", AudioCodec: "eac3"}},
+		ViewOffset:    60000,
+	}
+	scream := plex.Video{
+		Title:         "Scream",
+		Year:          1996,
+		Rating:        6.6,
+		ContentRating: "R",
+		Genre:         []plex.Tag{{Tag: "Horror"}, {Tag: "Mystery"}},
+		Director:      []plex.Tag{{Tag: "Wes Craven"}},
+		Media:         []plex.Media{{VideoResolution: "sd", VideoCodec: "mpeg2video", AudioCodec: "ac3"}},
+	}
+	videos := []plex.Video{alien, carol, scream}
+
+	tests := []struct {
+		query string
+		want  []string // Matching titles, in input order
+	}{
+		{query: "", want: []string{"Alien", "Carol", "Scream"}},
+		{query: "   ", want: []string{"Alien", "Carol", "Scream"}},
+		{query: "al", want: []string{"Alien"}},
+		{query: "scott", want: []string{"Alien"}},
+		{query: "genre:horror", want: []string{"Alien", "Scream"}},
+		{query: "GENRE:Horror", want: []string{"Alien", "Scream"}},
+		{query: `genre:"science fiction"`, want: []string{"Alien"}},
+		{query: "-genre:horror", want: []string{"Carol"}},
+		{query: "genre:horror year:1990..1999", want: []string{"Scream"}},
+		{query: "year:1979", want: []string{"Alien"}},
+		{query: "year:2000..", want: []string{"Carol"}},
+		{query: "year:..1990", want: []string{"Alien"}},
+		{query: "year:>1979", want: []string{"Carol", "Scream"}},
+		{query: "year:<=1996", want: []string{"Alien", "Scream"}},
+		{query: "rating:7..", want: []string{"Alien", "Carol"}},
+		{query: "rating:<7", want: []string{"Scream"}},
+		{query: `actor:"Cate Blanchett"`, want: []string{"Carol"}},
+		{query: "actor:weaver", want: []string{"Alien"}},
+		{query: "director:craven", want: []string{"Scream"}},
+		{query: "studio:fox", want: []string{"Alien"}},
+		{query: "content:r", want: []string{"Alien", "Carol", "Scream"}},
+		{query: "cr:pg-13", want: nil},
+		{query: "res:4k", want: []string{"Carol"}},
+		{query: "res:2160p", want: []string{"Carol"}},
+		{query: "res:1080p", want: []string{"Alien"}},
+		{query: "res:sd", want: []string{"Scream"}},
+		{query: "codec:hevc", want: []string{"Carol"}},
+		{query: "codec:ac3", want: []string{"Scream"}},
+		{query: "vcodec:ac3", want: nil},
+		{query: "acodec:eac3", want: []string{"Carol"}},
+		{query: "unwatched", want: []string{"Carol", "Scream"}},
+		{query: "watched", want: []string{"Alien"}},
+		{query: "inprogress", want: []string{"Carol"}},
+		{query: `"watched"`, want: nil},
+		{query: "-", want: []string{"Alien", "Carol", "Scream"}},
+		{query: "genre:horror unwatched", want: []string{"Scream"}},
+		{query: "res:4k actor:blanchett unwatched year:2010..2020", want: []string{"Carol"}},
+	}
+
+	for _, tt := range tests {
+		match, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, v := range videos {
+			if match(v) {
+				got = append(got, v.Title)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Parse(%q): expected %v, got %v", tt.query, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Parse(%q): expected %v, got %v", tt.query, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: `actor:"Cate`, want: "unterminated quote"},
+		{query: "gnre:horror", want: `unknown filter "gnre" (quote text containing ':')`},
+		{query: "genre:", want: "genre: missing value"},
+		{query: "year:19x0", want: `year: "19x0" is not a number`},
+		{query: "year:1999..1990", want: "year: range 1999..1990 is reversed"},
+		{query: "year:..", want: "year: empty range"},
+		{query: "rating:>high", want: `rating: "high" is not a number`},
+		{query: "res:8k", want: `res: unknown resolution "8k" (use 4k, 1080, 720 or sd)`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil {
+			t.Errorf("Parse(%q): expected error %q", tt.query, tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("Parse(%q): expected error %q, got %q", tt.query, tt.want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+// SearchResults groups the cached items matching a search query.
+type SearchResults struct {
+	Movies   []plex.Video
+	Shows    []plex.Video
+	Episodes []plex.Video
+}
+
+// All returns every result: movies, then shows, then episodes.
+func (r *SearchResults) All() []plex.Video {
+	items := append([]plex.Video{}, r.Movies...)
+	items = append(items, r.Shows...)
+	return append(items, r.Episodes...)
+}
+
+// searchKind describes how to search and scan one kind of item.
+type searchKind struct {
+	kind   string
+	from   string   // SELECT ... FROM <table> t [JOIN ...]
+	fields []string // Columns matched by the LIKE fallback
+	scan   func(rows *sql.Rows) (plex.Video, error)
+}
+
+var searchKinds = []searchKind{
+	{
+		kind: "movie",
+		from: `SELECT t.id, t.title, t.year, t.part_key, t.duration, t.summary, t.section_key, ` +
+			prefixColumns("t", MediaInfo{}.Columns()) + `, t.view_count, t.view_offset, t.last_viewed_at FROM films t`,
+		fields: []string{"t.title", "t.summary", `t."cast"`, "t.genres"},
+		scan: func(rows *sql.Rows) (plex.Video, error) {
+			var v plex.Video
+			var partKey, sectionKey sql.NullString
+			var media MediaInfo
+			var watch WatchState
+			scanArgs := append([]interface{}{&v.RatingKey, &v.Title, &v.Year, &partKey, &v.Duration, &v.Summary, &sectionKey}, media.Pointers()...)
+			scanArgs = append(scanArgs, watch.Pointers()...)
+			if err := rows.Scan(scanArgs...); err != nil {
+				return v, err
+			}
+			v.Type = "movie"
+			v.LibrarySectionID = sectionKey.String
+			// Played from the results: its part, and the media info deciding on transcoding
+			v.Key = partKey.String
+			media.ApplyTo(&v)
+			watch.ApplyTo(&v)
+			return v, nil
+		},
+	},
+	{
+		kind:   "show",
+		from:   `SELECT t.id, t.title, t.summary, t.section_key FROM series t`,
+		fields: []string{"t.title", "t.summary", `t."cast"`, "t.genres"},
+		scan: func(rows *sql.Rows) (plex.Video, error) {
+			var v plex.Video
+			var sectionKey sql.NullString
+			if err := rows.Scan(&v.RatingKey, &v.Title, &v.Summary, &sectionKey); err != nil {
+				return v, err
+			}
+			v.Type = "show"
+			v.LibrarySectionID = sectionKey.String
+			return v, nil
+		},
+	},
+	{
+		kind: "episode",
+		from: `SELECT t.id, t.title, t.episode_index, t.duration, t.summary, se.id, se.season_index, sr.id, sr.title, t.view_count, t.view_offset, t.last_viewed_at
+			FROM episodes t JOIN seasons se ON se.id = t.season_id JOIN series sr ON sr.id = se.series_id`,
+		fields: []string{"t.title", "t.summary"},
+		scan: func(rows *sql.Rows) (plex.Video, error) {
+			var v plex.Video
+			var watch WatchState
+			scanArgs := append([]interface{}{
+				&v.RatingKey, &v.Title, &v.Index, &v.Duration, &v.Summary,
+				&v.ParentRatingKey, &v.ParentIndex, &v.GrandparentRatingKey, &v.GrandparentTitle,
+			}, watch.Pointers()...)
+			if err := rows.Scan(scanArgs...); err != nil {
+				return v, err
+			}
+			v.Type = "episode"
+			watch.ApplyTo(&v)
+			return v, nil
+		},
+	},
+}
+
+// Search looks up movies, shows and episodes whose title, summary, cast or
+// genres contain every word of query, returning at most limit of each.
+// It uses the FTS5 search_index when available and LIKE queries otherwise.
+func (s *Store) Search(query string, limit int) (*SearchResults, error) {
+	terms := strings.Fields(query)
+	results := &SearchResults{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	useIndex := s.hasSearchIndex()
+	for _, k := range searchKinds {
+		var q string
+		var args []interface{}
+		if useIndex {
+			q = k.from + ` JOIN search_index ON search_index.rowid = t.id
+				WHERE search_index MATCH ? AND search_index.kind = ? ORDER BY search_index.rank LIMIT ?`
+			args = []interface{}{ftsQuery(terms), k.kind, limit}
+		} else {
+			var conds []string
+			for _, term := range terms {
+				var ors []string
+				for _, f := range k.fields {
+					ors = append(ors, f+` LIKE ? ESCAPE '\'`)
+					args = append(args, "%"+escapeLike(term)+"%")
+				}
+				conds = append(conds, "("+strings.Join(ors, " OR ")+")")
+			}
+			q = k.from + ` WHERE ` + strings.Join(conds, " AND ") + ` ORDER BY t.title LIMIT ?`
+			args = append(args, limit)
+		}
+
+		videos, err := s.searchKind(k, q, args)
+		if err != nil {
+			return nil, err
+		}
+		switch k.kind {
+		case "movie":
+			results.Movies = videos
+		case "show":
+			results.Shows = videos
+		case "episode":
+			results.Episodes = videos
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) searchKind(k searchKind, query string, args []interface{}) ([]plex.Video, error) {
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var videos []plex.Video
+	for rows.Next() {
+		v, err := k.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, v)
+	}
+	return videos, rows.Err()
+}
+
+// hasSearchIndex reports whether the FTS5 index exists and can be queried
+// by this build.
+func (s *Store) hasSearchIndex() bool {
+	var n int
+	return s.DB.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type='trigger' AND name = 'search_films_ai'`).Scan(&n) == nil && n > 0
+}
+
+// ftsQuery turns search terms into an FTS5 query matching every term as a
+// prefix, e.g. `"star"* "war"*`.
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"database/sql"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -23,16 +24,17 @@ type MediaInfo struct {
 	VideoCodec      string
 	AudioCodec      string
 	AudioChannels   sql.NullInt64
+	Bitrate         sql.NullInt64 // kbps
 }
 
 // Columns returns the SQL column names for media fields.
 func (MediaInfo) Columns() string {
-	return "video_resolution, video_codec, audio_codec, audio_channels"
+	return "video_resolution, video_codec, audio_codec, audio_channels, bitrate"
 }
 
 // Pointers returns pointers for sql.Scan.
 func (m *MediaInfo) Pointers() []interface{} {
-	return []interface{}{&m.VideoResolution, &m.VideoCodec, &m.AudioCodec, &m.AudioChannels}
+	return []interface{}{&m.VideoResolution, &m.VideoCodec, &m.AudioCodec, &m.AudioChannels, &m.Bitrate}
 }
 
 // ToPlexMedia converts MediaInfo to plex.Media.
@@ -42,18 +44,52 @@ func (m *MediaInfo) ToPlexMedia() plex.Media {
 		VideoCodec:      m.VideoCodec,
 		AudioCodec:      m.AudioCodec,
 		AudioChannels:   int(m.AudioChannels.Int64),
+		Bitrate:         int(m.Bitrate.Int64),
 	}
 }
 
-// ApplyTo sets the Media field on a plex.Video.
+// ApplyTo sets the Media field on a plex.Video, with the part whose key the
+// listings read into v.Key, so that the video can be played.
 func (m *MediaInfo) ApplyTo(v *plex.Video) {
-	v.Media = []plex.Media{m.ToPlexMedia()}
+	media := m.ToPlexMedia()
+	if v.Key != "" {
+		media.Part = []plex.Part{{Key: v.Key}}
+	}
+	v.Media = []plex.Media{media}
+}
+
+// WatchState holds the cached watch progress of a movie or episode.
+type WatchState struct {
+	ViewCount    sql.NullInt64
+	ViewOffset   sql.NullInt64
+	LastViewedAt sql.NullInt64
+}
+
+// Columns returns the SQL column names for watch state fields.
+func (WatchState) Columns() string {
+	return "view_count, view_offset, last_viewed_at"
+}
+
+// Pointers returns pointers for sql.Scan.
+func (w *WatchState) Pointers() []interface{} {
+	return []interface{}{&w.ViewCount, &w.ViewOffset, &w.LastViewedAt}
+}
+
+// ApplyTo sets the watch state fields on a plex.Video.
+func (w *WatchState) ApplyTo(v *plex.Video) {
+	v.ViewCount = int(w.ViewCount.Int64)
+	v.ViewOffset = int(w.ViewOffset.Int64)
+	v.LastViewedAt = w.LastViewedAt.Int64
 }
 
-func (s *Store) ListMovies() ([]plex.Video, error) {
+// ListMovies returns the movies of a library section.
+// Rows synced before sections were tracked (NULL section_key) are included
+// until the next sync backfills them.
+func (s *Store) ListMovies(sectionKey string) ([]plex.Video, error) {
 	var m MediaInfo
-	query := `SELECT id, title, year, part_key, duration, rating, added_at, summary, genres, directors, "cast", originallyAvailableAt, content_rating, studio, ` + m.Columns() + ` FROM films`
-	rows, err := s.DB.Query(query)
+	var w WatchState
+	query := `SELECT id, title, year, part_key, duration, rating, added_at, summary, genres, directors, "cast", originallyAvailableAt, content_rating, studio, ` + m.Columns() + `, ` + w.Columns() + ` FROM films WHERE section_key = ? OR section_key IS NULL`
+	rows, err := s.DB.Query(query, sectionKey)
 	if err != nil {
 		return nil, err
 	}
@@ -64,16 +100,19 @@ func (s *Store) ListMovies() ([]plex.Video, error) {
 		var v plex.Video
 		var genres, directors, cast string
 		var media MediaInfo
+		var watch WatchState
 		scanArgs := append([]interface{}{
 			&v.RatingKey, &v.Title, &v.Year, &v.Key, &v.Duration, &v.Rating, &v.AddedAt,
 			&v.Summary, &genres, &directors, &cast, &v.OriginallyAvailableAt, &v.ContentRating, &v.Studio,
 		}, media.Pointers()...)
+		scanArgs = append(scanArgs, watch.Pointers()...)
 		if err := rows.Scan(scanArgs...); err != nil {
 			return nil, err
 		}
 		v.Type = "movie"
 		applyCommonFields(&v, genres, directors, cast)
 		media.ApplyTo(&v)
+		watch.ApplyTo(&v)
 		videos = append(videos, v)
 	}
 	return videos, nil
@@ -104,9 +143,13 @@ func (s *Store) GetVideoMetadata(id string, isEpisode bool) (*plex.Video, error)
 	return &v, nil
 }
 
-func (s *Store) ListSeries() ([]plex.Video, error) {
-	const query = `SELECT id, title, rating, added_at, summary, genres, directors, "cast", content_rating, studio FROM series`
-	rows, err := s.DB.Query(query)
+// ListSeries returns the shows of a library section, see ListMovies.
+func (s *Store) ListSeries(sectionKey string) ([]plex.Video, error) {
+	const query = `SELECT id, title, rating, added_at, summary, genres, directors, "cast", content_rating, studio,
+		(SELECT count(*) FROM episodes e JOIN seasons se ON se.id = e.season_id WHERE se.series_id = series.id),
+		(SELECT count(*) FROM episodes e JOIN seasons se ON se.id = e.season_id WHERE se.series_id = series.id AND e.view_count > 0)
+		FROM series WHERE section_key = ? OR section_key IS NULL`
+	rows, err := s.DB.Query(query, sectionKey)
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +162,7 @@ func (s *Store) ListSeries() ([]plex.Video, error) {
 		if err := rows.Scan(
 			&v.RatingKey, &v.Title, &v.Rating, &v.AddedAt,
 			&v.Summary, &genres, &directors, &cast, &v.ContentRating, &v.Studio,
+			&v.LeafCount, &v.ViewedLeafCount,
 		); err != nil {
 			return nil, err
 		}
@@ -225,8 +269,28 @@ func (s *Store) ListSections(targetType string) ([]plex.Directory, error) {
 	return sections, nil
 }
 
+// SectionSort returns the sort order last chosen for a library section,
+// or "" when none was saved.
+func (s *Store) SectionSort(sectionKey string) (string, error) {
+	var value string
+	err := s.DB.QueryRow(`SELECT value FROM metadata WHERE key = ?`, "sort_"+sectionKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetSectionSort remembers the sort order of a library section.
+func (s *Store) SetSectionSort(sectionKey, value string) error {
+	_, err := s.DB.Exec(`INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)`, "sort_"+sectionKey, value)
+	return err
+}
+
 func (s *Store) ListSeasons(seriesID string) ([]plex.Directory, error) {
-	const query = `SELECT id, season_index, summary FROM seasons WHERE series_id = ? ORDER BY season_index`
+	const query = `SELECT id, season_index, summary,
+		(SELECT count(*) FROM episodes e WHERE e.season_id = seasons.id),
+		(SELECT count(*) FROM episodes e WHERE e.season_id = seasons.id AND e.view_count > 0)
+		FROM seasons WHERE series_id = ? ORDER BY season_index`
 	rows, err := s.DB.Query(query, seriesID)
 	if err != nil {
 		return nil, err
@@ -237,7 +301,7 @@ func (s *Store) ListSeasons(seriesID string) ([]plex.Directory, error) {
 	for rows.Next() {
 		var d plex.Directory
 		var idx int
-		if err := rows.Scan(&d.RatingKey, &idx, &d.Summary); err != nil {
+		if err := rows.Scan(&d.RatingKey, &idx, &d.Summary, &d.LeafCount, &d.ViewedLeafCount); err != nil {
 			return nil, err
 		}
 		d.Index = strconv.Itoa(idx)
@@ -249,7 +313,8 @@ func (s *Store) ListSeasons(seriesID string) ([]plex.Directory, error) {
 
 func (s *Store) ListEpisodes(seasonID string) ([]plex.Video, error) {
 	var m MediaInfo
-	query := `SELECT id, episode_index, title, part_key, duration, rating, summary, ` + m.Columns() + ` FROM episodes WHERE season_id = ? ORDER BY episode_index`
+	var w WatchState
+	query := `SELECT id, episode_index, title, part_key, duration, rating, summary, ` + m.Columns() + `, ` + w.Columns() + ` FROM episodes WHERE season_id = ? ORDER BY episode_index`
 	rows, err := s.DB.Query(query, seasonID)
 	if err != nil {
 		return nil, err
@@ -260,18 +325,147 @@ func (s *Store) ListEpisodes(seasonID string) ([]plex.Video, error) {
 	for rows.Next() {
 		var v plex.Video
 		var media MediaInfo
+		var watch WatchState
 		scanArgs := append([]interface{}{&v.RatingKey, &v.Index, &v.Title, &v.Key, &v.Duration, &v.Rating, &v.Summary}, media.Pointers()...)
+		scanArgs = append(scanArgs, watch.Pointers()...)
 		if err := rows.Scan(scanArgs...); err != nil {
 			return nil, err
 		}
 		v.Type = "episode"
 		v.ParentRatingKey = seasonID
 		media.ApplyTo(&v)
+		watch.ApplyTo(&v)
 		episodes = append(episodes, v)
 	}
 	return episodes, nil
 }
 
+// ListLibrary returns the movies or shows (sectionType "movie" or "show") of
+// every cached section, or of the section sectionKey when set, sorted by
+// title. Items without a section are listed once.
+func (s *Store) ListLibrary(sectionType, sectionKey string) ([]plex.Video, error) {
+	list := s.ListMovies
+	if sectionType == "show" {
+		list = s.ListSeries
+	}
+
+	keys := []string{sectionKey}
+	if sectionKey == "" {
+		sections, err := s.ListSections(sectionType)
+		if err != nil {
+			return nil, err
+		}
+		keys = keys[:0]
+		for _, sec := range sections {
+			keys = append(keys, sec.Key)
+		}
+		if len(keys) == 0 {
+			keys = append(keys, "") // Cache synced before sections were tracked
+		}
+	}
+
+	seen := map[string]bool{}
+	var items []plex.Video
+	for _, k := range keys {
+		videos, err := list(k)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range videos {
+			if seen[v.RatingKey] {
+				continue
+			}
+			seen[v.RatingKey] = true
+			v.LibrarySectionID = k
+			items = append(items, v)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return strings.ToLower(items[i].Title) < strings.ToLower(items[j].Title)
+	})
+	return items, nil
+}
+
+// ListAllSeasons returns every cached season as a video of type "season",
+// with its show as parent, ordered by show title and season.
+func (s *Store) ListAllSeasons() ([]plex.Video, error) {
+	const query = `SELECT se.id, se.season_index, se.summary, sr.id, sr.title, sr.section_key,
+		(SELECT count(*) FROM episodes e WHERE e.season_id = se.id),
+		(SELECT count(*) FROM episodes e WHERE e.season_id = se.id AND e.view_count > 0)
+		FROM seasons se JOIN series sr ON sr.id = se.series_id
+		ORDER BY sr.title COLLATE NOCASE, se.season_index`
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seasons []plex.Video
+	for rows.Next() {
+		var v plex.Video
+		var summary, sectionKey sql.NullString
+		if err := rows.Scan(&v.RatingKey, &v.Index, &summary, &v.ParentRatingKey, &v.ParentTitle, &sectionKey, &v.LeafCount, &v.ViewedLeafCount); err != nil {
+			return nil, err
+		}
+		v.Type = "season"
+		v.Title = "Season " + strconv.Itoa(v.Index)
+		if v.Index == 0 {
+			v.Title = "Specials"
+		}
+		v.Summary = summary.String
+		v.LibrarySectionID = sectionKey.String
+		seasons = append(seasons, v)
+	}
+	return seasons, rows.Err()
+}
+
+// ListAllEpisodes returns every cached episode with its show, season and
+// library section, ordered by show title, season and episode.
+func (s *Store) ListAllEpisodes() ([]plex.Video, error) {
+	var m MediaInfo
+	var w WatchState
+	query := `SELECT e.id, e.episode_index, e.title, e.part_key, e.duration, e.rating, e.summary, se.id, se.season_index, sr.id, sr.title, sr.section_key, ` +
+		prefixColumns("e", m.Columns()) + `, ` + prefixColumns("e", w.Columns()) + `
+		FROM episodes e JOIN seasons se ON se.id = e.season_id JOIN series sr ON sr.id = se.series_id
+		ORDER BY sr.title COLLATE NOCASE, se.season_index, e.episode_index`
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var episodes []plex.Video
+	for rows.Next() {
+		var v plex.Video
+		var media MediaInfo
+		var watch WatchState
+		var sectionKey sql.NullString
+		scanArgs := append([]interface{}{
+			&v.RatingKey, &v.Index, &v.Title, &v.Key, &v.Duration, &v.Rating, &v.Summary,
+			&v.ParentRatingKey, &v.ParentIndex, &v.GrandparentRatingKey, &v.GrandparentTitle, &sectionKey,
+		}, media.Pointers()...)
+		scanArgs = append(scanArgs, watch.Pointers()...)
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+		v.Type = "episode"
+		v.LibrarySectionID = sectionKey.String
+		media.ApplyTo(&v)
+		watch.ApplyTo(&v)
+		episodes = append(episodes, v)
+	}
+	return episodes, rows.Err()
+}
+
+// prefixColumns qualifies a comma-separated column list with a table alias.
+func prefixColumns(alias, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
 func applyCommonFields(v *plex.Video, genres, directors, cast string) {
 	applyGenres(v, genres)
 	applyDirectors(v, directors)
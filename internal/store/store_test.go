@@ -2,8 +2,10 @@ package store
 
 import (
 	"database/sql"
+	"strings"
 	"testing"
 
+	schema "github.com/Waddenn/plex-client/internal/db"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -14,6 +16,10 @@ func initTestDB(t *testing.T) *sql.DB {
 	}
 
 	queries := []string{
+		`CREATE TABLE IF NOT EXISTS metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT
+		);`,
 		`CREATE TABLE IF NOT EXISTS films (
             id INTEGER PRIMARY KEY,
             title TEXT,
@@ -33,7 +39,12 @@ func initTestDB(t *testing.T) *sql.DB {
             video_resolution TEXT,
             video_codec TEXT,
             audio_codec TEXT,
-            audio_channels INTEGER
+            audio_channels INTEGER,
+            bitrate INTEGER,
+            section_key TEXT,
+            view_count INTEGER DEFAULT 0,
+            view_offset INTEGER DEFAULT 0,
+            last_viewed_at INTEGER DEFAULT 0
         );`,
 		`CREATE TABLE IF NOT EXISTS series (
             id INTEGER PRIMARY KEY,
@@ -46,7 +57,34 @@ func initTestDB(t *testing.T) *sql.DB {
             content_rating TEXT,
             studio TEXT,
             added_at INTEGER,
+            updated_at INTEGER,
+            section_key TEXT
+        );`,
+		`CREATE TABLE IF NOT EXISTS seasons (
+            id INTEGER PRIMARY KEY,
+            series_id INTEGER,
+            season_index INTEGER,
+            summary TEXT,
             updated_at INTEGER
+        );`,
+		`CREATE TABLE IF NOT EXISTS episodes (
+            id INTEGER PRIMARY KEY,
+            season_id INTEGER,
+            episode_index INTEGER,
+            title TEXT,
+            part_key TEXT,
+            duration INTEGER,
+            summary TEXT,
+            rating REAL,
+            updated_at INTEGER,
+            video_resolution TEXT,
+            video_codec TEXT,
+            audio_codec TEXT,
+            audio_channels INTEGER,
+            bitrate INTEGER,
+            view_count INTEGER DEFAULT 0,
+            view_offset INTEGER DEFAULT 0,
+            last_viewed_at INTEGER DEFAULT 0
         );`,
 	}
 	for _, q := range queries {
@@ -61,14 +99,14 @@ func TestStore_ListMovies(t *testing.T) {
 	db := initTestDB(t)
 	defer db.Close()
 
-	_, err := db.Exec(`INSERT INTO films (id, title, year, part_key, duration, summary, rating, genres, directors, "cast", originallyAvailableAt, content_rating, studio, added_at, updated_at, video_resolution, video_codec, audio_codec, audio_channels)
-		VALUES (1, 'Test Movie', 2021, '', 3600, 'Test Summary', 8.5, 'Action', 'John Doe', 'Jane Doe:Lead', '2021-01-01', 'PG-13', 'Studio X', 1600000000, 1600000000, '1080p', 'h264', 'aac', 6)`)
+	_, err := db.Exec(`INSERT INTO films (id, title, year, part_key, duration, summary, rating, genres, directors, "cast", originallyAvailableAt, content_rating, studio, added_at, updated_at, video_resolution, video_codec, audio_codec, audio_channels, section_key)
+		VALUES (1, 'Test Movie', 2021, '', 3600, 'Test Summary', 8.5, 'Action', 'John Doe', 'Jane Doe:Lead', '2021-01-01', 'PG-13', 'Studio X', 1600000000, 1600000000, '1080p', 'h264', 'aac', 6, '1')`)
 	if err != nil {
 		t.Fatalf("Failed to insert movie: %v", err)
 	}
 
 	s := New(db)
-	movies, err := s.ListMovies()
+	movies, err := s.ListMovies("1")
 	if err != nil {
 		t.Fatalf("ListMovies failed: %v", err)
 	}
@@ -80,20 +118,24 @@ func TestStore_ListMovies(t *testing.T) {
 	if movies[0].Title != "Test Movie" {
 		t.Errorf("Expected title 'Test Movie', got '%s'", movies[0].Title)
 	}
+
+	if movies[0].ViewCount != 0 || movies[0].ViewOffset != 0 {
+		t.Errorf("Expected unwatched movie, got viewCount=%d viewOffset=%d", movies[0].ViewCount, movies[0].ViewOffset)
+	}
 }
 
 func TestStore_ListSeries(t *testing.T) {
 	db := initTestDB(t)
 	defer db.Close()
 
-	_, err := db.Exec(`INSERT INTO series (id, title, summary, rating, genres, directors, "cast", content_rating, studio, added_at, updated_at) 
-		VALUES (1, 'Test Series', 'Test Summary', 9.0, 'Drama', 'Alice Smith', 'Bob Brown:Hero', 'TV-MA', 'Network Y', 1600000000, 1600000000)`)
+	_, err := db.Exec(`INSERT INTO series (id, title, summary, rating, genres, directors, "cast", content_rating, studio, added_at, updated_at, section_key) 
+		VALUES (1, 'Test Series', 'Test Summary', 9.0, 'Drama', 'Alice Smith', 'Bob Brown:Hero', 'TV-MA', 'Network Y', 1600000000, 1600000000, '2')`)
 	if err != nil {
 		t.Fatalf("Failed to insert series: %v", err)
 	}
 
 	s := New(db)
-	series, err := s.ListSeries()
+	series, err := s.ListSeries("2")
 	if err != nil {
 		t.Fatalf("ListSeries failed: %v", err)
 	}
@@ -106,3 +148,171 @@ func TestStore_ListSeries(t *testing.T) {
 		t.Errorf("Expected title 'Test Series', got '%s'", series[0].Title)
 	}
 }
+
+func TestStore_ListMoviesBySection(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`INSERT INTO films (id, title, year, part_key, duration, summary, rating, genres, directors, "cast", originallyAvailableAt, content_rating, studio, added_at, updated_at, video_resolution, video_codec, audio_codec, audio_channels, section_key) VALUES
+		(1, 'Grown-up Movie', 2020, '', 0, '', 0, '', '', '', '', '', '', 0, 0, '', '', '', 0, '1'),
+		(2, 'Kids Movie', 2021, '', 0, '', 0, '', '', '', '', '', '', 0, 0, '', '', '', 0, '2'),
+		(3, 'Legacy Movie', 2019, '', 0, '', 0, '', '', '', '', '', '', 0, 0, '', '', '', 0, NULL)`)
+	if err != nil {
+		t.Fatalf("Failed to insert movies: %v", err)
+	}
+
+	s := New(db)
+	movies, err := s.ListMovies("2")
+	if err != nil {
+		t.Fatalf("ListMovies failed: %v", err)
+	}
+
+	titles := map[string]bool{}
+	for _, m := range movies {
+		titles[m.Title] = true
+	}
+	if len(movies) != 2 || !titles["Kids Movie"] || !titles["Legacy Movie"] {
+		t.Errorf("Expected 'Kids Movie' and unscoped 'Legacy Movie', got %v", titles)
+	}
+}
+
+func TestStore_SectionSort(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	s := New(db)
+	if got, err := s.SectionSort("1"); err != nil || got != "" {
+		t.Fatalf("Expected no saved sort, got %q (err %v)", got, err)
+	}
+
+	if err := s.SetSectionSort("1", "year:asc"); err != nil {
+		t.Fatalf("SetSectionSort failed: %v", err)
+	}
+	if err := s.SetSectionSort("1", "rating:desc"); err != nil {
+		t.Fatalf("SetSectionSort failed: %v", err)
+	}
+	if got, _ := s.SectionSort("1"); got != "rating:desc" {
+		t.Errorf("Expected 'rating:desc', got %q", got)
+	}
+	if got, _ := s.SectionSort("2"); got != "" {
+		t.Errorf("Expected sorts to be per section, got %q for section 2", got)
+	}
+}
+
+func TestStore_Search(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	// Uses FTS5 when built with the sqlite_fts5 tag, LIKE queries otherwise.
+	if _, err := schema.EnableSearchIndex(db); err != nil {
+		t.Fatalf("EnableSearchIndex failed: %v", err)
+	}
+
+	queries := []string{
+		`INSERT INTO films (id, title, year, part_key, duration, summary, rating, genres, directors, "cast", originallyAvailableAt, content_rating, studio, added_at, updated_at, video_resolution, video_codec, audio_codec, audio_channels, section_key) VALUES
+			(1, 'Alien', 1979, '/library/parts/1/file.mkv', 0, 'A crew meets a creature.', 0, 'Horror, Science Fiction', '', 'Sigourney Weaver:Ripley', '', '', '', 0, 0, '', '', '', 0, '1'),
+			(2, 'Heat', 1995, '', 0, 'A heist in Los Angeles.', 0, 'Crime', '', 'Al Pacino:Vincent Hanna', '', '', '', 0, 0, '', '', '', 0, '1')`,
+		`INSERT INTO series (id, title, summary, rating, genres, directors, "cast", content_rating, studio, added_at, updated_at, section_key) VALUES
+			(10, 'The Expanse', 'Space politics.', 0, 'Science Fiction', '', 'Steven Strait:Holden', '', '', 0, 0, '2')`,
+		`INSERT INTO seasons (id, series_id, season_index, summary, updated_at) VALUES (11, 10, 1, '', 0)`,
+		`INSERT INTO episodes (id, season_id, episode_index, title, part_key, duration, summary, rating, updated_at) VALUES
+			(12, 11, 3, 'Remember the Cant', '', 0, 'Holden meets an alien threat.', 0, 0)`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	s := New(db)
+
+	tests := []struct {
+		query                   string
+		movies, shows, episodes int
+	}{
+		{"alien", 1, 0, 1},     // Title and summary
+		{"weaver", 1, 0, 0},    // Cast
+		{"science", 1, 1, 0},   // Genres
+		{"holden", 0, 1, 1},    // Cast and summary
+		{"heist los", 1, 0, 0}, // Every word must match
+		{"heist alien", 0, 0, 0},
+		{"   ", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		res, err := s.Search(tt.query, 10)
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", tt.query, err)
+		}
+		if len(res.Movies) != tt.movies || len(res.Shows) != tt.shows || len(res.Episodes) != tt.episodes {
+			t.Errorf("Search(%q): expected %d/%d/%d results, got %d/%d/%d", tt.query,
+				tt.movies, tt.shows, tt.episodes, len(res.Movies), len(res.Shows), len(res.Episodes))
+		}
+	}
+
+	res, err := s.Search("alien", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if m := res.Movies[0]; len(m.Media) == 0 || len(m.Media[0].Part) == 0 || m.LibrarySectionID != "1" {
+		t.Errorf("Expected playable movie in section 1, got %+v", m)
+	}
+	if e := res.Episodes[0]; e.GrandparentTitle != "The Expanse" || e.ParentIndex != 1 || e.Index != 3 {
+		t.Errorf("Expected episode S01E03 of 'The Expanse', got %+v", e)
+	}
+}
+
+func TestStore_ListAllEpisodes(t *testing.T) {
+	db := initTestDB(t)
+	defer db.Close()
+
+	queries := []string{
+		`INSERT INTO series (id, title, summary, rating, genres, directors, "cast", content_rating, studio, added_at, updated_at, section_key) VALUES
+			(10, 'The Expanse', '', 0, '', '', '', '', '', 0, 0, '2'),
+			(20, 'Andor', '', 0, '', '', '', '', '', 0, 0, '2')`,
+		`INSERT INTO seasons (id, series_id, season_index, summary, updated_at) VALUES (11, 10, 2, '', 0), (12, 10, 1, '', 0), (21, 20, 1, '', 0)`,
+		`INSERT INTO episodes (id, season_id, episode_index, title, part_key, duration, summary, rating, updated_at, video_resolution, video_codec, audio_codec, audio_channels, view_count) VALUES
+			(100, 11, 1, 'Doors & Corners', '', 0, '', 0, 0, '', '', '', 0, 0),
+			(101, 12, 2, 'The Big Empty', '', 0, '', 0, 0, '', '', '', 0, 1),
+			(102, 12, 1, 'Dulcinea', '', 0, '', 0, 0, '', '', '', 0, 1),
+			(200, 21, 1, 'Kassa', '', 0, '', 0, 0, '', '', '', 0, 0)`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	episodes, err := New(db).ListAllEpisodes()
+	if err != nil {
+		t.Fatalf("ListAllEpisodes failed: %v", err)
+	}
+	var got []string
+	for _, e := range episodes {
+		got = append(got, e.RatingKey)
+	}
+	if want := "200 102 101 100"; strings.Join(got, " ") != want {
+		t.Fatalf("Expected episodes %s, got %v", want, got)
+	}
+	if e := episodes[1]; e.GrandparentTitle != "The Expanse" || e.ParentIndex != 1 || e.Index != 1 || e.ViewCount != 1 || e.LibrarySectionID != "2" {
+		t.Errorf("Expected watched S01E01 of 'The Expanse' in section 2, got %+v", e)
+	}
+
+	// Shows and seasons count their watched episodes
+	s := New(db)
+	series, err := s.ListSeries("2")
+	if err != nil {
+		t.Fatalf("ListSeries failed: %v", err)
+	}
+	for _, sr := range series {
+		if sr.RatingKey == "10" && (sr.LeafCount != 3 || sr.ViewedLeafCount != 2) {
+			t.Errorf("Expected 2 of 3 episodes watched for 'The Expanse', got %d of %d", sr.ViewedLeafCount, sr.LeafCount)
+		}
+	}
+	seasons, err := s.ListSeasons("10")
+	if err != nil {
+		t.Fatalf("ListSeasons failed: %v", err)
+	}
+	if len(seasons) != 2 || seasons[0].LeafCount != 2 || seasons[0].ViewedLeafCount != 2 || seasons[1].ViewedLeafCount != 0 {
+		t.Errorf("Expected season 1 fully watched and season 2 not, got %+v", seasons)
+	}
+}
@@ -43,11 +43,11 @@ func fetchSections(p *plex.Client, targetType string) tea.Cmd {
 	}
 }
 
-func fetchLibraryItemsFromStore(s *store.Store, targetType string) ([]plex.Video, error) {
+func fetchLibraryItemsFromStore(s *store.Store, targetType, sectionKey string) ([]plex.Video, error) {
 	if targetType == "movie" {
-		return s.ListMovies()
+		return s.ListMovies(sectionKey)
 	}
-	return s.ListSeries()
+	return s.ListSeries(sectionKey)
 }
 
 func fetchSectionsFromStore(s *store.Store, targetType string) ([]plex.Directory, error) {
@@ -0,0 +1,44 @@
+package browser
+
+import (
+	"strconv"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// download requests the selected movie, episode or season for offline
+// playback. Whole shows are left out; their seasons can be picked one by
+// one.
+func (m *Model) download() tea.Cmd {
+	list := m.getFilteredList()
+	if m.cursor >= len(list) {
+		return nil
+	}
+	msg := shared.MsgDownload{Show: m.selectedShowTitle}
+	switch item := list[m.cursor].(type) {
+	case plex.Video:
+		if item.Type == "show" {
+			return nil
+		}
+		if item.Type == "episode" {
+			// Name the episode in the downloads before its metadata is fetched
+			if item.GrandparentTitle == "" {
+				item.GrandparentTitle = m.selectedShowTitle
+			}
+			for _, s := range m.seasons {
+				if s.RatingKey == item.ParentRatingKey && item.ParentIndex == 0 {
+					item.ParentIndex, _ = strconv.Atoi(s.Index)
+				}
+			}
+		}
+		msg.Item = item
+	case plex.Directory:
+		if item.Type != "season" {
+			return nil
+		}
+		msg.Item = item
+	}
+	return func() tea.Msg { return msg }
+}
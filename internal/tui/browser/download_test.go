@@ -0,0 +1,53 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDownload(t *testing.T) {
+	m := NewModel(nil, nil, false, "badges")
+	m.items = []plex.Video{{RatingKey: "10", Title: "The Expanse", Type: "show"}}
+	m.selectedShowTitle = "The Expanse"
+	m.seasons = []plex.Directory{{RatingKey: "11", Title: "Season 2", Type: "season", Index: "2"}}
+	m.episodes = []plex.Video{{RatingKey: "101", Type: "episode", Title: "Doors & Corners", ParentRatingKey: "11", Index: 3}}
+	m.needsRefresh = true
+
+	download := func() (shared.MsgDownload, bool) {
+		t.Helper()
+		cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+		if cmd == nil {
+			return shared.MsgDownload{}, false
+		}
+		msg, ok := cmd().(shared.MsgDownload)
+		if !ok {
+			t.Fatalf("Expected MsgDownload, got %T", cmd())
+		}
+		return msg, true
+	}
+
+	// Whole shows are not downloaded at once
+	m.mode = ModeItems
+	if msg, ok := download(); ok {
+		t.Errorf("Expected no download of a show, got %+v", msg)
+	}
+
+	m.mode = ModeSeasons
+	m.needsRefresh = true
+	msg, ok := download()
+	if season, isSeason := msg.Item.(plex.Directory); !ok || !isSeason || season.RatingKey != "11" || msg.Show != "The Expanse" {
+		t.Errorf("Expected season 11 of The Expanse, got %+v", msg)
+	}
+
+	// Episodes are named after their show and season until the download starts
+	m.mode = ModeEpisodes
+	m.needsRefresh = true
+	msg, ok = download()
+	ep, isVideo := msg.Item.(plex.Video)
+	if !ok || !isVideo || ep.RatingKey != "101" || ep.GrandparentTitle != "The Expanse" || ep.ParentIndex != 2 {
+		t.Errorf("Expected episode 101 of The Expanse season 2, got %+v", msg.Item)
+	}
+}
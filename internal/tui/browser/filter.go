@@ -1,10 +1,10 @@
 package browser
 
 import (
-	"sort"
 	"strings"
 
 	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/query"
 )
 
 // Helper methods for filtering
@@ -21,6 +21,19 @@ func (m *Model) getFilteredList() []interface{} {
 	filter := strings.ToLower(m.textInput.Value())
 	var result []interface{}
 
+	// Keep showing the last valid filter while the query is mistyped;
+	// the error is shown in the search bar.
+	match, err := query.Parse(m.textInput.Value())
+	m.filterErr = err
+	if err != nil {
+		match = m.lastFilter
+	} else {
+		m.lastFilter = match
+	}
+	if match == nil {
+		match = query.MatchAll
+	}
+
 	switch m.mode {
 	case ModeSections:
 		for _, s := range m.sections {
@@ -29,7 +42,7 @@ func (m *Model) getFilteredList() []interface{} {
 			}
 		}
 	case ModeItems:
-		result = filterAndSortVideos(m.items, filter, m.sortMethod)
+		result = filterAndSortVideos(m.items, match, m.sort)
 	case ModeSeasons:
 		for _, s := range m.seasons {
 			if filter == "" || strings.Contains(strings.ToLower(s.Title), filter) {
@@ -37,7 +50,7 @@ func (m *Model) getFilteredList() []interface{} {
 			}
 		}
 	case ModeEpisodes:
-		result = filterAndSortVideos(m.episodes, filter, m.sortMethod)
+		result = filterAndSortVideos(m.episodes, match, m.sort)
 	}
 
 	m.filteredList = result
@@ -45,51 +58,15 @@ func (m *Model) getFilteredList() []interface{} {
 	return result
 }
 
-func filterAndSortVideos(videos []plex.Video, filter string, sortMethod SortMethod) []interface{} {
+func filterAndSortVideos(videos []plex.Video, match query.Filter, s Sort) []interface{} {
 	var filtered []plex.Video
 	for _, v := range videos {
-		match := false
-		if filter == "" {
-			match = true
-		} else {
-			lowTitle := strings.ToLower(v.Title)
-			if strings.Contains(lowTitle, filter) {
-				match = true
-			} else {
-				// Also search in director names
-				for _, d := range v.Director {
-					if strings.Contains(strings.ToLower(d.Tag), filter) {
-						match = true
-						break
-					}
-				}
-			}
-		}
-
-		if match {
+		if match(v) {
 			filtered = append(filtered, v)
 		}
 	}
 
-	// Sort using sort.Slice (O(n log n))
-	sort.Slice(filtered, func(i, j int) bool {
-		switch sortMethod {
-		case SortYear:
-			if filtered[i].Year != filtered[j].Year {
-				return filtered[i].Year > filtered[j].Year // Newest first
-			}
-		case SortRating:
-			if filtered[i].Rating != filtered[j].Rating {
-				return filtered[i].Rating > filtered[j].Rating // Best first
-			}
-		case SortDateAdded:
-			if filtered[i].AddedAt != filtered[j].AddedAt {
-				return filtered[i].AddedAt > filtered[j].AddedAt // Newest first
-			}
-		}
-		// Default: Title
-		return strings.ToLower(filtered[i].Title) < strings.ToLower(filtered[j].Title)
-	})
+	sortVideos(filtered, s)
 
 	var final []interface{}
 	for _, v := range filtered {
@@ -0,0 +1,37 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+)
+
+func TestGetFilteredList_KeepsLastValidFilter(t *testing.T) {
+	m := NewModel(nil, nil, false, "")
+	m.mode = ModeItems
+	m.items = []plex.Video{
+		{Title: "Alien", Genre: []plex.Tag{{Tag: "Horror"}}},
+		{Title: "Carol", Genre: []plex.Tag{{Tag: "Drama"}}},
+	}
+
+	m.textInput.SetValue("genre:horror")
+	m.needsRefresh = true
+	if got := m.getFilteredList(); len(got) != 1 || m.filterErr != nil {
+		t.Fatalf("Expected 1 match without error, got %d (err %v)", len(got), m.filterErr)
+	}
+
+	m.textInput.SetValue(`genre:horror actor:"Sig`)
+	m.needsRefresh = true
+	if got := m.getFilteredList(); len(got) != 1 {
+		t.Errorf("Expected the last valid filter to stay applied, got %d items", len(got))
+	}
+	if m.filterErr == nil {
+		t.Errorf("Expected a parse error for the unterminated quote")
+	}
+
+	m.textInput.SetValue("")
+	m.needsRefresh = true
+	if got := m.getFilteredList(); len(got) != 2 || m.filterErr != nil {
+		t.Errorf("Expected all items after clearing, got %d (err %v)", len(got), m.filterErr)
+	}
+}
@@ -21,3 +21,8 @@ type MsgChildrenLoaded struct {
 	Videos   []plex.Video
 	Err      error
 }
+
+// MsgSortSaved reports whether a section's sort order was remembered.
+type MsgSortSaved struct {
+	Err error
+}
@@ -0,0 +1,204 @@
+package browser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Sort is an ordering of the item list and its direction.
+type Sort struct {
+	Method SortMethod
+	Desc   bool
+}
+
+// sortKeys are the names used by ui.sort_by and the saved section sorts.
+// The first name of each method is the one written back.
+var sortKeys = []struct {
+	name   string
+	method SortMethod
+}{
+	{"title", SortTitle},
+	{"year", SortYear},
+	{"rating", SortRating},
+	{"added", SortDateAdded},
+	{"date_added", SortDateAdded},
+	{"recently_added", SortDateAdded},
+	{"recent", SortDateAdded},
+	{"duration", SortDuration},
+	{"release_date", SortReleaseDate},
+	{"release", SortReleaseDate},
+	{"date", SortReleaseDate},
+	{"last_watched", SortLastWatched},
+	{"last_viewed", SortLastWatched},
+}
+
+// DefaultDesc reports whether s naturally lists the largest values first:
+// everything but titles shows the newest, best or longest items on top.
+func (s SortMethod) DefaultDesc() bool {
+	return s != SortTitle
+}
+
+// Key returns the config name of s, e.g. "release_date".
+func (s SortMethod) Key() string {
+	for _, k := range sortKeys {
+		if k.method == s {
+			return k.name
+		}
+	}
+	return "title"
+}
+
+// ParseSort parses a sort such as "title", "year" or "rating:asc".
+// Without a direction the method's natural one is used.
+func ParseSort(s string) (Sort, error) {
+	name, dir, hasDir := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	if name == "" {
+		return Sort{}, nil
+	}
+
+	var res Sort
+	found := false
+	for _, k := range sortKeys {
+		if k.name == name {
+			res = Sort{Method: k.method, Desc: k.method.DefaultDesc()}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return Sort{}, fmt.Errorf("unknown sort %q", name)
+	}
+
+	if hasDir {
+		switch dir {
+		case "asc":
+			res.Desc = false
+		case "desc":
+			res.Desc = true
+		default:
+			return Sort{}, fmt.Errorf("unknown sort direction %q (use asc or desc)", dir)
+		}
+	}
+	return res, nil
+}
+
+// String returns the form accepted by ParseSort, e.g. "year:desc".
+func (s Sort) String() string {
+	dir := "asc"
+	if s.Desc {
+		dir = "desc"
+	}
+	return s.Method.Key() + ":" + dir
+}
+
+// Label describes s for the footer, e.g. "Year ↓".
+func (s Sort) Label() string {
+	if s.Desc {
+		return s.Method.String() + " ↓"
+	}
+	return s.Method.String() + " ↑"
+}
+
+// Next cycles to the following method in its natural direction.
+func (s Sort) Next() Sort {
+	next := s.Method + 1
+	if next > SortLastWatched {
+		next = SortTitle
+	}
+	return Sort{Method: next, Desc: next.DefaultDesc()}
+}
+
+// value returns the numeric sort key of v; 0 means unknown.
+func (s SortMethod) value(v plex.Video) float64 {
+	switch s {
+	case SortYear:
+		return float64(v.Year)
+	case SortRating:
+		return v.Rating
+	case SortDateAdded:
+		return float64(v.AddedAt)
+	case SortDuration:
+		return float64(v.Duration)
+	case SortReleaseDate:
+		if t, err := time.Parse("2006-01-02", v.OriginallyAvailableAt); err == nil {
+			return float64(t.Unix())
+		}
+		if v.Year != 0 {
+			return float64(time.Date(v.Year, time.January, 1, 0, 0, 0, 0, time.UTC).Unix())
+		}
+	case SortLastWatched:
+		return float64(v.LastViewedAt)
+	}
+	return 0
+}
+
+// sortVideos orders videos by s. Items missing the sort value (never
+// watched, no release date...) go last in both directions, and ties fall
+// back to the title.
+func sortVideos(videos []plex.Video, s Sort) {
+	sort.SliceStable(videos, func(i, j int) bool {
+		a, b := videos[i], videos[j]
+		if s.Method != SortTitle {
+			va, vb := s.Method.value(a), s.Method.value(b)
+			switch {
+			case va == vb:
+			case va == 0:
+				return false
+			case vb == 0:
+				return true
+			case s.Desc:
+				return va > vb
+			default:
+				return va < vb
+			}
+		}
+		ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title)
+		if s.Method == SortTitle && s.Desc {
+			return ta > tb
+		}
+		return ta < tb
+	})
+}
+
+// loadSectionSort restores the sort of the current section: the one picked
+// this session, else the saved one, else DefaultSort.
+func (m *Model) loadSectionSort() {
+	m.needsRefresh = true
+	if s, ok := m.sectionSort[m.sectionKey]; ok {
+		m.sort = s
+		return
+	}
+	m.sort = m.DefaultSort
+	if m.sectionKey == "" || m.store == nil {
+		return
+	}
+	if saved, err := m.store.SectionSort(m.sectionKey); err == nil && saved != "" {
+		if s, err := ParseSort(saved); err == nil {
+			m.sort = s
+		}
+	}
+}
+
+// setSort applies s and remembers it for the current section.
+func (m *Model) setSort(s Sort) tea.Cmd {
+	m.sort = s
+	m.needsRefresh = true
+	m.sectionSort[m.sectionKey] = s
+	if m.sectionKey == "" || m.store == nil {
+		return nil
+	}
+	return m.Work.Cmd(saveSortInBackground(m.store, m.sectionKey, s))
+}
+
+func saveSortInBackground(st *store.Store, sectionKey string, s Sort) tea.Cmd {
+	return func() tea.Msg {
+		err := st.SetSectionSort(sectionKey, s.String())
+		return MsgSortSaved{Err: err}
+	}
+}
@@ -0,0 +1,133 @@
+package browser
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Sort
+		wantErr bool
+	}{
+		{in: "", want: Sort{Method: SortTitle}},
+		{in: "title", want: Sort{Method: SortTitle}},
+		{in: "title:desc", want: Sort{Method: SortTitle, Desc: true}},
+		{in: "year", want: Sort{Method: SortYear, Desc: true}},
+		{in: "Year:ASC", want: Sort{Method: SortYear}},
+		{in: "rating", want: Sort{Method: SortRating, Desc: true}},
+		{in: "date_added", want: Sort{Method: SortDateAdded, Desc: true}},
+		{in: "duration:asc", want: Sort{Method: SortDuration}},
+		{in: "release_date", want: Sort{Method: SortReleaseDate, Desc: true}},
+		{in: "last_watched", want: Sort{Method: SortLastWatched, Desc: true}},
+		{in: "recent", want: Sort{Method: SortDateAdded, Desc: true}},
+		{in: "popularity", wantErr: true},
+		{in: "year:up", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSort(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSort(%q): expected error, got %+v", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSort(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSort(%q): expected %+v, got %+v", tt.in, tt.want, got)
+		}
+		// Saved sorts must read back unchanged
+		if back, err := ParseSort(got.String()); err != nil || back != got {
+			t.Errorf("ParseSort(%q) round trip: got %+v (err %v)", got.String(), back, err)
+		}
+	}
+}
+
+func TestSortVideos(t *testing.T) {
+	videos := []plex.Video{
+		{Title: "Brazil", Year: 1985, Duration: 8580000, OriginallyAvailableAt: "1985-02-20", LastViewedAt: 200},
+		{Title: "alien", Year: 1979, Duration: 7020000, OriginallyAvailableAt: "1979-05-25"},
+		{Title: "Carol", Year: 2015, Duration: 7080000, LastViewedAt: 100},
+		{Title: "Dune"},
+	}
+
+	tests := []struct {
+		sort Sort
+		want string
+	}{
+		{sort: Sort{Method: SortTitle}, want: "alien,Brazil,Carol,Dune"},
+		{sort: Sort{Method: SortTitle, Desc: true}, want: "Dune,Carol,Brazil,alien"},
+		{sort: Sort{Method: SortYear, Desc: true}, want: "Carol,Brazil,alien,Dune"},
+		{sort: Sort{Method: SortYear}, want: "alien,Brazil,Carol,Dune"},
+		{sort: Sort{Method: SortDuration, Desc: true}, want: "Brazil,Carol,alien,Dune"},
+		// Carol has no release date, so its year stands in
+		{sort: Sort{Method: SortReleaseDate, Desc: true}, want: "Carol,Brazil,alien,Dune"},
+		{sort: Sort{Method: SortLastWatched, Desc: true}, want: "Brazil,Carol,alien,Dune"},
+		{sort: Sort{Method: SortLastWatched}, want: "Carol,Brazil,alien,Dune"},
+	}
+
+	for _, tt := range tests {
+		sorted := append([]plex.Video(nil), videos...)
+		sortVideos(sorted, tt.sort)
+		var titles []string
+		for _, v := range sorted {
+			titles = append(titles, v.Title)
+		}
+		if got := strings.Join(titles, ","); got != tt.want {
+			t.Errorf("sortVideos(%s): expected %s, got %s", tt.sort, tt.want, got)
+		}
+	}
+}
+
+func TestSectionSort_RememberedPerSection(t *testing.T) {
+	m := NewModel(nil, nil, false, "")
+	m.DefaultSort = Sort{Method: SortYear, Desc: true}
+
+	m.sectionKey = "1"
+	m.loadSectionSort()
+	if m.sort != m.DefaultSort {
+		t.Fatalf("Expected the default sort for a new section, got %+v", m.sort)
+	}
+	m.setSort(Sort{Method: SortRating})
+
+	m.sectionKey = "2"
+	m.loadSectionSort()
+	if m.sort != m.DefaultSort {
+		t.Errorf("Expected section 2 to keep the default sort, got %+v", m.sort)
+	}
+
+	m.sectionKey = "1"
+	m.loadSectionSort()
+	if want := (Sort{Method: SortRating}); m.sort != want {
+		t.Errorf("Expected section 1 to restore %+v, got %+v", want, m.sort)
+	}
+}
+
+func TestSectionSort_SaveError(t *testing.T) {
+	d, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	m := NewModel(nil, store.New(d), false, "")
+	m.sectionKey = "1"
+	cmd := m.setSort(Sort{Method: SortRating})
+	if cmd == nil {
+		t.Fatal("Expected the sort to be saved")
+	}
+	m.Update(cmd())
+	if !strings.Contains(m.Notice, "sort order") {
+		t.Errorf("Expected a notice about the sort order, got %q", m.Notice)
+	}
+}
@@ -25,14 +25,14 @@ func saveSectionsInBackground(db *sql.DB, sections []plex.Directory) tea.Cmd {
 	}
 }
 
-func saveItemsInBackground(db *sql.DB, items []plex.Video, itemType string) tea.Cmd {
+func saveItemsInBackground(db *sql.DB, sectionKey string, items []plex.Video, itemType string) tea.Cmd {
 	return func() tea.Msg {
 		added := 0
 		var err error
 		if itemType == "show" {
-			err = cache.SaveSeries(db, convertToDirs(items), &added, nil)
+			err = cache.SaveSeries(db, sectionKey, convertToDirs(items), &added, nil)
 		} else {
-			err = cache.SaveMovies(db, items, &added, nil)
+			err = cache.SaveMovies(db, sectionKey, items, &added, nil)
 		}
 		return MsgBackgroundSyncFinished{Added: added, Error: err}
 	}
@@ -2,7 +2,9 @@ package browser
 
 import (
 	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/query"
 	"github.com/Waddenn/plex-client/internal/store"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -23,6 +25,9 @@ const (
 	SortYear
 	SortRating
 	SortDateAdded
+	SortDuration
+	SortReleaseDate
+	SortLastWatched
 )
 
 func (s SortMethod) String() string {
@@ -35,6 +40,12 @@ func (s SortMethod) String() string {
 		return "Rating"
 	case SortDateAdded:
 		return "Recently Added"
+	case SortDuration:
+		return "Duration"
+	case SortReleaseDate:
+		return "Release Date"
+	case SortLastWatched:
+		return "Last Watched"
 	default:
 		return "Unknown"
 	}
@@ -61,16 +72,22 @@ type Model struct {
 
 	// Filter
 	targetType string // "movie" or "show"
+	sectionKey string // Library section currently shown in ModeItems
 
 	// Search
 	textInput  textinput.Model
 	showSearch bool
+	filterErr  error        // Parse error of the current query, if any
+	lastFilter query.Filter // Last query that parsed, applied while filterErr is set
 
 	// Sorting
-	sortMethod SortMethod
+	sort        Sort
+	sectionSort map[string]Sort // Sorts chosen this session, by section key
+	DefaultSort Sort            // From ui.sort_by, for sections without a saved sort
 
 	// Navigation context
 	selectedShowTitle string // Title of the selected show (for breadcrumbs)
+	selectedShowKey   string // Rating key of the selected show
 
 	// Error handling
 	errorMsg string
@@ -83,15 +100,21 @@ type Model struct {
 	SyncStatus string
 	AutoSync   bool
 
+	// Notice is shown in the header, such as watch changes waiting for Plex
+	Notice string
+
+	// Work runs the background saves to the cache
+	Work *shared.Work
+
 	// UI Config
 	StatusIndicatorStyle string
 }
 
 func NewModel(p *plex.Client, s *store.Store, autoSync bool, statusIndicatorStyle string) Model {
 	ti := textinput.New()
-	ti.Placeholder = "Search..."
+	ti.Placeholder = "Search... (genre:drama year:1990..1999 unwatched)"
 	ti.CharLimit = 156
-	ti.Width = 30
+	ti.Width = 50
 
 	return Model{
 		plexClient:           p,
@@ -102,6 +125,7 @@ func NewModel(p *plex.Client, s *store.Store, autoSync bool, statusIndicatorStyl
 		mode:                 ModeSections,
 		textInput:            ti,
 		needsRefresh:         true,
+		sectionSort:          make(map[string]Sort),
 		AutoSync:             autoSync,
 		StatusIndicatorStyle: statusIndicatorStyle,
 	}
@@ -114,6 +138,8 @@ func (m *Model) Init() tea.Cmd {
 // SetType allows the main model to configure this browser before switching to it
 func (m *Model) SetType(t string) tea.Cmd {
 	m.targetType = t
+	m.sectionKey = ""
+	m.loadSectionSort()
 	m.mode = ModeSections
 	m.loading = true
 	m.cursor = 0
@@ -145,8 +171,10 @@ func (m *Model) SetType(t string) tea.Cmd {
 		if len(m.sections) == 1 {
 			section := m.sections[0]
 			m.mode = ModeItems
+			m.sectionKey = section.Key
+			m.loadSectionSort()
 			m.loading = true
-			dbItems, err := fetchLibraryItemsFromStore(m.store, m.targetType)
+			dbItems, err := fetchLibraryItemsFromStore(m.store, m.targetType, m.sectionKey)
 			if err != nil {
 				m.errorMsg = "Database error: " + err.Error()
 				m.loading = false
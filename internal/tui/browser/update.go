@@ -33,6 +33,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				m.showSearch = false
 				m.textInput.Reset()
 				m.cursor = 0
+				m.needsRefresh = true
 				return nil
 			}
 			var tiCmd tea.Cmd
@@ -64,12 +65,12 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 
 		case "s": // Cycle sort
 			if !m.showSearch {
-				m.sortMethod = (m.sortMethod + 1)
-				if m.sortMethod > SortDateAdded {
-					m.sortMethod = SortTitle
-				}
-				m.needsRefresh = true
-				return nil
+				return m.setSort(m.sort.Next())
+			}
+
+		case "S": // Reverse sort
+			if !m.showSearch {
+				return m.setSort(Sort{Method: m.sort.Method, Desc: !m.sort.Desc})
 			}
 
 		case "q":
@@ -82,6 +83,21 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				return func() tea.Msg { return shared.MsgManualSync{} }
 			}
 
+		case "w": // Toggle watched
+			if !m.showSearch && m.mode != ModeSections {
+				return m.toggleWatched()
+			}
+
+		case "x": // Clear progress
+			if !m.showSearch && m.mode != ModeSections {
+				return m.clearProgress()
+			}
+
+		case "d": // Download for offline playback
+			if !m.showSearch && m.mode != ModeSections {
+				return m.download()
+			}
+
 		case "up", "k":
 			if m.cursor > 0 {
 				m.cursor--
@@ -107,6 +123,8 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 					return func() tea.Msg { return shared.MsgBack{} }
 				}
 				m.mode = ModeSections
+				m.sectionKey = ""
+				m.loadSectionSort()
 				m.cursor = 0
 				m.showSearch = false
 				m.textInput.Reset()
@@ -116,6 +134,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 			} else if m.mode == ModeSeasons {
 				m.mode = ModeItems
 				m.selectedShowTitle = "" // Clear show title when going back
+				m.selectedShowKey = ""
 				m.cursor = 0
 				m.showSearch = false
 				m.textInput.Reset()
@@ -127,6 +146,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				if len(m.seasons) == 0 {
 					m.mode = ModeItems
 					m.selectedShowTitle = "" // Clear show title when going back
+					m.selectedShowKey = ""
 				} else {
 					m.mode = ModeSeasons
 				}
@@ -149,6 +169,8 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				case plex.Directory: // Section or Season
 					if m.mode == ModeSections {
 						m.mode = ModeItems
+						m.sectionKey = item.Key
+						m.loadSectionSort()
 						m.loading = true
 						m.cursor = 0
 						m.showSearch = false // Reset search when drilling down
@@ -160,7 +182,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 						m.items = nil
 
 						// Instant load from DB
-						if dbItems, err := fetchLibraryItemsFromStore(m.store, m.targetType); err == nil && len(dbItems) > 0 {
+						if dbItems, err := fetchLibraryItemsFromStore(m.store, m.targetType, m.sectionKey); err == nil && len(dbItems) > 0 {
 							m.items = dbItems
 							m.loading = false // Hide loader if we have data
 						}
@@ -195,28 +217,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				case plex.Video: // Item or Episode
 					if m.mode == ModeItems {
 						if item.Type == "show" {
-							m.selectedShowTitle = item.Title // Store show title for breadcrumbs
-							m.mode = ModeSeasons
-							m.loading = true
-							m.cursor = 0
-							m.showSearch = false
-							m.textInput.Reset()
-							m.needsRefresh = true
-							m.filteredList = nil
-
-							// Clear previous seasons
-							m.seasons = nil
-
-							// Instant load from DB
-							if dbSeasons, err := fetchSeasonsFromStore(m.store, item.RatingKey); err == nil && len(dbSeasons) > 0 {
-								m.seasons = dbSeasons
-								m.loading = false
-							}
-							if m.AutoSync {
-								return fetchChildren(m.plexClient, item.RatingKey)
-							}
-							m.loading = false
-							return nil
+							return m.openShow(item)
 						}
 						return func() tea.Msg { return shared.MsgPlayVideo{Video: item} }
 					} else if m.mode == ModeEpisodes {
@@ -227,6 +228,13 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 		}
 
 	case MsgSectionsLoaded:
+		// Keep the show opened from elsewhere instead of auto-selecting
+		if m.mode == ModeSeasons || m.mode == ModeEpisodes {
+			if msg.Err == nil {
+				m.sections = msg.Sections
+			}
+			return nil
+		}
 		m.loading = false
 		m.filteredList = nil // Force refresh
 		if msg.Err != nil {
@@ -236,12 +244,14 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 			m.errorMsg = "" // Clear any previous error
 
 			// Background Update Store
-			syncCmd := saveSectionsInBackground(m.store.DB, m.sections)
+			syncCmd := m.Work.Cmd(saveSectionsInBackground(m.store.DB, m.sections))
 
 			// UX Improvement: If only one section, auto-select it
 			if len(m.sections) == 1 {
 				section := m.sections[0]
 				m.mode = ModeItems
+				m.sectionKey = section.Key
+				m.loadSectionSort()
 				m.loading = true
 				m.needsRefresh = true
 				m.filteredList = nil
@@ -249,7 +259,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				// Clear previous items
 				m.items = nil
 
-				if dbItems, err := fetchLibraryItemsFromStore(m.store, m.targetType); err == nil && len(dbItems) > 0 {
+				if dbItems, err := fetchLibraryItemsFromStore(m.store, m.targetType, m.sectionKey); err == nil && len(dbItems) > 0 {
 					m.items = dbItems
 					m.loading = false
 				}
@@ -258,6 +268,10 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 			return syncCmd
 		}
 	case MsgItemsLoaded:
+		// Ignore late responses for a section the user already left
+		if msg.SectionKey != m.sectionKey {
+			return nil
+		}
 		m.loading = false
 		m.needsRefresh = true
 		m.filteredList = nil // Force refresh to show updated metadata
@@ -270,19 +284,21 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				var converted []plex.Video
 				for _, d := range msg.Dirs {
 					converted = append(converted, plex.Video{
-						Title:         d.Title,
-						Key:           d.Key,
-						RatingKey:     d.RatingKey,
-						Summary:       d.Summary,
-						Type:          "show",
-						Year:          d.Year,
-						Rating:        d.Rating,
-						Genre:         d.Genre,
-						Director:      d.Director,
-						ContentRating: d.ContentRating,
-						Studio:        d.Studio,
-						Role:          d.Role,
-						AddedAt:       d.AddedAt,
+						Title:           d.Title,
+						Key:             d.Key,
+						RatingKey:       d.RatingKey,
+						Summary:         d.Summary,
+						Type:            "show",
+						Year:            d.Year,
+						Rating:          d.Rating,
+						Genre:           d.Genre,
+						Director:        d.Director,
+						ContentRating:   d.ContentRating,
+						Studio:          d.Studio,
+						Role:            d.Role,
+						AddedAt:         d.AddedAt,
+						LeafCount:       d.LeafCount,
+						ViewedLeafCount: d.ViewedLeafCount,
 					})
 				}
 				m.items = converted
@@ -290,7 +306,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 				m.items = msg.Items
 			}
 			// Background Update Store
-			return saveItemsInBackground(m.store.DB, m.items, m.targetType)
+			return m.Work.Cmd(saveItemsInBackground(m.store.DB, msg.SectionKey, m.items, m.targetType))
 		}
 	case MsgChildrenLoaded:
 		m.loading = false
@@ -304,7 +320,7 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 			m.episodes = msg.Videos
 
 			// Background Update Store
-			syncCmd := saveChildrenInBackground(m.store.DB, msg.ParentID, m.seasons, m.episodes)
+			syncCmd := m.Work.Cmd(saveChildrenInBackground(m.store.DB, msg.ParentID, m.seasons, m.episodes))
 
 			// Auto-Switch Logic:
 			if m.mode == ModeSeasons {
@@ -315,9 +331,57 @@ func (m *Model) Update(msg tea.Msg) tea.Cmd {
 			}
 			return syncCmd
 		}
+	case MsgSortSaved:
+		if msg.Err != nil {
+			m.Notice = fmt.Sprintf("⚠ Could not save the sort order: %v", msg.Err)
+		}
+		return nil
 	case MsgBackgroundSyncFinished:
 		// Silently ignore or maybe show a tiny indicator if Added > 0
 		return nil
 	}
 	return nil
 }
+
+// openShow drills into the seasons of show.
+func (m *Model) openShow(item plex.Video) tea.Cmd {
+	m.selectedShowTitle = item.Title // Store show title for breadcrumbs
+	m.selectedShowKey = item.RatingKey
+	m.mode = ModeSeasons
+	m.loading = true
+	m.cursor = 0
+	m.showSearch = false
+	m.textInput.Reset()
+	m.needsRefresh = true
+	m.filteredList = nil
+
+	// Clear previous seasons
+	m.seasons = nil
+
+	// Instant load from DB
+	if dbSeasons, err := fetchSeasonsFromStore(m.store, item.RatingKey); err == nil && len(dbSeasons) > 0 {
+		m.seasons = dbSeasons
+		m.loading = false
+	}
+	if m.AutoSync {
+		return fetchChildren(m.plexClient, item.RatingKey)
+	}
+	m.loading = false
+	return nil
+}
+
+// OpenShow shows the seasons of a show picked outside the browser, such as
+// from the search screen. Going back lands on its library section.
+func (m *Model) OpenShow(show plex.Video) tea.Cmd {
+	m.targetType = "show"
+	m.sectionKey = show.LibrarySectionID
+	m.loadSectionSort()
+	m.mode = ModeItems
+	m.errorMsg = ""
+	m.episodes = nil
+
+	m.sections, _ = fetchSectionsFromStore(m.store, m.targetType)
+	m.items, _ = fetchLibraryItemsFromStore(m.store, m.targetType, m.sectionKey)
+
+	return m.openShow(show)
+}
@@ -35,14 +35,23 @@ func (m *Model) View() string {
 		}
 	}
 
+	// Filtering first so the header reflects the current query's errors.
+	filteredList := m.getFilteredList()
+
 	headerViewSource := ""
 	if m.showSearch {
 		headerViewSource = fmt.Sprintf("🔍 %s", m.textInput.View())
+		if m.filterErr != nil {
+			headerViewSource += lipgloss.NewStyle().Foreground(shared.ColorRed).Render("  ⚠ " + m.filterErr.Error())
+		}
 	} else {
 		headerViewSource = breadcrumb
 		if m.SyncStatus != "" {
 			headerViewSource += shared.StyleDim.Render("  " + m.SyncStatus)
 		}
+		if m.Notice != "" {
+			headerViewSource += lipgloss.NewStyle().Foreground(shared.ColorPlexOrange).Render("  " + m.Notice)
+		}
 		if m.textInput.Value() != "" {
 			headerViewSource += shared.StyleDim.Render(fmt.Sprintf(" [Filter: %s]", m.textInput.Value()))
 		}
@@ -69,15 +78,14 @@ func (m *Model) View() string {
 
 	// --- 4. Render Body ---
 	var leftPane, rightPane string
-	filteredList := m.getFilteredList()
 	count := len(filteredList)
 	start := 0
 	end := 0
 
 	// Footer
 	totalElements := len(filteredList)
-	footerText := fmt.Sprintf("%d elements • Sorted by %s", totalElements, m.sortMethod.String())
-	helpKeys := "[/] Search • [S] Sort • [Enter] Select • [Esc/Q] Back"
+	footerText := fmt.Sprintf("%d elements • Sorted by %s", totalElements, m.sort.Label())
+	helpKeys := "[/] Search • [s] Sort • [S] Reverse • [w] Watched • [d] Download • [Enter] Select • [Esc/Q] Back"
 	renderedFooter, footerHeight := shared.RenderFooterLegacySafe(footerText, helpKeys, availableWidth)
 
 	// Calculate heights
@@ -152,7 +160,7 @@ func (m *Model) View() string {
 			indicators := ""
 			sidebarIndicator := ""
 			textModified := false
-			if v, ok := item.(plex.Video); ok {
+			if v, ok := watchStatus(item); ok {
 				indicators, sidebarIndicator = m.renderStatusIndicator(v, listWidth)
 
 				// For text-style, we modify the line style instead of adding indicators
@@ -0,0 +1,143 @@
+package browser
+
+import (
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// watchStatus returns the video whose watch state the status indicators of
+// item show. Shows and seasons are watched once all their episodes are, and
+// in progress, by the share of episodes watched, while some are.
+func watchStatus(item interface{}) (plex.Video, bool) {
+	switch v := item.(type) {
+	case plex.Video:
+		if v.Type != "show" {
+			return v, true
+		}
+		return leafStatus(v.LeafCount, v.ViewedLeafCount), true
+	case plex.Directory:
+		if v.Type != "season" {
+			return plex.Video{}, false
+		}
+		return leafStatus(v.LeafCount, v.ViewedLeafCount), true
+	}
+	return plex.Video{}, false
+}
+
+func leafStatus(leaves, viewed int) plex.Video {
+	if leaves > 0 && viewed >= leaves {
+		return plex.Video{ViewCount: 1}
+	}
+	return plex.Video{ViewOffset: viewed, Duration: leaves}
+}
+
+// toggleWatched marks the selected movie, episode, season or show watched,
+// or unwatched when it already is. The list changes right away; MainModel
+// updates the cache and Plex.
+func (m *Model) toggleWatched() tea.Cmd {
+	list := m.getFilteredList()
+	if m.cursor >= len(list) {
+		return nil
+	}
+	status, ok := watchStatus(list[m.cursor])
+	if !ok {
+		return nil
+	}
+	watched := status.ViewCount == 0
+
+	var key string
+	switch item := list[m.cursor].(type) {
+	case plex.Video:
+		key = item.RatingKey
+		if item.Type == "show" {
+			for i := range m.items {
+				if m.items[i].RatingKey == key {
+					m.items[i].ViewedLeafCount = 0
+					if watched {
+						m.items[i].ViewedLeafCount = m.items[i].LeafCount
+					}
+				}
+			}
+			break
+		}
+		for _, videos := range [][]plex.Video{m.items, m.episodes} {
+			for i := range videos {
+				if videos[i].RatingKey != key {
+					continue
+				}
+				videos[i].ViewOffset = 0
+				if !watched {
+					videos[i].ViewCount = 0
+				} else if videos[i].ViewCount == 0 {
+					videos[i].ViewCount = 1
+				}
+			}
+		}
+		if item.Type == "episode" {
+			delta := -1
+			if watched {
+				delta = 1
+			}
+			m.countWatchedEpisodes(item.ParentRatingKey, delta)
+		}
+	case plex.Directory:
+		key = item.RatingKey
+		viewed := 0
+		if watched {
+			viewed = item.LeafCount
+		}
+		m.countWatchedEpisodes(key, viewed-item.ViewedLeafCount)
+	}
+	m.needsRefresh = true
+	m.filteredList = nil
+
+	kind := cache.ChangeUnwatched
+	if watched {
+		kind = cache.ChangeWatched
+	}
+	return watchChange(cache.WatchChange{Kind: kind, RatingKey: key})
+}
+
+// clearProgress forgets where playback of the selected movie or episode
+// stopped.
+func (m *Model) clearProgress() tea.Cmd {
+	list := m.getFilteredList()
+	if m.cursor >= len(list) {
+		return nil
+	}
+	item, ok := list[m.cursor].(plex.Video)
+	if !ok || item.Type == "show" || item.ViewOffset == 0 {
+		return nil
+	}
+	for _, videos := range [][]plex.Video{m.items, m.episodes} {
+		for i := range videos {
+			if videos[i].RatingKey == item.RatingKey {
+				videos[i].ViewOffset = 0
+			}
+		}
+	}
+	m.needsRefresh = true
+	m.filteredList = nil
+	return watchChange(cache.WatchChange{Kind: cache.ChangeProgress, RatingKey: item.RatingKey})
+}
+
+// countWatchedEpisodes adds delta to the watched episodes of the season
+// seasonKey and of the open show.
+func (m *Model) countWatchedEpisodes(seasonKey string, delta int) {
+	for i := range m.seasons {
+		if m.seasons[i].RatingKey == seasonKey {
+			m.seasons[i].ViewedLeafCount += delta
+		}
+	}
+	for i := range m.items {
+		if m.items[i].Type == "show" && m.items[i].RatingKey == m.selectedShowKey {
+			m.items[i].ViewedLeafCount += delta
+		}
+	}
+}
+
+func watchChange(c cache.WatchChange) tea.Cmd {
+	return func() tea.Msg { return shared.MsgWatchChange{Change: c} }
+}
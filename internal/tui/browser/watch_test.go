@@ -0,0 +1,90 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestToggleWatched(t *testing.T) {
+	m := NewModel(nil, nil, false, "badges")
+	m.items = []plex.Video{{RatingKey: "10", Title: "The Expanse", Type: "show", LeafCount: 3, ViewedLeafCount: 1}}
+	m.selectedShowKey = "10"
+	m.seasons = []plex.Directory{
+		{RatingKey: "11", Title: "Season 1", Type: "season", LeafCount: 2, ViewedLeafCount: 1},
+		{RatingKey: "12", Title: "Season 2", Type: "season", LeafCount: 1},
+	}
+	m.mode = ModeEpisodes
+	m.episodes = []plex.Video{
+		{RatingKey: "101", Type: "episode", ParentRatingKey: "11", Index: 1, ViewCount: 1},
+		{RatingKey: "102", Type: "episode", ParentRatingKey: "11", Index: 2, ViewOffset: 60000, Duration: 120000},
+	}
+	m.needsRefresh = true
+
+	key := func(k string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)} }
+	change := func(cmd tea.Cmd) cache.WatchChange {
+		t.Helper()
+		if cmd == nil {
+			t.Fatal("Expected a watch change")
+		}
+		msg, ok := cmd().(shared.MsgWatchChange)
+		if !ok {
+			t.Fatalf("Expected MsgWatchChange, got %T", cmd())
+		}
+		return msg.Change.(cache.WatchChange)
+	}
+
+	// An episode in progress becomes watched, completing its season
+	m.cursor = 1
+	if c := change(m.Update(key("w"))); c.Kind != cache.ChangeWatched || c.RatingKey != "102" {
+		t.Errorf("Expected episode 102 marked watched, got %+v", c)
+	}
+	if e := m.episodes[1]; e.ViewCount != 1 || e.ViewOffset != 0 {
+		t.Errorf("Expected episode 102 watched, got %+v", e)
+	}
+	if s, ok := watchStatus(m.seasons[0]); !ok || s.ViewCount == 0 {
+		t.Errorf("Expected season 1 watched, got %+v", m.seasons[0])
+	}
+	if show := m.items[0]; show.ViewedLeafCount != 2 {
+		t.Errorf("Expected 2 watched episodes for the show, got %d", show.ViewedLeafCount)
+	}
+
+	// Toggling the season again marks both episodes unwatched
+	m.mode = ModeSeasons
+	m.needsRefresh = true
+	m.cursor = 0
+	if c := change(m.Update(key("w"))); c.Kind != cache.ChangeUnwatched || c.RatingKey != "11" {
+		t.Errorf("Expected season 11 marked unwatched, got %+v", c)
+	}
+	if m.seasons[0].ViewedLeafCount != 0 || m.items[0].ViewedLeafCount != 0 {
+		t.Errorf("Expected no watched episodes left, got season %d and show %d", m.seasons[0].ViewedLeafCount, m.items[0].ViewedLeafCount)
+	}
+
+	// A whole show
+	m.mode = ModeItems
+	m.needsRefresh = true
+	if c := change(m.Update(key("w"))); c.Kind != cache.ChangeWatched || c.RatingKey != "10" {
+		t.Errorf("Expected show 10 marked watched, got %+v", c)
+	}
+	if s, _ := watchStatus(m.items[0]); s.ViewCount == 0 {
+		t.Errorf("Expected the show watched, got %+v", m.items[0])
+	}
+
+	// Only items with a resume position have progress to clear
+	m.mode = ModeEpisodes
+	m.needsRefresh = true
+	m.episodes[1].ViewCount, m.episodes[1].ViewOffset = 0, 30000
+	m.cursor = 1
+	if c := change(m.Update(key("x"))); c.Kind != cache.ChangeProgress || c.RatingKey != "102" || c.OffsetMs != 0 {
+		t.Errorf("Expected progress of 102 cleared, got %+v", c)
+	}
+	if m.episodes[1].ViewOffset != 0 {
+		t.Errorf("Expected no progress left, got %d", m.episodes[1].ViewOffset)
+	}
+	if cmd := m.Update(key("x")); cmd != nil {
+		t.Errorf("Expected nothing to clear, got %T", cmd())
+	}
+}
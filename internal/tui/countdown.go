@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Waddenn/plex-client/internal/player"
 	"github.com/Waddenn/plex-client/internal/tui/shared"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -14,12 +15,16 @@ type CountdownModel struct {
 	NextTitle        string
 	NextAction       func() tea.Cmd
 	CancelAction     func() tea.Cmd
+
+	// Controller drives the mpv still open, whose window hides the
+	// terminal: the countdown shows on its OSD too. Nil when there is none.
+	Controller *player.Controller
 }
 
 type TickMsg time.Time
 
 func (m *CountdownModel) Init() tea.Cmd {
-	return tick()
+	return tea.Batch(tick(), m.showOSD())
 }
 
 func tick() tea.Cmd {
@@ -33,7 +38,7 @@ func (m *CountdownModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case TickMsg:
 		if m.SecondsRemaining > 0 {
 			m.SecondsRemaining--
-			return m, tick()
+			return m, tea.Batch(tick(), m.showOSD())
 		}
 		// Time's up!
 		return m, m.NextAction()
@@ -51,6 +56,20 @@ func (m *CountdownModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// showOSD shows the countdown in mpv until the next tick. Closing mpv
+// cancels it, like Esc in the terminal.
+func (m *CountdownModel) showOSD() tea.Cmd {
+	c := m.Controller
+	if c == nil {
+		return nil
+	}
+	text := fmt.Sprintf("Next up: %s\nStarting in %d seconds... (Q=Cancel)", m.NextTitle, m.SecondsRemaining)
+	return func() tea.Msg {
+		c.ShowText(text, 1500*time.Millisecond)
+		return nil
+	}
+}
+
 func (m CountdownModel) View() string {
 	title := shared.StyleTitle.Render("⏳ Play Queue")
 
@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/player"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestCountdownInPlayer(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := player.NewController(client)
+	defer c.Close()
+
+	commands := make(chan []interface{}, 1)
+	go func() {
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			var req struct {
+				Command   []interface{} `json:"command"`
+				RequestID int           `json:"request_id"`
+			}
+			json.Unmarshal(scanner.Bytes(), &req)
+			commands <- req.Command
+			data, _ := json.Marshal(map[string]interface{}{"request_id": req.RequestID, "error": "success"})
+			server.Write(append(data, '\n'))
+		}
+	}()
+
+	// Shown on the OSD, as the mpv window hides the terminal
+	s := &player.Session{Controller: c}
+	m := &MainModel{session: s, currentView: shared.ViewCountdown}
+	m.countdown = CountdownModel{
+		SecondsRemaining: 3,
+		NextTitle:        "The Expanse - Dulcinea",
+		Controller:       c,
+		CancelAction:     func() tea.Cmd { return func() tea.Msg { return shared.MsgBack{} } },
+	}
+	m.countdown.showOSD()()
+	want := "[show-text Next up: The Expanse - Dulcinea\nStarting in 3 seconds... (Q=Cancel) 1500]"
+	if got := fmt.Sprint(<-commands); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Closing mpv cancels it
+	cmd, ok := m.handlePlayerMsg(msgPlayerExited{session: s})
+	if !ok || cmd == nil {
+		t.Fatal("Expected the countdown cancelled")
+	}
+	if _, back := cmd().(shared.MsgBack); !back || m.session != nil {
+		t.Errorf("Expected to go back without a session, got session %v", m.session)
+	}
+}
@@ -21,7 +21,7 @@ type Model struct {
 	// activeColumn: 0 = Sidebar, 1 = Content
 	activeColumn int
 
-	// sidebarCursor: 0 = Home (refresh), 1 = Movies, 2 = Series
+	// sidebarCursor: 0 = Movies, 1 = Series, 2 = Search, 3 = Downloads, 4 = Servers, 5 = Switch User, 6 = Settings
 	sidebarCursor int
 
 	// contentCursor: 0 = Hero, 1+ = List items
@@ -29,6 +29,11 @@ type Model struct {
 
 	// Sync State
 	SyncStatus string
+
+	// ServerName is the active server, shown in the header
+	ServerName string
+	// UserName is the active Plex Home user, shown in the header
+	UserName string
 }
 
 func NewModel(p *plex.Client) Model {
@@ -115,7 +120,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 		case "down", "j":
 			if m.activeColumn == 0 {
-				if m.sidebarCursor < 2 { // Movies, Series, Settings
+				if m.sidebarCursor < 6 { // Movies, Series, Search, Downloads, Servers, Switch User, Settings
 					m.sidebarCursor++
 				}
 			} else {
@@ -148,7 +153,15 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewMovieBrowser} }
 				case 1: // Series
 					return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewSeriesBrowser} }
-				case 2: // Settings
+				case 2: // Search
+					return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewSearch} }
+				case 3: // Downloads
+					return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewDownloads} }
+				case 4: // Servers
+					return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewServers} }
+				case 5: // Switch User
+					return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewUsers} }
+				case 6: // Settings
 					return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewSettings} }
 				}
 			} else {
@@ -168,6 +181,18 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 		case "r":
 			return m, func() tea.Msg { return shared.MsgManualSync{} }
+
+		case "/":
+			return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewSearch} }
+
+		case "s":
+			return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewServers} }
+
+		case "u":
+			return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewUsers} }
+
+		case "d":
+			return m, func() tea.Msg { return shared.MsgSwitchView{View: shared.ViewDownloads} }
 		}
 
 	case MsgOnDeckLoaded:
@@ -198,7 +223,7 @@ func (m *Model) View() string {
 			Bold(true).
 			Padding(1, 2)
 		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
-			errorStyle.Render("⚠ "+m.errorMsg+"\n\nPress Q or Esc to quit"))
+			errorStyle.Render("⚠ "+m.errorMsg+"\n\nPress S to switch servers, D for downloads, Q or Esc to quit"))
 	}
 
 	// --- 1. Layout dims ---
@@ -206,13 +231,19 @@ func (m *Model) View() string {
 	availableHeight := shared.ClampMin(m.height, 10)
 	// --- 2. Render Header ---
 	title := "📂 Plex CLI"
+	if m.ServerName != "" {
+		title += " • " + m.ServerName
+	}
+	if m.UserName != "" {
+		title += " • 👤 " + m.UserName
+	}
 	if m.SyncStatus != "" {
-		title = fmt.Sprintf("📂 Plex CLI  %s", m.SyncStatus)
+		title = fmt.Sprintf("%s  %s", title, m.SyncStatus)
 	}
 	header, headerHeight := shared.RenderHeaderLegacySafe(title, availableWidth)
 
 	// --- 3. Render Footer ---
-	help := "[←/→] Focus • [↑/↓] Navigate • [Enter] Open • [Q/Esc] Quit"
+	help := "[←/→] Focus • [↑/↓] Navigate • [Enter] Open • [/] Search • [D] Downloads • [S] Servers • [U] User • [Q/Esc] Quit"
 	footer, footerHeight := shared.RenderFooterLegacySafe("", help, availableWidth)
 
 	contentHeight := availableHeight - headerHeight - footerHeight
@@ -240,7 +271,7 @@ func (m *Model) View() string {
 }
 
 func (m *Model) renderSidebar(height int) string {
-	items := []string{"🎬 Movies", "📺 TV Series", "⚙️ Settings"}
+	items := []string{"🎬 Movies", "📺 TV Series", "🔍 Search", "⬇️ Downloads", "🖥️ Servers", "👤 Switch User", "⚙️ Settings"}
 
 	var renderedItems []string
 
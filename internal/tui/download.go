@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Waddenn/plex-client/internal/download"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+	"github.com/Waddenn/plex-client/internal/tui/downloads"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// msgDownloadEvent carries one download.Event along with its stream.
+type msgDownloadEvent struct {
+	event  download.Event
+	events <-chan download.Event
+	closed bool // The stream ended
+}
+
+// msgDownloadsQueued reports items added to the downloads.
+type msgDownloadsQueued struct {
+	Added int
+	Err   error
+}
+
+// openDownloads starts downloading the queue of the active cache, stopping
+// the downloads of the previous one. Read its progress with
+// waitForDownloadEvent.
+func (m *MainModel) openDownloads() {
+	m.stopDownloads()
+	dir, err := m.cfg.Downloads.Path()
+	m.downloads = download.NewManager(download.NewStore(m.db), m.plexClient, dir)
+	m.downloadsView = downloads.NewModel(m.downloads)
+	if err != nil {
+		m.downloadsView.ErrorMsg = "No downloads directory: " + err.Error()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.downloadsCancel, m.downloadsDone = cancel, done
+	go func(mgr *download.Manager) {
+		defer close(done)
+		mgr.Run(ctx)
+	}(m.downloads)
+}
+
+// stopDownloads stops the running downloads and waits until they no
+// longer use the cache.
+func (m *MainModel) stopDownloads() {
+	if m.downloadsCancel == nil {
+		return
+	}
+	m.downloadsCancel()
+	<-m.downloadsDone
+	m.downloadsCancel, m.downloadsDone = nil, nil
+}
+
+func waitForDownloadEvent(events <-chan download.Event) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		return msgDownloadEvent{event: ev, events: events, closed: !ok}
+	}
+}
+
+// queueDownload adds a movie, an episode or the episodes of a season to the
+// downloads. Seasons are listed from the cache, or from the server when
+// they were not synced yet.
+func (m *MainModel) queueDownload(msg shared.MsgDownload) tea.Cmd {
+	mgr, p, st := m.downloads, m.plexClient, store.New(m.db)
+	return m.work.Cmd(func() tea.Msg {
+		var videos []plex.Video
+		switch item := msg.Item.(type) {
+		case plex.Video:
+			videos = []plex.Video{item}
+		case plex.Directory:
+			episodes, err := st.ListEpisodes(item.RatingKey)
+			if err != nil || len(episodes) == 0 {
+				if _, episodes, err = p.GetChildren(item.RatingKey); err != nil {
+					return msgDownloadsQueued{Err: err}
+				}
+			}
+			season, _ := strconv.Atoi(item.Index)
+			for _, e := range episodes {
+				if e.GrandparentTitle == "" {
+					e.GrandparentTitle, e.ParentIndex = msg.Show, season
+				}
+				videos = append(videos, e)
+			}
+		}
+		n, err := mgr.Add(videos...)
+		return msgDownloadsQueued{Added: n, Err: err}
+	})
+}
+
+// localFile returns the downloaded file of ratingKey, if any.
+func (m *MainModel) localFile(ratingKey string) (string, bool) {
+	if m.downloads == nil {
+		return "", false
+	}
+	return m.downloads.Store.LocalPath(ratingKey)
+}
+
+// handleDownloadMsg routes the messages of downloads and reports whether
+// msg was one.
+func (m *MainModel) handleDownloadMsg(msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case shared.MsgDownload:
+		return m.queueDownload(msg), true
+	case msgDownloadsQueued:
+		switch {
+		case msg.Err != nil:
+			m.browser.Notice = "⚠ Could not download: " + msg.Err.Error()
+		case msg.Added == 0:
+			m.browser.Notice = "⬇ Already in the downloads"
+		default:
+			m.browser.Notice = fmt.Sprintf("⬇ %d added to the downloads (d on the dashboard)", msg.Added)
+		}
+		m.downloadsView.Reload()
+		return nil, true
+	case msgDownloadEvent:
+		// Events of the downloads of a cache switched away from are dropped
+		if msg.closed || msg.events != m.downloads.Events() {
+			return nil, true
+		}
+		m.downloadsView.Apply(msg.event)
+		return waitForDownloadEvent(msg.events), true
+	}
+	return nil, false
+}
@@ -0,0 +1,204 @@
+package downloads
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Waddenn/plex-client/internal/download"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Model is the "Downloads" screen: the queue with the progress of the
+// running download, and the files kept for offline playback.
+type Model struct {
+	manager *download.Manager
+	width   int
+	height  int
+
+	items  []download.Item
+	cursor int
+
+	// Error of the last action, or of reading the downloads
+	ErrorMsg string
+
+	// Sync State
+	SyncStatus string
+}
+
+func NewModel(mgr *download.Manager) Model {
+	return Model{manager: mgr, width: 80, height: 24}
+}
+
+// msgChanged reports a download removed or queued again.
+type msgChanged struct {
+	err error
+}
+
+// Reload reads the downloads again.
+func (m *Model) Reload() {
+	items, err := m.manager.Store.List()
+	if err != nil {
+		m.ErrorMsg = "Could not read the downloads: " + err.Error()
+		return
+	}
+	m.items = items
+	if m.cursor >= len(m.items) {
+		m.cursor = max(len(m.items)-1, 0)
+	}
+}
+
+// Apply shows the change of a download reported by the manager.
+func (m *Model) Apply(ev download.Event) {
+	for i := range m.items {
+		if m.items[i].RatingKey == ev.Item.RatingKey {
+			m.items[i] = ev.Item
+			return
+		}
+	}
+	m.Reload()
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case msgChanged:
+		m.ErrorMsg = ""
+		if msg.err != nil {
+			m.ErrorMsg = msg.err.Error()
+		}
+		m.Reload()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.items)-1 {
+				m.cursor++
+			}
+		case "enter":
+			if m.cursor < len(m.items) && m.items[m.cursor].Status == download.StatusDone {
+				it := m.items[m.cursor]
+				v := plex.Video{RatingKey: it.RatingKey, Type: it.Type, Title: it.Title}
+				return m, func() tea.Msg { return shared.MsgPlayVideo{Video: v} }
+			}
+		case "r":
+			if m.cursor < len(m.items) && m.items[m.cursor].Status == download.StatusFailed {
+				mgr, key := m.manager, m.items[m.cursor].RatingKey
+				return m, func() tea.Msg { return msgChanged{err: mgr.Retry(key)} }
+			}
+		case "x", "delete":
+			if m.cursor < len(m.items) {
+				mgr, key := m.manager, m.items[m.cursor].RatingKey
+				return m, func() tea.Msg { return msgChanged{err: mgr.Remove(key)} }
+			}
+		case "esc", "q", "backspace":
+			return m, func() tea.Msg { return shared.MsgBack{} }
+		}
+	}
+	return m, nil
+}
+
+func (m *Model) View() string {
+	availableWidth := shared.ClampMin(m.width, 20)
+	availableHeight := shared.ClampMin(m.height, 10)
+
+	title := "📂 Plex CLI > Downloads"
+	if m.SyncStatus != "" {
+		title += shared.StyleDim.Render("  " + m.SyncStatus)
+	}
+	header, headerHeight := shared.RenderHeaderLegacySafe(title, availableWidth)
+
+	pending := 0
+	for _, it := range m.items {
+		if it.Status == download.StatusQueued || it.Status == download.StatusDownloading {
+			pending++
+		}
+	}
+	status := fmt.Sprintf("%d downloads", len(m.items))
+	if pending > 0 {
+		status += fmt.Sprintf(" • %d to go", pending)
+	}
+	help := "[↑/↓] Navigate • [Enter] Play • [r] Retry • [x] Remove • [Esc] Back"
+	footer, footerHeight := shared.RenderFooterLegacySafe(status, help, availableWidth)
+
+	bodyHeight := shared.ClampMin(availableHeight-headerHeight-footerHeight, 1)
+	var lines []string
+	if m.ErrorMsg != "" {
+		lines = append(lines, lipgloss.NewStyle().Foreground(shared.ColorRed).Padding(0, 2).Render("⚠ "+m.ErrorMsg), "")
+	}
+	if len(m.items) == 0 {
+		lines = append(lines, shared.StyleDim.Copy().Padding(0, 2).Render("Nothing downloaded yet. Press d on a movie, an episode or a season to download it."))
+	}
+
+	// Keep the cursor on screen
+	rows := bodyHeight - len(lines)
+	start := 0
+	if rows > 0 && m.cursor >= rows {
+		start = m.cursor - rows + 1
+	}
+	for i := start; i < len(m.items) && i-start < rows; i++ {
+		lines = append(lines, m.renderRow(m.items[i], i == m.cursor, availableWidth))
+	}
+	body := lipgloss.NewStyle().Width(availableWidth).Height(bodyHeight).MaxHeight(bodyHeight).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, body, footer)
+}
+
+func (m *Model) renderRow(it download.Item, active bool, width int) string {
+	prefix := "  "
+	style := shared.StyleItemNormal
+	if active {
+		prefix = shared.SelectionIndicator()
+		style = shared.StyleItemNormal.Copy().Foreground(shared.ColorPlexOrange).Bold(true)
+	}
+
+	var state string
+	switch it.Status {
+	case download.StatusDone:
+		state = "✓ " + formatSize(it.Size)
+	case download.StatusFailed:
+		state = "⚠ " + it.Err
+	case download.StatusDownloading:
+		state = progressBar(it.Progress(), 20) + fmt.Sprintf(" %3.0f%%", it.Progress()*100)
+		if it.Size > 0 {
+			state += fmt.Sprintf(" %s/%s", formatSize(it.Received), formatSize(it.Size))
+		}
+	default:
+		state = "queued"
+	}
+
+	maxLen := shared.ClampMin(width-6, 10)
+	titleLen := shared.ClampMin(maxLen-lipgloss.Width(state)-2, 10)
+	line := fmt.Sprintf("%-*s  %s", titleLen, shared.Truncate(it.Title, titleLen), state)
+	return style.Copy().MaxHeight(1).Width(width).Render(prefix + shared.Truncate(line, maxLen))
+}
+
+func progressBar(p float64, width int) string {
+	filled := int(p * float64(width))
+	filled = min(max(filled, 0), width)
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// formatSize renders a byte count the way file managers do, e.g. 1.4 GB.
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -3,13 +3,12 @@ package login
 import (
 	"fmt"
 	"os/exec"
-	"sort"
-	"strings"
 	"time"
 
 	"github.com/Waddenn/plex-client/internal/appinfo"
 	"github.com/Waddenn/plex-client/internal/auth"
 	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/tui/servers"
 	"github.com/Waddenn/plex-client/internal/tui/shared"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -29,9 +28,11 @@ type Model struct {
 
 	width, height int
 	state         state
-	focus         int // 0: Open Browser, 1: Cancel
-	servers       []auth.PlexResource
-	selectedConn  string // BaseURL
+	focus         int           // 0: Open Browser, 1: Cancel
+	picker        servers.Model // Shown when the account reaches several servers
+
+	// Reason explains why sign-in is needed again, e.g. a revoked token
+	Reason string
 }
 
 type state int
@@ -42,6 +43,7 @@ const (
 	stateConfirmOpen
 	statePolling
 	stateSearchingServers
+	stateSelectServer
 	stateSuccess
 )
 
@@ -69,6 +71,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		}
 
+		if m.state == stateSelectServer {
+			var cmd tea.Cmd
+			m.picker, cmd = m.picker.Update(msg)
+			return m, cmd
+		}
+
 		if m.state == stateConfirmOpen {
 			switch msg.String() {
 			case "left", "h", "tab":
@@ -87,6 +95,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.picker, _ = m.picker.Update(msg)
 		return m, nil
 
 	case msgPinReady:
@@ -111,6 +120,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		if msg.pin.AuthToken != "" {
 			m.cfg.Plex.Token = msg.pin.AuthToken // Save token temporarily
+			m.cfg.Plex.UserID = ""               // Signed in as the account itself
 			m.state = stateSearchingServers
 			return m, getResourcesCmd(m.authClient, msg.pin.AuthToken)
 		}
@@ -123,29 +133,50 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil // Wait for user to quit?
 		}
 
-		// Auto-select best connection
-		bestURL := selectBestConnection(msg.resources)
-		if bestURL == "" {
+		list := servers.FromResources(msg.resources)
+		if len(list) == 0 {
 			m.err = fmt.Errorf("no suitable Plex server found")
 			return m, nil
 		}
-
-		m.cfg.Plex.BaseURL = bestURL
-		// Save config
-		if err := config.Save(m.cfg); err != nil {
-			m.err = err
-			return m, nil
+		m.cfg.Plex.Servers = list
+		if len(list) == 1 {
+			cmd := m.useServer(list[0])
+			return m, cmd
 		}
 
-		return m, func() tea.Msg {
-			return MsgLoginSuccess{Config: m.cfg}
-		}
+		m.picker = servers.NewModel(list, m.cfg.Plex.ServerID, nil, "")
+		m.picker.AllowBack = false
+		m.picker, _ = m.picker.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		m.state = stateSelectServer
+		return m, nil
+
+	case servers.MsgSelectServer:
+		cmd := m.useServer(msg.Server)
+		return m, cmd
 	}
 
 	return m, nil
 }
 
+// useServer saves s as the active server and completes the login.
+func (m *Model) useServer(s config.ServerConfig) tea.Cmd {
+	m.cfg.Plex.UseServer(s)
+	if err := config.Save(m.cfg); err != nil {
+		m.err = err
+		return nil
+	}
+	m.state = stateSuccess
+	cfg := m.cfg
+	return func() tea.Msg {
+		return MsgLoginSuccess{Config: cfg}
+	}
+}
+
 func (m Model) View() string {
+	if m.state == stateSelectServer && m.err == nil {
+		return m.picker.View()
+	}
+
 	frame := shared.StyleBorder.Copy()
 	innerWidth := m.width - frame.GetHorizontalFrameSize()
 	innerHeight := m.height - frame.GetVerticalFrameSize()
@@ -212,14 +243,13 @@ func (m Model) View() string {
 			tip = shared.StyleItemNormal.Render("⏳ Waiting for authorization...")
 		}
 
-		content = lipgloss.JoinVertical(lipgloss.Center,
-			shared.StyleTitle.Render("🔐 Plex Authentication"),
-			"",
-			status,
-			tip,
-			"",
-			shared.StyleSecondary.Render("Link: "+m.authLink),
-		)
+		lines := []string{shared.StyleTitle.Render("🔐 Plex Authentication"), ""}
+		if m.Reason != "" {
+			reason := lipgloss.NewStyle().Foreground(shared.ColorRed).Width(min(innerWidth, 60)).Align(lipgloss.Center)
+			lines = append(lines, reason.Render("⚠ "+m.Reason), "")
+		}
+		lines = append(lines, status, tip, "", shared.StyleSecondary.Render("Link: "+m.authLink))
+		content = lipgloss.JoinVertical(lipgloss.Center, lines...)
 
 	case stateSearchingServers:
 		content = lipgloss.JoinVertical(lipgloss.Center,
@@ -283,29 +313,3 @@ func getResourcesCmd(client *auth.AuthClient, token string) tea.Cmd {
 		return msgResourcesReady{res, err}
 	}
 }
-
-// Helper logic
-func selectBestConnection(resources []auth.PlexResource) string {
-	for _, res := range resources {
-		if res.Provides == "server" || res.Product == "Plex Media Server" {
-			if len(res.Connections) > 0 {
-				sort.Slice(res.Connections, func(i, j int) bool {
-					return getConnectionScore(res.Connections[i]) > getConnectionScore(res.Connections[j])
-				})
-				return res.Connections[0].Uri
-			}
-		}
-	}
-	return ""
-}
-
-// Duplicate of main.go logic logic for now, should move to auth/utils?
-func getConnectionScore(conn auth.PlexConnection) int {
-	if !conn.Local {
-		return 3
-	}
-	if strings.HasPrefix(conn.Address, "172.") {
-		return 1
-	}
-	return 2
-}
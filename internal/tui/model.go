@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -10,20 +11,26 @@ import (
 	"github.com/Waddenn/plex-client/internal/appinfo"
 	"github.com/Waddenn/plex-client/internal/cache"
 	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/download"
 	"github.com/Waddenn/plex-client/internal/player"
 	"github.com/Waddenn/plex-client/internal/plex"
 	"github.com/Waddenn/plex-client/internal/store"
 	"github.com/Waddenn/plex-client/internal/tui/browser"
 	"github.com/Waddenn/plex-client/internal/tui/dashboard"
+	"github.com/Waddenn/plex-client/internal/tui/downloads"
 	"github.com/Waddenn/plex-client/internal/tui/login"
+	"github.com/Waddenn/plex-client/internal/tui/search"
+	"github.com/Waddenn/plex-client/internal/tui/servers"
 	"github.com/Waddenn/plex-client/internal/tui/settings"
 	"github.com/Waddenn/plex-client/internal/tui/shared"
+	"github.com/Waddenn/plex-client/internal/tui/users"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 type MainModel struct {
 	cfg        *config.Config
 	db         *sql.DB
+	dbCacheID  string // Server and user whose cache db is
 	plexClient *plex.Client
 	appInfo    appinfo.Info
 
@@ -37,45 +44,118 @@ type MainModel struct {
 	dashboard dashboard.Model
 	browser   *browser.Model
 	settings  settings.Model
+	search    search.Model
+	servers   servers.Model
+	users     users.Model
 	countdown CountdownModel
+	resume    ResumeModel
+
+	downloadsView downloads.Model
+	nowPlaying    NowPlayingModel
 
 	// Play Queue State
 	playQueue []plex.Video
 	queueIdx  int
 
+	// Running mpv, kept open across the play queue; playerEvents are its
+	// events, shown by nowPlaying
+	session      *player.Session
+	playerEvents <-chan player.Event
+
 	// Sync State
-	syncStatus string
-	syncAdded  int
-	syncTick   int
+	syncStatus  string
+	syncAdded   int
+	syncRemoved int
+	syncTick    int
+
+	// syncing is true while any sync is in flight; syncScheduled while a
+	// periodic background sync is pending.
+	syncing       bool
+	syncScheduled bool
+
+	// Sections that failed during the current sync
+	syncErrors []string
+
+	// syncCancel stops the running sync; nil when none is running
+	syncCancel context.CancelFunc
+
+	// Updates Plex could not be reached for, replayed with backoff
+	outbox          *cache.Outbox
+	replayer        *cache.Replayer
+	replayScheduled bool
+
+	// Downloads of the active cache; downloadsCancel stops them and
+	// downloadsDone is closed once they stopped
+	downloads       *download.Manager
+	downloadsCancel context.CancelFunc
+	downloadsDone   chan struct{}
+
+	// Background commands on db, waited for before it is closed
+	work *shared.Work
 }
 
 func NewModel(db *sql.DB, cfg *config.Config, p *plex.Client, info appinfo.Info) MainModel {
 	st := store.New(db)
 	bm := browser.NewModel(p, st, cfg.Sync.AutoSync, cfg.UI.StatusIndicatorStyle)
+	bm.DefaultSort = defaultSort(cfg)
+	bm.Work = shared.NewWork()
 
 	initialView := shared.ViewDashboard
 	if cfg.Plex.Token == "" {
 		initialView = shared.ViewLogin
 	}
 
-	return MainModel{
+	dm := dashboard.NewModel(p)
+	dm.ServerName = serverName(cfg)
+	dm.UserName = userName(cfg)
+
+	m := MainModel{
 		cfg:         cfg,
 		db:          db,
+		dbCacheID:   cfg.Plex.CacheID(),
 		plexClient:  p,
 		appInfo:     info,
 		currentView: initialView,
 		login:       login.NewModel(cfg, info),
-		dashboard:   dashboard.NewModel(p),
+		dashboard:   dm,
 		browser:     &bm,
 		settings:    settings.NewModel(cfg),
+		search:      search.NewModel(p, st, cfg.Sync.AutoSync),
+		work:        bm.Work,
+	}
+	m.openOutbox()
+	m.updateWatchNotice()
+	m.openDownloads()
+	return m
+}
+
+// Close stops the player and the downloads and closes the cache of the
+// active server. Unfinished downloads resume on the next start.
+func (m *MainModel) Close() error {
+	if m.session != nil {
+		// Report where playback stopped before the cache closes
+		m.session.Quit()
+		m.session.Wait()
 	}
+	m.stopDownloads()
+	m.work.Close()
+	return m.db.Close()
+}
+
+// defaultSort maps ui.sort_by to the browser's sort; invalid values,
+// reported at startup, fall back to sorting by title.
+func defaultSort(cfg *config.Config) browser.Sort {
+	s, _ := browser.ParseSort(cfg.UI.SortBy)
+	return s
 }
 
 func (m *MainModel) Init() tea.Cmd {
+	downloads := waitForDownloadEvent(m.downloads.Events())
 	if m.currentView == shared.ViewLogin {
-		return m.login.Init()
+		return tea.Batch(m.login.Init(), downloads)
 	}
-	return m.dashboard.Init()
+	// Send what earlier sessions could not
+	return tea.Batch(m.dashboard.Init(), m.scheduleBackgroundSync(), m.scheduleReplay(outboxRetryMin), downloads)
 }
 
 // MsgQueueLoaded is returned when a Play Queue is fetched
@@ -95,10 +175,15 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		// Global keys
-		if m.currentView != shared.ViewCountdown { // Countdown handles its own keys
+		if m.currentView != shared.ViewCountdown && m.currentView != shared.ViewResume { // Dialogs handle their own keys
 			switch msg.String() {
 			case "ctrl+c":
+				m.cancelSync()
 				return m, tea.Quit
+			case "ctrl+x":
+				if m.syncing {
+					return m, func() tea.Msg { return shared.MsgCancelSync{} }
+				}
 			}
 		}
 	case tea.WindowSizeMsg:
@@ -112,10 +197,27 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		newLogin, _ := m.login.Update(msg)
 		m.login = newLogin.(login.Model)
 		m.settings, _ = m.settings.Update(msg)
+		m.search, _ = m.search.Update(msg)
+		m.servers, _ = m.servers.Update(msg)
+		m.users, _ = m.users.Update(msg)
+		m.downloadsView, _ = m.downloadsView.Update(msg)
 		cmd = m.browser.Update(msg)
 		return m, cmd
 	}
 
+	if cmd, ok := m.handleUnauthorized(msg); ok {
+		return m, cmd
+	}
+	if cmd, ok := m.handleWatchMsg(msg); ok {
+		return m, cmd
+	}
+	if cmd, ok := m.handleDownloadMsg(msg); ok {
+		return m, cmd
+	}
+	if cmd, ok := m.handlePlayerMsg(msg); ok {
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case shared.MsgSwitchView:
 		m.currentView = msg.View
@@ -131,9 +233,37 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				_ = m.browser.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
 			}
 			return m, m.browser.SetType("show")
+		} else if msg.View == shared.ViewSearch {
+			return m, m.search.Focus()
+		} else if msg.View == shared.ViewServers {
+			return m, m.openServerPicker()
+		} else if msg.View == shared.ViewUsers {
+			return m, m.openUserPicker()
+		} else if msg.View == shared.ViewDownloads {
+			m.downloadsView.Reload()
 		}
 		return m, nil
 
+	case servers.MsgSelectServer:
+		// During login the picker belongs to the login screen
+		if m.currentView != shared.ViewLogin {
+			return m, m.switchServer(msg)
+		}
+
+	case users.MsgSelectUser:
+		return m, m.switchUser(msg)
+
+	case shared.MsgOpenShow:
+		show, ok := msg.Show.(plex.Video)
+		if !ok {
+			return m, nil
+		}
+		m.currentView = shared.ViewSeriesBrowser
+		if m.width > 0 && m.height > 0 {
+			_ = m.browser.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		}
+		return m, m.browser.OpenShow(show)
+
 	case shared.MsgBack:
 		if m.currentView != shared.ViewDashboard {
 			m.currentView = shared.ViewDashboard
@@ -142,14 +272,14 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.queueIdx = 0
 
 			// Refresh dashboard
-			return m, m.dashboard.Init()
+			return m, tea.Batch(m.dashboard.Init(), m.stopPlayer())
 		}
 		return m, tea.Quit
 
 	case shared.MsgError:
 		fmt.Fprintf(os.Stderr, "ERROR: %v\n", msg.Err)
 		m.currentView = shared.ViewDashboard
-		return m, nil
+		return m, m.stopPlayer()
 
 	case shared.MsgPlayVideo:
 		// Assert video type
@@ -162,28 +292,19 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// For episodes, fetch/create Play Queue
 		if v.Type == "episode" {
 			m.currentView = shared.ViewPlayer // Show placeholder
-			return m, fetchPlayQueue(m.plexClient, v)
+			m.nowPlaying = NowPlayingModel{Title: v.Title, Starting: true}
+			return m, fetchPlayQueue(m.plexClient, m.downloads, v)
 		}
 
 		// For movies, play single video directly
-		// Find media part
-		if len(v.Media) == 0 || len(v.Media[0].Part) == 0 {
+		// Find media part, unless it was downloaded
+		if !m.playable(v) {
 			// No media found
 			m.currentView = shared.ViewDashboard
 			return m, nil
 		}
-		partKey := v.Media[0].Part[0].Key
-		playbackURL := m.plexClient.BaseURL + partKey
 
-		m.currentView = shared.ViewPlayer
-		// Run player in a command
-		return m, func() tea.Msg {
-			completed, err := player.Play(v.Title, playbackURL, v.RatingKey, int64(v.ViewOffset), m.cfg, m.plexClient)
-			if err != nil {
-				return shared.MsgError{Err: err}
-			}
-			return MsgPlaybackFinished{Completed: completed}
-		}
+		return m, m.play(v, v.Title)
 
 	case MsgQueueLoaded:
 		m.playQueue = msg.Queue
@@ -191,6 +312,9 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.playCurrentQueueItem()
 
 	case MsgPlaybackFinished:
+		// Progress may have been queued while the server was unreachable
+		replay := m.scheduleReplay(m.replayer.Delay())
+
 		// Logic to determine what to do next
 		if len(m.playQueue) > 0 && m.queueIdx < len(m.playQueue)-1 && msg.Completed {
 			// Proceed to Countdown
@@ -201,10 +325,15 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				title = fmt.Sprintf("%s - %s", nextItem.GrandparentTitle, nextItem.Title)
 			}
 
+			var controller *player.Controller
+			if m.session != nil {
+				controller = m.session.Controller
+			}
 			m.currentView = shared.ViewCountdown
 			m.countdown = CountdownModel{
 				SecondsRemaining: 3, // 3 seconds countdown (matches old behavior)
 				NextTitle:        title,
+				Controller:       controller,
 				NextAction: func() tea.Cmd {
 					m.queueIdx++
 					return func() tea.Msg { return MsgPlayNext{} }
@@ -213,11 +342,11 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return func() tea.Msg { return shared.MsgBack{} }
 				},
 			}
-			return m, m.countdown.Init()
+			return m, tea.Batch(replay, m.countdown.Init())
 		}
 
 		// If finished or no queue, go back
-		return m, func() tea.Msg { return shared.MsgBack{} }
+		return m, tea.Batch(replay, func() tea.Msg { return shared.MsgBack{} })
 
 	case MsgPlayNext:
 		// Triggered by countdown completion
@@ -228,46 +357,33 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.browser != nil {
 			m.browser.AutoSync = m.cfg.Sync.AutoSync
 			m.browser.StatusIndicatorStyle = m.cfg.UI.StatusIndicatorStyle
+			m.browser.DefaultSort = defaultSort(m.cfg)
 		}
-		return m, nil
+		m.search.Remote = m.cfg.Sync.AutoSync
+		return m, m.scheduleBackgroundSync()
 
 	case login.MsgLoginSuccess:
 		m.cfg = msg.Config
-		// Re-init plex client with new token/url
-		m.plexClient = plex.New(m.cfg.Plex.BaseURL, m.cfg.Plex.Token, m.cfg.Plex.ClientIdentifier, m.appInfo)
-
-		// Update submodels
-		st := store.New(m.db)
-		bm := browser.NewModel(m.plexClient, st, m.cfg.Sync.AutoSync, m.cfg.UI.StatusIndicatorStyle)
-		m.browser = &bm
-		if m.width > 0 && m.height > 0 {
-			_ = m.browser.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		// Point the client and cache at the server picked during login
+		if err := m.connect(m.cfg.Plex); err != nil {
+			cmd := m.openServerPicker()
+			m.servers.ErrorMsg = "Could not open the server cache: " + err.Error()
+			return m, cmd
 		}
-		m.dashboard = dashboard.NewModel(m.plexClient)
 
 		// Switch to dashboard
 		m.currentView = shared.ViewDashboard
-		return m, m.dashboard.Init()
+		return m, tea.Batch(m.dashboard.Init(), m.scheduleBackgroundSync(), m.syncNewServer(), m.scheduleReplay(outboxRetryMin),
+			waitForDownloadEvent(m.downloads.Events()))
 
 	case shared.MsgSyncProgress:
-		m.syncStatus = msg.Status
-		m.syncAdded = msg.Added
-		if msg.Done {
-			m.syncStatus = "Sync Complete"
-			m.syncTick = 0
-			m.updateSubmodelsSyncStatus()
-			return m, tea.Tick(time.Second*2, func(t time.Time) tea.Msg { return msgSyncClear{} })
-		}
-		if m.syncStatus != "" && m.syncTick == 0 {
-			m.syncTick = 1
-			m.updateSubmodelsSyncStatus()
-			return m, tickSync()
-		}
-		m.updateSubmodelsSyncStatus()
-		return m, nil
+		return m, m.handleSyncProgress(msg)
+
+	case msgSyncEvent:
+		return m, m.handleSyncEvent(msg)
 
 	case msgSyncTick:
-		if m.syncStatus == "" || m.syncStatus == "Sync Complete" {
+		if m.syncStatus == "" || !m.syncing {
 			m.syncTick = 0
 			return m, nil
 		}
@@ -276,30 +392,38 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tickSync()
 
 	case msgSyncClear:
+		if m.syncing {
+			return m, nil // A new sync started meanwhile
+		}
 		m.syncStatus = ""
 		m.syncAdded = 0
+		m.syncRemoved = 0
+		m.syncErrors = nil
 		m.syncTick = 0
 		m.updateSubmodelsSyncStatus()
 		return m, nil
 
 	case shared.MsgManualSync:
-		if m.cfg.Plex.Token == "" {
+		if m.cfg.Plex.Token == "" || m.syncing || m.currentView == shared.ViewLogin {
 			return m, nil
 		}
-		m.syncStatus = "Manual Sync"
-		m.updateSubmodelsSyncStatus()
-		return m, tea.Batch(
-			tickSync(),
-			func() tea.Msg {
-				if err := cache.Sync(m.plexClient, m.db, false, func(s string, a int) {
-					// Real-time progress would need the 'program' pointer or a channel.
-					// For now, we just stay in "Manual Sync..." state until done.
-				}); err != nil {
-					return shared.MsgError{Err: err}
-				}
-				return shared.MsgSyncProgress{Done: true}
-			},
-		)
+		return m, m.startSync("Manual Sync")
+
+	case shared.MsgAutoSync:
+		if m.cfg.Plex.Token == "" || m.syncing || m.isPlaying() || m.currentView == shared.ViewLogin {
+			return m, nil
+		}
+		return m, m.startSync("Background Sync")
+
+	case shared.MsgCancelSync:
+		if m.cancelSync() {
+			m.syncStatus = "Cancelling Sync"
+			m.updateSubmodelsSyncStatus()
+		}
+		return m, nil
+
+	case msgBackgroundSyncTick:
+		return m, m.handleBackgroundSyncTick()
 	}
 
 	// Update active submodel
@@ -318,10 +442,26 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		newModel, newCmd := m.countdown.Update(msg)
 		m.countdown = *newModel.(*CountdownModel)
 		cmd = newCmd
+	case shared.ViewResume:
+		newModel, newCmd := m.resume.Update(msg)
+		m.resume = *newModel.(*ResumeModel)
+		cmd = newCmd
+	case shared.ViewPlayer:
+		newModel, newCmd := m.nowPlaying.Update(msg)
+		m.nowPlaying = *newModel.(*NowPlayingModel)
+		cmd = newCmd
 	case shared.ViewSettings:
 		newModel, newCmd := m.settings.Update(msg)
 		m.settings = newModel
 		cmd = newCmd
+	case shared.ViewSearch:
+		m.search, cmd = m.search.Update(msg)
+	case shared.ViewServers:
+		m.servers, cmd = m.servers.Update(msg)
+	case shared.ViewUsers:
+		m.users, cmd = m.users.Update(msg)
+	case shared.ViewDownloads:
+		m.downloadsView, cmd = m.downloadsView.Update(msg)
 	}
 
 	return m, cmd
@@ -330,38 +470,115 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // MsgPlayNext is a signal to play the next item in queue
 type MsgPlayNext struct{}
 
-func (m MainModel) playCurrentQueueItem() tea.Cmd {
+func (m *MainModel) playCurrentQueueItem() tea.Cmd {
 	if m.queueIdx < 0 || m.queueIdx >= len(m.playQueue) {
 		return func() tea.Msg { return shared.MsgBack{} }
 	}
 
 	item := m.playQueue[m.queueIdx]
-	if len(item.Media) == 0 || len(item.Media[0].Part) == 0 {
+	if !m.playable(item) {
 		// Skip or error?
 		return func() tea.Msg { return MsgPlaybackFinished{Completed: true} } // Skip
 	}
 
-	partKey := item.Media[0].Part[0].Key
 	title := item.Title
 	if item.Type == "episode" && item.GrandparentTitle != "" {
 		title = fmt.Sprintf("%s - S%02dE%02d - %s", item.GrandparentTitle, item.ParentIndex, item.Index, item.Title)
 	}
 
-	playbackURL := m.plexClient.BaseURL + partKey
+	// The queue items from PMS carry viewOffset if partially watched.
+	return m.play(item, title)
+}
+
+// play starts v in mpv. When v was partially watched past the resume
+// threshold, the resume prompt asks where to start first.
+func (m *MainModel) play(v plex.Video, title string) tea.Cmd {
+	offset := int64(v.ViewOffset)
+	if !m.cfg.Player.Resumable(offset) {
+		m.currentView = shared.ViewPlayer
+		return m.launch(v, title, 0)
+	}
+	// The prompt would be hidden behind the mpv window kept open between
+	// the items of a queue: player.resume_default decides instead
+	if m.session != nil && m.session.Running() {
+		if m.cfg.Player.ResumeDefault == config.ResumeStartOver {
+			offset = 0
+		}
+		m.currentView = shared.ViewPlayer
+		return m.launch(v, title, offset)
+	}
+
+	cursor := choiceResume
+	if m.cfg.Player.ResumeDefault == config.ResumeStartOver {
+		cursor = choiceStartOver
+	}
+	m.currentView = shared.ViewResume
+	m.resume = ResumeModel{
+		Title:    title,
+		OffsetMs: offset,
+		Cursor:   cursor,
+		PlayAction: func(startMs int64) tea.Cmd {
+			m.currentView = shared.ViewPlayer
+			return m.launch(v, title, startMs)
+		},
+		CancelAction: func() tea.Cmd {
+			return func() tea.Msg { return shared.MsgBack{} }
+		},
+	}
+	return nil
+}
 
-	// Determine start time (resume) only if it's the item we started with?
-	// The queue items from PMS usually have viewOffset if partially watched.
-	startTime := int64(item.ViewOffset)
+// playable reports whether v has a file to play, on the server or
+// downloaded.
+func (m *MainModel) playable(v plex.Video) bool {
+	if len(v.Media) > 0 && len(v.Media[0].Part) > 0 {
+		return true
+	}
+	_, ok := m.localFile(v.RatingKey)
+	return ok
+}
 
+// launch plays v from startMs in the background, to be followed on the
+// Now Playing panel. The mpv still open from the previous item of the queue
+// is reused, and a downloaded file is played instead of the server's
+// stream.
+func (m *MainModel) launch(v plex.Video, title string, startMs int64) tea.Cmd {
+	p, cfg, reporter := m.plexClient, m.cfg, m.reporter()
+	local, hasLocal := m.localFile(v.RatingKey)
+	running := m.session
+	if running != nil && !running.Running() {
+		running = nil
+	}
+	m.nowPlaying = NowPlayingModel{Title: title, Starting: true}
 	return func() tea.Msg {
-		completed, err := player.Play(title, playbackURL, item.RatingKey, startTime, m.cfg, m.plexClient)
+		playbackURL := local
+		if !hasLocal {
+			// Direct play or transcode depending on player.quality
+			var err error
+			if playbackURL, err = p.PlaybackURL(v, cfg.Player.Quality); err != nil {
+				return shared.MsgError{Err: err}
+			}
+		}
+		if running != nil {
+			if err := running.Load(title, playbackURL, v.RatingKey, startMs); err == nil {
+				return msgPlayerLoaded{session: running, title: title}
+			}
+			// mpv quit meanwhile, or cannot be controlled: start over
+			running.Quit()
+		}
+		session, err := player.Start(title, playbackURL, v.RatingKey, startMs, cfg, reporter)
 		if err != nil {
 			return shared.MsgError{Err: err}
 		}
-		return MsgPlaybackFinished{Completed: completed}
+		return msgPlayerStarted{session: session, title: title}
 	}
 }
 
+// reporter returns the progress sink for playback, mirroring state into the cache.
+func (m *MainModel) reporter() player.Reporter {
+	return m.watchReporter()
+}
+
 func (m *MainModel) View() string {
 	// Global header/footer can be added here if needed, but submodels currently handle their own.
 	// We'll pass the sync status to submodels or wrap their view.
@@ -375,11 +592,21 @@ func (m *MainModel) View() string {
 	case shared.ViewMovieBrowser, shared.ViewSeriesBrowser:
 		s = m.browser.View()
 	case shared.ViewPlayer:
-		s = shared.StyleBorder.Render(shared.StyleTitle.Render("▶ Playing Video..."))
+		s = m.nowPlaying.View()
 	case shared.ViewCountdown:
 		s = m.countdown.View()
+	case shared.ViewResume:
+		s = m.resume.View()
 	case shared.ViewSettings:
 		s = m.settings.View()
+	case shared.ViewSearch:
+		s = m.search.View()
+	case shared.ViewServers:
+		s = m.servers.View()
+	case shared.ViewUsers:
+		s = m.users.View()
+	case shared.ViewDownloads:
+		s = m.downloadsView.View()
 	default:
 		s = "Unknown View"
 	}
@@ -415,6 +642,12 @@ func (m *MainModel) getSyncDisplay() string {
 	if m.syncAdded > 0 {
 		status += fmt.Sprintf(" +%d", m.syncAdded)
 	}
+	if m.syncRemoved > 0 {
+		status += fmt.Sprintf(" -%d", m.syncRemoved)
+	}
+	if m.syncCancel != nil {
+		status += " (ctrl+x to cancel)"
+	}
 
 	return fmt.Sprintf("%s %s", status, dotsPadded)
 }
@@ -423,6 +656,10 @@ func (m *MainModel) updateSubmodelsSyncStatus() {
 	display := m.getSyncDisplay()
 	m.dashboard.SyncStatus = display
 	m.settings.SyncStatus = display
+	m.search.SyncStatus = display
+	m.servers.SyncStatus = display
+	m.users.SyncStatus = display
+	m.downloadsView.SyncStatus = display
 	if m.browser != nil {
 		m.browser.SyncStatus = display
 	}
@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/player"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// NowPlayingModel shows what mpv is playing and remote-controls it while
+// the window is in the background.
+type NowPlayingModel struct {
+	Title    string
+	Starting bool // mpv is being launched
+
+	// Controller drives mpv; nil when it cannot be controlled
+	Controller *player.Controller
+
+	Position float64 // Seconds
+	Duration float64 // Seconds
+	Paused   bool
+	Audio    string // Selected audio track
+	Subs     string // Selected subtitle track, empty when off
+
+	// Error of the last command
+	Err string
+}
+
+// msgPlayerCommand reports the outcome of a command sent to mpv.
+type msgPlayerCommand struct {
+	err error
+}
+
+func (m *NowPlayingModel) Init() tea.Cmd {
+	return nil
+}
+
+// Apply shows a change reported by mpv.
+func (m *NowPlayingModel) Apply(ev player.Event) {
+	if ev.Name != "property-change" {
+		return
+	}
+	m.set(ev.Property, ev.Data)
+}
+
+// set shows the value of an observed property.
+func (m *NowPlayingModel) set(name string, value interface{}) {
+	switch name {
+	case "time-pos":
+		m.Position, _ = value.(float64)
+	case "duration":
+		m.Duration, _ = value.(float64)
+	case "pause":
+		m.Paused, _ = value.(bool)
+	case "media-title":
+		if title, _ := value.(string); title != "" {
+			m.Title = title
+		}
+	case "track-list":
+		m.Audio = player.SelectedTrack(value, "audio")
+		m.Subs = player.SelectedTrack(value, "sub")
+	}
+}
+
+func (m *NowPlayingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case msgPlayerCommand:
+		m.Err = ""
+		if msg.err != nil {
+			m.Err = msg.err.Error()
+		}
+	case tea.KeyMsg:
+		if m.Controller == nil {
+			return m, nil
+		}
+		c := m.Controller
+		switch key := msg.String(); key {
+		case " ", "p":
+			paused := !m.Paused
+			return m, m.send(func() error { return c.SetPause(paused) })
+		case "left", "h":
+			return m, m.send(func() error { return c.Seek(-10 * time.Second) })
+		case "right", "l":
+			return m, m.send(func() error { return c.Seek(10 * time.Second) })
+		case "down", "j":
+			return m, m.send(func() error { return c.Seek(-time.Minute) })
+		case "up", "k":
+			return m, m.send(func() error { return c.Seek(time.Minute) })
+		case "s":
+			return m, m.send(c.CycleSubs)
+		case "a":
+			return m, m.send(c.CycleAudio)
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			id := int(key[0] - '0')
+			return m, m.send(func() error { return c.SetAudioTrack(id) })
+		case "q", "esc":
+			// Playback ends like closing the window, through the player's exit
+			return m, m.send(c.Quit)
+		}
+	}
+	return m, nil
+}
+
+func (m *NowPlayingModel) send(command func() error) tea.Cmd {
+	return func() tea.Msg {
+		return msgPlayerCommand{err: command()}
+	}
+}
+
+func (m NowPlayingModel) View() string {
+	title := shared.StyleTitle.Render("▶ Now Playing")
+
+	state := "▶ Playing"
+	if m.Paused {
+		state = "⏸ Paused"
+	}
+	content := fmt.Sprintf("\n%s\n\n%s  %s / %s  %s\n",
+		lipgloss.NewStyle().Bold(true).Foreground(shared.ColorPlexOrange).Render(m.Title),
+		state,
+		formatOffset(int64(m.Position*1000)),
+		formatOffset(int64(m.Duration*1000)),
+		playbackBar(m.Position, m.Duration, 30))
+
+	subs := m.Subs
+	if subs == "" {
+		subs = "off"
+	}
+	tracks := fmt.Sprintf("Audio: %s • Subtitles: %s", orDash(m.Audio), subs)
+
+	lines := []string{title, content, tracks}
+	if m.Err != "" {
+		lines = append(lines, lipgloss.NewStyle().Foreground(shared.ColorRed).Render("\n⚠ "+m.Err))
+	}
+
+	helpText := "\n(Space=Pause, ←/→ ±10s, ↑/↓ ±1min, S=Subtitles, A=Audio, 1-9=Audio Track, Q=Stop)"
+	switch {
+	case m.Starting:
+		helpText = "\n(Starting the player...)"
+	case m.Controller == nil:
+		helpText = "\n(Remote control unavailable, close the player window to stop)"
+	}
+	lines = append(lines, lipgloss.NewStyle().Foreground(shared.ColorLightGrey).Render(helpText))
+
+	return shared.StyleBorder.Render(lipgloss.JoinVertical(lipgloss.Center, lines...))
+}
+
+// playbackBar draws how far pos is into duration.
+func playbackBar(pos, duration float64, width int) string {
+	filled := 0
+	if duration > 0 {
+		filled = min(max(int(pos/duration*float64(width)), 0), width)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
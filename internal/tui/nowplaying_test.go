@@ -0,0 +1,73 @@
+package tui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/player"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestNowPlayingModel(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := player.NewController(client)
+	defer c.Close()
+
+	// Answer every command like mpv, handing them over
+	commands := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			var req struct {
+				Command   []interface{} `json:"command"`
+				RequestID int           `json:"request_id"`
+			}
+			json.Unmarshal(scanner.Bytes(), &req)
+			commands <- fmt.Sprint(req.Command)
+			data, _ := json.Marshal(map[string]interface{}{"request_id": req.RequestID, "error": "success"})
+			server.Write(append(data, '\n'))
+		}
+	}()
+
+	m := &NowPlayingModel{Title: "Alien", Controller: c}
+	m.Apply(player.Event{Name: "property-change", Property: "pause", Data: true})
+	m.Apply(player.Event{Name: "property-change", Property: "duration", Data: 100.0})
+	m.Apply(player.Event{Name: "property-change", Property: "track-list", Data: []interface{}{
+		map[string]interface{}{"id": 1.0, "type": "audio", "lang": "eng", "selected": true},
+		map[string]interface{}{"id": 1.0, "type": "sub", "lang": "fre", "title": "Forced", "selected": true},
+	}})
+	if !m.Paused || m.Duration != 100 || m.Audio != "eng" || m.Subs != "fre (Forced)" {
+		t.Errorf("Expected the state of mpv, got %+v", m)
+	}
+
+	tests := []struct {
+		key  tea.KeyMsg
+		want string
+	}{
+		{tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}, "[set_property pause false]"},
+		{tea.KeyMsg{Type: tea.KeyLeft}, "[seek -10 relative]"},
+		{tea.KeyMsg{Type: tea.KeyUp}, "[seek 60 relative]"},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")}, "[cycle sub]"},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")}, "[set_property aid 2]"},
+		{tea.KeyMsg{Type: tea.KeyEsc}, "[quit]"},
+	}
+	for _, tt := range tests {
+		_, cmd := m.Update(tt.key)
+		if cmd == nil {
+			t.Fatalf("%s: expected a command", tt.key)
+		}
+		result := make(chan tea.Msg)
+		go func() { result <- cmd() }()
+		if got := <-commands; got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.key, tt.want, got)
+		}
+		m.Update(<-result)
+		if m.Err != "" {
+			t.Errorf("%s: unexpected error %s", tt.key, m.Err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"github.com/Waddenn/plex-client/internal/player"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Properties shown by the Now Playing panel.
+var nowPlayingProperties = []string{"time-pos", "duration", "pause", "media-title", "track-list"}
+
+// msgPlayerStarted reports mpv running, not yet done playing.
+type msgPlayerStarted struct {
+	session *player.Session
+	title   string
+}
+
+// msgPlayerLoaded reports the next item of the queue playing in the mpv
+// already open.
+type msgPlayerLoaded struct {
+	session *player.Session
+	title   string
+}
+
+// msgPlayerEnded reports an item done playing; mpv stays open.
+type msgPlayerEnded struct {
+	session *player.Session
+	result  player.Result
+}
+
+// msgPlayerExited reports mpv gone.
+type msgPlayerExited struct {
+	session *player.Session
+	err     error
+}
+
+// msgPlayerEvent carries one event of mpv along with its stream.
+type msgPlayerEvent struct {
+	event  player.Event
+	events <-chan player.Event
+	closed bool // mpv quit
+}
+
+// msgPlayerObserved reports the panel's properties observed, so their
+// current values can be shown.
+type msgPlayerObserved struct {
+	controller *player.Controller
+}
+
+func waitForPlayerEvent(events <-chan player.Event) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		return msgPlayerEvent{event: ev, events: events, closed: !ok}
+	}
+}
+
+// waitForPlayback waits for the current item to end, or for mpv to exit.
+func waitForPlayback(s *player.Session) tea.Cmd {
+	return func() tea.Msg {
+		if r, ok := <-s.Ended(); ok {
+			return msgPlayerEnded{session: s, result: r}
+		}
+		return msgPlayerExited{session: s, err: s.Wait()}
+	}
+}
+
+// stopPlayer closes mpv, if still open.
+func (m *MainModel) stopPlayer() tea.Cmd {
+	s := m.session
+	if s == nil {
+		return nil
+	}
+	m.session, m.playerEvents = nil, nil
+	return func() tea.Msg {
+		s.Quit()
+		return nil
+	}
+}
+
+// showPlayerValues shows the last values of the panel's properties.
+func (m *MainModel) showPlayerValues(c *player.Controller) {
+	for _, name := range nowPlayingProperties {
+		if v, ok := c.Value(name); ok {
+			m.nowPlaying.set(name, v)
+		}
+	}
+}
+
+// handlePlayerMsg routes the messages of a running mpv and reports whether
+// msg was one.
+func (m *MainModel) handlePlayerMsg(msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case msgPlayerStarted:
+		stop := m.stopPlayer()
+		m.session = msg.session
+		m.nowPlaying = NowPlayingModel{Title: msg.title, Controller: msg.session.Controller}
+		wait := tea.Batch(stop, waitForPlayback(msg.session))
+		c := msg.session.Controller
+		if c == nil {
+			return wait, true
+		}
+		// Subscribe before observing, so that no change is missed
+		events, _ := c.Subscribe()
+		m.playerEvents = events
+		observe := func() tea.Msg {
+			c.Observe(nowPlayingProperties...)
+			return msgPlayerObserved{controller: c}
+		}
+		return tea.Batch(wait, waitForPlayerEvent(events), observe), true
+	case msgPlayerLoaded:
+		if msg.session == m.session {
+			m.nowPlaying = NowPlayingModel{Title: msg.title, Controller: msg.session.Controller}
+			m.showPlayerValues(msg.session.Controller)
+		}
+		return nil, true
+	case msgPlayerObserved:
+		// Properties observed earlier, e.g. by the progress monitor, sent
+		// their first value before the subscription
+		if msg.controller == m.nowPlaying.Controller {
+			m.showPlayerValues(msg.controller)
+		}
+		return nil, true
+	case msgPlayerEvent:
+		// Events of a previous mpv are dropped
+		if msg.closed || msg.events != m.playerEvents {
+			return nil, true
+		}
+		m.nowPlaying.Apply(msg.event)
+		return waitForPlayerEvent(msg.events), true
+	case msgPlayerEnded:
+		wait := waitForPlayback(msg.session)
+		if msg.session != m.session {
+			return wait, true
+		}
+		completed := msg.result.Completed
+		return tea.Batch(wait, func() tea.Msg { return MsgPlaybackFinished{Completed: completed} }), true
+	case msgPlayerExited:
+		var cancel tea.Cmd
+		if msg.session == m.session {
+			m.session, m.playerEvents = nil, nil
+			// Closing mpv during the countdown is the way to cancel it
+			// while its window hides the terminal
+			if m.currentView == shared.ViewCountdown {
+				cancel = m.countdown.CancelAction()
+			}
+		}
+		if msg.err != nil {
+			err := msg.err
+			return func() tea.Msg { return shared.MsgError{Err: err} }, true
+		}
+		return cancel, true
+	}
+	return nil, false
+}
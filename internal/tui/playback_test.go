@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/db"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+)
+
+func TestPlayCachedMovie(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	d, err := db.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	media := func(bitrate int, part string) []plex.Media {
+		return []plex.Media{{VideoResolution: "720", Bitrate: bitrate, Part: []plex.Part{{Key: part}}}}
+	}
+	var n int
+	if err := cache.SaveMovies(d, "1", []plex.Video{
+		{RatingKey: "1", Title: "Heat", Media: media(2000, "/library/parts/1/heat.mkv")},
+		{RatingKey: "2", Title: "Alien", Media: media(12000, "/library/parts/2/alien.mkv")},
+	}, &n, nil); err != nil {
+		t.Fatal(err)
+	}
+	movies, err := store.New(d).ListMovies("1")
+	if err != nil || len(movies) != 2 {
+		t.Fatalf("Expected 2 cached movies, got %d (%v)", len(movies), err)
+	}
+	byTitle := map[string]plex.Video{}
+	for _, v := range movies {
+		byTitle[v.Title] = v
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s for direct play", r.URL.Path)
+	}))
+	defer srv.Close()
+	cfg := config.Defaults()
+	cfg.Player.Quality = "1080p-8mbps"
+	m := &MainModel{cfg: cfg, db: d, plexClient: plex.New(srv.URL, "token", "client-id", appinfo.Default())}
+
+	// Within the limits of the preset, as launch decides
+	heat := byTitle["Heat"]
+	if !m.playable(heat) {
+		t.Fatalf("Expected the cached movie to be playable, got %+v", heat.Media)
+	}
+	got, err := m.plexClient.PlaybackURL(heat, cfg.Player.Quality)
+	if want := srv.URL + "/library/parts/1/heat.mkv"; err != nil || got != want {
+		t.Errorf("Expected direct play of %s, got %s (%v)", want, got, err)
+	}
+
+	q, _ := plex.ParseQuality(cfg.Player.Quality)
+	if !plex.NeedsTranscode(byTitle["Alien"], q) {
+		t.Errorf("Expected the cached bitrate over the limit to be transcoded, got %+v", byTitle["Alien"].Media)
+	}
+}
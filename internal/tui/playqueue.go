@@ -3,25 +3,34 @@ package tui
 import (
 	"fmt"
 
+	"github.com/Waddenn/plex-client/internal/download"
 	"github.com/Waddenn/plex-client/internal/plex"
 	"github.com/Waddenn/plex-client/internal/tui/shared"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// fetchPlayQueue creates a Play Queue from an episode
-func fetchPlayQueue(p *plex.Client, initialVideo plex.Video) tea.Cmd {
+// fetchPlayQueue creates a Play Queue from an episode. When the server
+// cannot be reached, a downloaded episode plays on its own.
+func fetchPlayQueue(p *plex.Client, downloads *download.Manager, initialVideo plex.Video) tea.Cmd {
 	return func() tea.Msg {
+		offline := func(err error) tea.Msg {
+			if _, ok := downloads.Store.LocalPath(initialVideo.RatingKey); ok {
+				return MsgQueueLoaded{Queue: []plex.Video{initialVideo}}
+			}
+			return shared.MsgError{Err: err}
+		}
+
 		// Fetch full metadata to ensure we have parent keys (if not already present)
 		// We might need to refresh because Dashboard OnDeck might be partial
 		video, err := p.GetMetadata(initialVideo.RatingKey)
 		if err != nil {
-			return shared.MsgError{Err: fmt.Errorf("failed to get metadata: %w", err)}
+			return offline(fmt.Errorf("failed to get metadata: %w", err))
 		}
 
 		// Create Play Queue
 		pq, err := p.CreatePlayQueue(*video)
 		if err != nil {
-			return shared.MsgError{Err: fmt.Errorf("failed to create play queue: %w", err)}
+			return offline(fmt.Errorf("failed to create play queue: %w", err))
 		}
 
 		// Find index of our starting item
@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"errors"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/tui/dashboard"
+	"github.com/Waddenn/plex-client/internal/tui/login"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SessionExpired explains a return to the login screen after Plex rejected
+// the saved token.
+const SessionExpired = "Plex no longer accepts the saved sign-in; it may have been revoked or signed out. Sign in again to continue."
+
+// RequireLogin shows the login screen with reason, stopping any sync. The
+// dashboard comes back once login succeeds.
+func (m *MainModel) RequireLogin(reason string) {
+	m.cancelSync()
+	m.login = login.NewModel(m.cfg, m.appInfo)
+	m.login.Reason = reason
+	if m.width > 0 && m.height > 0 {
+		newLogin, _ := m.login.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		m.login = newLogin.(login.Model)
+	}
+	m.currentView = shared.ViewLogin
+	m.playQueue = nil
+	m.queueIdx = 0
+}
+
+// handleUnauthorized returns to the login screen when msg reports that the
+// server rejected the token, and reports whether it did.
+func (m *MainModel) handleUnauthorized(msg tea.Msg) (tea.Cmd, bool) {
+	var err error
+	switch msg := msg.(type) {
+	case shared.MsgError:
+		err = msg.Err
+	case dashboard.MsgOnDeckLoaded:
+		err = msg.Err
+	case msgSyncEvent:
+		err = msg.event.Err
+	case msgWatchChanged:
+		err = msg.Err
+	case msgOutboxReplayed:
+		err = msg.Err
+	case msgDownloadsQueued:
+		err = msg.Err
+	}
+	if !errors.Is(err, plex.ErrUnauthorized) || m.currentView == shared.ViewLogin {
+		return nil, false
+	}
+
+	var syncCmd tea.Cmd
+	if ev, ok := msg.(msgSyncEvent); ok {
+		// Keep draining the sync so it rolls back and finishes
+		syncCmd = m.handleSyncEvent(ev)
+	}
+	m.RequireLogin(SessionExpired)
+	return tea.Batch(m.login.Init(), syncCmd), true
+}
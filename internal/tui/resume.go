@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Choices of the resume prompt, in display order.
+const (
+	choiceResume = iota
+	choiceStartOver
+	choiceCancel
+	choiceCount
+)
+
+// ResumeModel asks whether to resume a partially watched item or start it
+// over before playback starts.
+type ResumeModel struct {
+	Title        string
+	OffsetMs     int64
+	Cursor       int
+	PlayAction   func(startMs int64) tea.Cmd
+	CancelAction func() tea.Cmd
+}
+
+func (m *ResumeModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *ResumeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "left", "h", "up", "k", "shift+tab":
+		m.Cursor = (m.Cursor + choiceCount - 1) % choiceCount
+	case "right", "l", "down", "j", "tab":
+		m.Cursor = (m.Cursor + 1) % choiceCount
+	case "enter", " ":
+		return m, m.choose(m.Cursor)
+	case "r":
+		return m, m.choose(choiceResume)
+	case "s", "b":
+		return m, m.choose(choiceStartOver)
+	case "esc", "q", "n", "ctrl+c":
+		return m, m.choose(choiceCancel)
+	}
+	return m, nil
+}
+
+func (m *ResumeModel) choose(choice int) tea.Cmd {
+	switch choice {
+	case choiceResume:
+		return m.PlayAction(m.OffsetMs)
+	case choiceStartOver:
+		return m.PlayAction(0)
+	}
+	return m.CancelAction()
+}
+
+func (m ResumeModel) View() string {
+	title := shared.StyleTitle.Render("▶ Resume Playback")
+
+	content := fmt.Sprintf("\n%s\n\nYou stopped at %s.\n",
+		lipgloss.NewStyle().Bold(true).Foreground(shared.ColorPlexOrange).Render(m.Title),
+		formatOffset(m.OffsetMs))
+
+	labels := []string{"Resume from " + formatOffset(m.OffsetMs), "Start Over", "Cancel"}
+	buttons := make([]string, len(labels))
+	for i, label := range labels {
+		style := shared.StyleItemNormal
+		if i == m.Cursor {
+			style = shared.StyleItemActive
+		}
+		buttons[i] = style.Copy().PaddingRight(2).Render(label)
+	}
+
+	help := lipgloss.NewStyle().Foreground(shared.ColorLightGrey).Render("\n(←/→ Choose, Enter=Confirm, R=Resume, S=Start Over, Esc=Cancel)")
+
+	return shared.StyleBorder.Render(lipgloss.JoinVertical(lipgloss.Center,
+		title,
+		content,
+		strings.Join(buttons, " "),
+		help,
+	))
+}
+
+// formatOffset formats a position as "42:10", or "1:02:05" past an hour.
+func formatOffset(ms int64) string {
+	s := ms / 1000
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
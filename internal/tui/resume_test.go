@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/player"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestResumeModel(t *testing.T) {
+	tests := []struct {
+		keys  []string
+		start int64 // -1 for cancel
+	}{
+		{[]string{"enter"}, 2_530_000},
+		{[]string{"right", "enter"}, 0},
+		{[]string{"left", "enter"}, -1},
+		{[]string{"s"}, 0},
+		{[]string{"right", "r"}, 2_530_000},
+		{[]string{"esc"}, -1},
+	}
+	for _, tt := range tests {
+		got := int64(-2)
+		m := &ResumeModel{
+			OffsetMs:     2_530_000,
+			PlayAction:   func(startMs int64) tea.Cmd { got = startMs; return nil },
+			CancelAction: func() tea.Cmd { got = -1; return nil },
+		}
+		for _, k := range tt.keys {
+			msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+			switch k {
+			case "enter":
+				msg = tea.KeyMsg{Type: tea.KeyEnter}
+			case "esc":
+				msg = tea.KeyMsg{Type: tea.KeyEsc}
+			case "left":
+				msg = tea.KeyMsg{Type: tea.KeyLeft}
+			case "right":
+				msg = tea.KeyMsg{Type: tea.KeyRight}
+			}
+			m.Update(msg)
+		}
+		if got != tt.start {
+			t.Errorf("%v: expected start %d, got %d", tt.keys, tt.start, got)
+		}
+	}
+
+	if got := formatOffset(2_530_000); got != "42:10" {
+		t.Errorf("Expected 42:10, got %s", got)
+	}
+	if got := formatOffset(3_725_000); got != "1:02:05" {
+		t.Errorf("Expected 1:02:05, got %s", got)
+	}
+}
+
+func TestPlayAsksToResume(t *testing.T) {
+	cfg := config.Defaults()
+	m := &MainModel{cfg: cfg}
+
+	// Below the threshold, playback starts over without asking
+	if cmd := m.play(plex.Video{RatingKey: "1", ViewOffset: 30_000}, "Alien"); cmd == nil || m.currentView != shared.ViewPlayer {
+		t.Fatalf("Expected playback to start, got view %v", m.currentView)
+	}
+
+	if cmd := m.play(plex.Video{RatingKey: "1", ViewOffset: 2_530_000}, "Alien"); cmd != nil || m.currentView != shared.ViewResume {
+		t.Fatalf("Expected the resume prompt, got view %v", m.currentView)
+	}
+	if m.resume.Cursor != choiceResume || m.resume.OffsetMs != 2_530_000 {
+		t.Errorf("Expected Resume selected at 42:10, got %+v", m.resume)
+	}
+
+	cfg.Player.ResumeDefault = config.ResumeStartOver
+	m.play(plex.Video{RatingKey: "1", ViewOffset: 2_530_000}, "Alien")
+	if m.resume.Cursor != choiceStartOver {
+		t.Errorf("Expected Start Over selected, got cursor %d", m.resume.Cursor)
+	}
+
+	if cmd := m.resume.choose(choiceStartOver); cmd == nil || m.currentView != shared.ViewPlayer {
+		t.Errorf("Expected Start Over to start playback, got view %v", m.currentView)
+	}
+
+	// Not behind the mpv window kept open for the queue
+	m.session = &player.Session{}
+	m.currentView = shared.ViewCountdown
+	if cmd := m.play(plex.Video{RatingKey: "2", ViewOffset: 2_530_000}, "Pilot"); cmd == nil || m.currentView != shared.ViewPlayer {
+		t.Errorf("Expected playback without asking while mpv is open, got view %v", m.currentView)
+	}
+}
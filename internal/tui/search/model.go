@@ -0,0 +1,373 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// resultLimit caps the results of each group.
+	resultLimit = 20
+
+	// remoteDelay debounces server searches while typing.
+	remoteDelay = 300 * time.Millisecond
+)
+
+// group is a titled list of results of one kind.
+type group struct {
+	title string
+	items []plex.Video
+}
+
+// Model is the global search screen. It searches the local cache on every
+// keystroke and, when Remote is set, the server's /hubs/search once typing
+// pauses, merging both into Movies, Shows and Episodes groups.
+type Model struct {
+	plexClient *plex.Client
+	store      *store.Store
+	width      int
+	height     int
+
+	textInput textinput.Model
+
+	// seq identifies the current query; results of older ones are dropped.
+	seq    int
+	local  *store.SearchResults
+	remote []plex.Hub
+	groups []group
+	cursor int
+
+	searchingRemote bool
+	remoteErr       error
+	errorMsg        string
+
+	// Remote also queries the server when set.
+	Remote bool
+
+	// Sync State
+	SyncStatus string
+}
+
+func NewModel(p *plex.Client, s *store.Store, remote bool) Model {
+	ti := textinput.New()
+	ti.Placeholder = "Search movies, shows, episodes, cast, genres..."
+	ti.CharLimit = 156
+	ti.Width = 50
+
+	return Model{
+		plexClient: p,
+		store:      s,
+		width:      80,
+		height:     24,
+		textInput:  ti,
+		Remote:     remote,
+	}
+}
+
+// Focus prepares the screen when it is opened, keeping the last query.
+func (m *Model) Focus() tea.Cmd {
+	m.textInput.Focus()
+	return textinput.Blink
+}
+
+type msgLocalResults struct {
+	seq     int
+	results *store.SearchResults
+	err     error
+}
+
+type msgRemoteTick struct {
+	seq int
+}
+
+type msgRemoteResults struct {
+	seq  int
+	hubs []plex.Hub
+	err  error
+}
+
+func searchLocal(s *store.Store, query string, seq int) tea.Cmd {
+	return func() tea.Msg {
+		results, err := s.Search(query, resultLimit)
+		return msgLocalResults{seq: seq, results: results, err: err}
+	}
+}
+
+func searchRemote(p *plex.Client, query string, seq int) tea.Cmd {
+	return func() tea.Msg {
+		hubs, err := p.SearchHubs(query, resultLimit)
+		return msgRemoteResults{seq: seq, hubs: hubs, err: err}
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return shared.MsgBack{} }
+		case "up", "ctrl+p":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			return m, nil
+		case "down", "ctrl+n":
+			if m.cursor < len(m.items())-1 {
+				m.cursor++
+			}
+			return m, nil
+		case "enter":
+			items := m.items()
+			if m.cursor >= len(items) {
+				return m, nil
+			}
+			item := items[m.cursor]
+			if item.Type == "show" {
+				return m, func() tea.Msg { return shared.MsgOpenShow{Show: item} }
+			}
+			return m, func() tea.Msg { return shared.MsgPlayVideo{Video: item} }
+		}
+
+		before := m.textInput.Value()
+		var cmd tea.Cmd
+		m.textInput, cmd = m.textInput.Update(msg)
+		query := strings.TrimSpace(m.textInput.Value())
+		if m.textInput.Value() == before {
+			return m, cmd
+		}
+
+		m.seq++
+		m.cursor = 0
+		m.remote = nil
+		m.remoteErr = nil
+		m.searchingRemote = false
+		if query == "" {
+			m.local = nil
+			m.groups = nil
+			return m, cmd
+		}
+
+		cmds := []tea.Cmd{cmd, searchLocal(m.store, query, m.seq)}
+		if m.Remote {
+			seq := m.seq
+			cmds = append(cmds, tea.Tick(remoteDelay, func(time.Time) tea.Msg { return msgRemoteTick{seq: seq} }))
+		}
+		return m, tea.Batch(cmds...)
+
+	case msgLocalResults:
+		if msg.seq != m.seq {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.errorMsg = fmt.Sprintf("Search failed: %v", msg.err)
+			return m, nil
+		}
+		m.errorMsg = ""
+		m.local = msg.results
+		m.rebuild()
+
+	case msgRemoteTick:
+		query := strings.TrimSpace(m.textInput.Value())
+		if msg.seq != m.seq || query == "" {
+			return m, nil
+		}
+		m.searchingRemote = true
+		return m, searchRemote(m.plexClient, query, msg.seq)
+
+	case msgRemoteResults:
+		if msg.seq != m.seq {
+			return m, nil
+		}
+		m.searchingRemote = false
+		m.remoteErr = msg.err
+		if msg.err == nil {
+			m.remote = msg.hubs
+			m.rebuild()
+		}
+	}
+	return m, nil
+}
+
+// rebuild merges local and server results into groups. Local results come
+// first; server results only add items the cache does not have yet.
+func (m *Model) rebuild() {
+	var selected string
+	if items := m.items(); m.cursor < len(items) {
+		selected = items[m.cursor].RatingKey
+	}
+
+	groups := []group{{title: "Movies"}, {title: "Shows"}, {title: "Episodes"}}
+	if m.local != nil {
+		groups[0].items = append(groups[0].items, m.local.Movies...)
+		groups[1].items = append(groups[1].items, m.local.Shows...)
+		groups[2].items = append(groups[2].items, m.local.Episodes...)
+	}
+
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		for _, item := range g.items {
+			seen[item.RatingKey] = true
+		}
+	}
+	add := func(i int, v plex.Video) {
+		if !seen[v.RatingKey] && len(groups[i].items) < resultLimit {
+			seen[v.RatingKey] = true
+			groups[i].items = append(groups[i].items, v)
+		}
+	}
+	for _, hub := range m.remote {
+		switch hub.Type {
+		case "movie":
+			for _, v := range hub.Videos {
+				add(0, v)
+			}
+		case "show":
+			for _, d := range hub.Directories {
+				add(1, plex.Video{
+					RatingKey:        d.RatingKey,
+					Title:            d.Title,
+					Summary:          d.Summary,
+					Year:             d.Year,
+					Type:             "show",
+					LibrarySectionID: d.LibrarySectionID,
+				})
+			}
+		case "episode":
+			for _, v := range hub.Videos {
+				add(2, v)
+			}
+		}
+	}
+
+	m.groups = nil
+	for _, g := range groups {
+		if len(g.items) > 0 {
+			m.groups = append(m.groups, g)
+		}
+	}
+
+	// Keep the selection on the same item when server results arrive
+	m.cursor = 0
+	for i, item := range m.items() {
+		if item.RatingKey == selected {
+			m.cursor = i
+			break
+		}
+	}
+}
+
+// items returns the results of all groups in display order.
+func (m Model) items() []plex.Video {
+	var items []plex.Video
+	for _, g := range m.groups {
+		items = append(items, g.items...)
+	}
+	return items
+}
+
+func (m *Model) View() string {
+	availableWidth := shared.ClampMin(m.width, 20)
+	availableHeight := shared.ClampMin(m.height, 10)
+
+	title := "📂 Plex CLI > Search"
+	if m.SyncStatus != "" {
+		title += shared.StyleDim.Render("  " + m.SyncStatus)
+	}
+	header, headerHeight := shared.RenderHeaderLegacySafe(title, availableWidth)
+
+	status := fmt.Sprintf("%d results", len(m.items()))
+	switch {
+	case m.searchingRemote:
+		status += " • searching server..."
+	case m.remoteErr != nil:
+		status += " • server search unavailable"
+	}
+	help := "[↑/↓] Navigate • [Enter] Play/Open • [Esc] Back"
+	footer, footerHeight := shared.RenderFooterLegacySafe(status, help, availableWidth)
+
+	input := lipgloss.NewStyle().Padding(0, 1).Render("🔍 " + m.textInput.View())
+	bodyHeight := shared.ClampMin(availableHeight-headerHeight-footerHeight-2, 1)
+
+	var body string
+	switch {
+	case m.errorMsg != "":
+		body = lipgloss.NewStyle().Foreground(shared.ColorRed).Padding(0, 2).Render(m.errorMsg)
+	case strings.TrimSpace(m.textInput.Value()) == "":
+		body = shared.StyleDim.Copy().Padding(0, 2).Render("Type to search all libraries.")
+	case len(m.groups) == 0:
+		body = shared.StyleDim.Copy().Padding(0, 2).Render("No results.")
+	default:
+		body = m.renderResults(availableWidth, bodyHeight)
+	}
+	body = lipgloss.NewStyle().Width(availableWidth).Height(bodyHeight).MaxHeight(bodyHeight).Render(body)
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, input, "", body, footer)
+}
+
+// renderResults renders the groups, scrolled so the cursor stays visible.
+func (m *Model) renderResults(width, height int) string {
+	var lines []string
+	cursorLine := 0
+	idx := 0
+	for _, g := range m.groups {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, shared.StyleTitle.Render(fmt.Sprintf("%s (%d)", g.title, len(g.items))))
+		for _, item := range g.items {
+			active := idx == m.cursor
+			if active {
+				cursorLine = len(lines)
+			}
+			lines = append(lines, renderRow(item, active, width))
+			idx++
+		}
+	}
+
+	start := 0
+	if cursorLine >= height {
+		start = cursorLine - height + 1
+	}
+	end := start + height
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return strings.Join(lines[start:end], "\n")
+}
+
+func renderRow(item plex.Video, active bool, width int) string {
+	prefix := "  "
+	style := shared.StyleItemNormal
+	if active {
+		prefix = shared.SelectionIndicator()
+		style = shared.StyleItemNormal.Copy().Foreground(shared.ColorPlexOrange).Bold(true)
+	}
+
+	label := item.Title
+	switch item.Type {
+	case "movie":
+		if item.Year > 0 {
+			label = fmt.Sprintf("%s (%d)", item.Title, item.Year)
+		}
+	case "episode":
+		label = fmt.Sprintf("%s - S%02dE%02d - %s", item.GrandparentTitle, item.ParentIndex, item.Index, item.Title)
+	}
+	if item.ViewCount > 0 {
+		label += " ✓"
+	}
+
+	maxLen := shared.ClampMin(width-6, 10)
+	return style.Copy().MaxHeight(1).Width(width).Render(prefix + shared.Truncate(label, maxLen))
+}
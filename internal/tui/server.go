@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"github.com/Waddenn/plex-client/internal/auth"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/db"
+	"github.com/Waddenn/plex-client/internal/plex"
+	"github.com/Waddenn/plex-client/internal/store"
+	"github.com/Waddenn/plex-client/internal/tui/browser"
+	"github.com/Waddenn/plex-client/internal/tui/dashboard"
+	"github.com/Waddenn/plex-client/internal/tui/search"
+	"github.com/Waddenn/plex-client/internal/tui/servers"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openServerPicker shows the saved servers and refreshes them from plex.tv.
+func (m *MainModel) openServerPicker() tea.Cmd {
+	client := auth.NewAuthClient(m.cfg.Plex.ClientIdentifier, m.appInfo)
+	m.servers = servers.NewModel(m.cfg.Plex.Servers, m.cfg.Plex.ServerID, client, m.cfg.Plex.UserToken())
+	m.servers.SyncStatus = m.getSyncDisplay()
+	if m.width > 0 && m.height > 0 {
+		m.servers, _ = m.servers.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+	}
+	m.currentView = shared.ViewServers
+	return m.servers.Init()
+}
+
+// switchServer makes the picked server active. The switch is refused while
+// a sync is writing to the current server's cache.
+func (m *MainModel) switchServer(msg servers.MsgSelectServer) tea.Cmd {
+	if m.syncing {
+		m.servers.ErrorMsg = "A sync is running. Wait for it to finish or cancel it with ctrl+x."
+		return nil
+	}
+
+	next := m.cfg.Plex
+	if len(msg.Servers) > 0 {
+		next.Servers = append([]config.ServerConfig(nil), msg.Servers...)
+	} else {
+		next.Servers = append([]config.ServerConfig(nil), next.Servers...)
+	}
+	next.UseServer(msg.Server)
+	if err := m.connect(next); err != nil {
+		m.servers.ErrorMsg = "Could not open the server cache: " + err.Error()
+		return nil
+	}
+	if err := config.Save(m.cfg); err != nil {
+		m.servers.ErrorMsg = "Could not save the configuration: " + err.Error()
+		return nil
+	}
+
+	m.currentView = shared.ViewDashboard
+	m.playQueue = nil
+	m.queueIdx = 0
+	return tea.Batch(m.dashboard.Init(), m.scheduleBackgroundSync(), m.syncNewServer(), waitForDownloadEvent(m.downloads.Events()))
+}
+
+// connect points the cache, the client and the submodels at the active
+// server and user of plexCfg, which becomes the configuration on success.
+// Each server and home user has its own cache database.
+func (m *MainModel) connect(plexCfg config.PlexConfig) error {
+	if m.db == nil || m.dbCacheID != plexCfg.CacheID() {
+		d, err := db.Open(plexCfg.CacheID())
+		if err != nil {
+			return err
+		}
+		if m.db != nil {
+			// Nothing may use the old cache once it is closed
+			m.stopDownloads()
+			m.work.Close()
+			m.db.Close()
+		}
+		m.db = d
+		m.dbCacheID = plexCfg.CacheID()
+		m.work = shared.NewWork()
+	}
+	m.cfg.Plex = plexCfg
+
+	m.plexClient = plex.New(m.cfg.Plex.BaseURL, m.cfg.Plex.ServerToken(), m.cfg.Plex.ClientIdentifier, m.appInfo)
+	m.plexClient.SetConnections(m.cfg.Plex.ConnectionURIs())
+	m.openOutbox()
+	m.openDownloads()
+	st := store.New(m.db)
+	bm := browser.NewModel(m.plexClient, st, m.cfg.Sync.AutoSync, m.cfg.UI.StatusIndicatorStyle)
+	bm.DefaultSort = defaultSort(m.cfg)
+	bm.Work = m.work
+	m.browser = &bm
+	m.dashboard = dashboard.NewModel(m.plexClient)
+	m.dashboard.ServerName = serverName(m.cfg)
+	m.dashboard.UserName = userName(m.cfg)
+	m.search = search.NewModel(m.plexClient, st, m.cfg.Sync.AutoSync)
+	if m.width > 0 && m.height > 0 {
+		size := tea.WindowSizeMsg{Width: m.width, Height: m.height}
+		_ = m.browser.Update(size)
+		m.dashboard, _ = m.dashboard.Update(size)
+		m.search, _ = m.search.Update(size)
+	}
+	m.updateSubmodelsSyncStatus()
+	m.updateWatchNotice()
+	return nil
+}
+
+// syncNewServer fills an empty cache right away, as the first start does;
+// otherwise it refreshes the cache when auto sync is on.
+func (m *MainModel) syncNewServer() tea.Cmd {
+	var count int
+	if err := m.db.QueryRow("SELECT (SELECT count(*) FROM films) + (SELECT count(*) FROM series)").Scan(&count); err == nil && count == 0 {
+		return func() tea.Msg { return shared.MsgManualSync{} }
+	}
+	if m.cfg.Sync.AutoSync {
+		return func() tea.Msg { return shared.MsgAutoSync{} }
+	}
+	return nil
+}
+
+// serverName labels the active server for headers.
+func serverName(cfg *config.Config) string {
+	if s, ok := cfg.Plex.Server(cfg.Plex.ServerID); ok && s.Name != "" {
+		return s.Name
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/db"
+	"github.com/Waddenn/plex-client/internal/plex"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestConnectWaitsForCacheWork(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := config.Defaults()
+	cfg.Plex.ServerID = "a"
+	cfg.Downloads.Dir = t.TempDir()
+	d, err := db.Open(cfg.Plex.CacheID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := plex.New("http://127.0.0.1:0", "token", "client-id", appinfo.Default())
+	m := NewModel(d, cfg, p, appinfo.Default())
+	defer m.Close()
+
+	// A save still running on the old cache when the server changes
+	started, release := make(chan struct{}), make(chan struct{})
+	saved := make(chan error, 1)
+	save := m.browser.Work.Cmd(func() tea.Msg {
+		close(started)
+		<-release
+		_, err := d.Exec("INSERT OR REPLACE INTO sections (key, title, type, updated_at) VALUES ('1', 'Movies', 'movie', 0)")
+		saved <- err
+		return nil
+	})
+	go save()
+	<-started
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	oldWork, oldEvents := m.work, m.downloads.Events()
+	next := cfg.Plex
+	next.ServerID = "b"
+	if err := m.connect(next); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-saved; err != nil {
+		t.Errorf("Expected the save to finish before the cache closed, got %v", err)
+	}
+	select {
+	case _, ok := <-oldEvents:
+		if ok {
+			t.Error("Expected the old downloads to be stopped")
+		}
+	default:
+		t.Error("Expected the old downloads to be stopped")
+	}
+	if msg := oldWork.Cmd(func() tea.Msg { return "ran" })(); msg != nil {
+		t.Errorf("Expected no work on the closed cache, got %v", msg)
+	}
+	if m.browser.Work != m.work || m.work == oldWork {
+		t.Error("Expected the browser to save to the new cache")
+	}
+}
@@ -0,0 +1,232 @@
+package servers
+
+import (
+	"fmt"
+
+	"github.com/Waddenn/plex-client/internal/auth"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MsgSelectServer is sent when a server is picked.
+type MsgSelectServer struct {
+	Server config.ServerConfig
+	// Servers is the refreshed list of the account's servers
+	Servers []config.ServerConfig
+}
+
+// Model is the server picker, shown after login when the account reaches
+// several servers and from the dashboard to switch servers.
+type Model struct {
+	authClient *auth.AuthClient // Refreshes the list from plex.tv when set
+	token      string
+	width      int
+	height     int
+
+	servers []config.ServerConfig
+	current string // Machine identifier of the active server
+	cursor  int
+
+	refreshing bool
+	refreshErr error
+
+	// Error shown instead of switching, e.g. while a sync is running
+	ErrorMsg string
+	// AllowBack lets Esc leave the picker without choosing
+	AllowBack bool
+
+	// Sync State
+	SyncStatus string
+}
+
+// NewModel lists servers with the active one preselected. With an auth
+// client, Init refreshes the list from the account's resources.
+func NewModel(servers []config.ServerConfig, current string, client *auth.AuthClient, token string) Model {
+	m := Model{
+		authClient: client,
+		token:      token,
+		width:      80,
+		height:     24,
+		servers:    servers,
+		current:    current,
+		AllowBack:  true,
+		refreshing: client != nil,
+	}
+	m.selectCurrent()
+	return m
+}
+
+func (m Model) Init() tea.Cmd {
+	if m.authClient == nil {
+		return nil
+	}
+	return m.refresh()
+}
+
+type msgServersLoaded struct {
+	servers []config.ServerConfig
+	err     error
+}
+
+func (m Model) refresh() tea.Cmd {
+	client, token := m.authClient, m.token
+	return func() tea.Msg {
+		resources, err := client.GetResources(token)
+		if err != nil {
+			return msgServersLoaded{err: err}
+		}
+		return msgServersLoaded{servers: FromResources(resources)}
+	}
+}
+
+// FromResources keeps the servers among the account's resources, each with
+// its connections, the preferred one as base URL.
+func FromResources(resources []auth.PlexResource) []config.ServerConfig {
+	var servers []config.ServerConfig
+	for _, r := range resources {
+		if !r.IsServer() {
+			continue
+		}
+		conns := r.SortedConnections()
+		if len(conns) == 0 {
+			continue
+		}
+		server := config.ServerConfig{
+			Name:              r.Name,
+			MachineIdentifier: r.ClientIdentifier,
+			BaseURL:           conns[0].Uri,
+			AccessToken:       r.AccessToken,
+			Owned:             r.Owned,
+		}
+		for _, c := range conns {
+			server.Connections = append(server.Connections, config.ConnectionConfig{URI: c.Uri, Local: c.Local, Relay: c.Relay})
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+func (m *Model) selectCurrent() {
+	for i, s := range m.servers {
+		if s.MachineIdentifier == m.current {
+			m.cursor = i
+			return
+		}
+	}
+	if m.cursor >= len(m.servers) {
+		m.cursor = 0
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case msgServersLoaded:
+		m.refreshing = false
+		m.refreshErr = msg.err
+		if msg.err == nil {
+			m.servers = msg.servers
+			m.selectCurrent()
+		}
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.servers)-1 {
+				m.cursor++
+			}
+		case "r":
+			if m.authClient != nil && !m.refreshing {
+				m.refreshing = true
+				return m, m.refresh()
+			}
+		case "enter":
+			if m.cursor < len(m.servers) {
+				selected := m.servers[m.cursor]
+				servers := m.servers
+				return m, func() tea.Msg { return MsgSelectServer{Server: selected, Servers: servers} }
+			}
+		case "esc", "q", "backspace":
+			if m.AllowBack {
+				return m, func() tea.Msg { return shared.MsgBack{} }
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *Model) View() string {
+	availableWidth := shared.ClampMin(m.width, 20)
+	availableHeight := shared.ClampMin(m.height, 10)
+
+	title := "📂 Plex CLI > Servers"
+	if m.SyncStatus != "" {
+		title += shared.StyleDim.Render("  " + m.SyncStatus)
+	}
+	header, headerHeight := shared.RenderHeaderLegacySafe(title, availableWidth)
+
+	status := fmt.Sprintf("%d servers", len(m.servers))
+	switch {
+	case m.refreshing:
+		status += " • refreshing..."
+	case m.refreshErr != nil:
+		status += " • refresh failed, showing saved servers"
+	}
+	help := "[↑/↓] Navigate • [Enter] Connect"
+	if m.authClient != nil {
+		help += " • [R] Refresh"
+	}
+	if m.AllowBack {
+		help += " • [Esc] Back"
+	}
+	footer, footerHeight := shared.RenderFooterLegacySafe(status, help, availableWidth)
+
+	bodyHeight := shared.ClampMin(availableHeight-headerHeight-footerHeight, 1)
+	var lines []string
+	if m.ErrorMsg != "" {
+		lines = append(lines, lipgloss.NewStyle().Foreground(shared.ColorRed).Padding(0, 2).Render("⚠ "+m.ErrorMsg), "")
+	}
+	if len(m.servers) == 0 {
+		lines = append(lines, shared.StyleDim.Copy().Padding(0, 2).Render("No servers found."))
+	}
+	for i, s := range m.servers {
+		lines = append(lines, m.renderRow(s, i == m.cursor, availableWidth))
+	}
+	body := lipgloss.NewStyle().Width(availableWidth).Height(bodyHeight).MaxHeight(bodyHeight).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, body, footer)
+}
+
+func (m *Model) renderRow(s config.ServerConfig, active bool, width int) string {
+	prefix := "  "
+	style := shared.StyleItemNormal
+	if active {
+		prefix = shared.SelectionIndicator()
+		style = shared.StyleItemNormal.Copy().Foreground(shared.ColorPlexOrange).Bold(true)
+	}
+
+	name := s.Name
+	if name == "" {
+		name = s.BaseURL
+	}
+	if !s.Owned {
+		name += " (shared)"
+	}
+	if s.MachineIdentifier == m.current {
+		name += " ✓"
+	}
+	label := name + "  " + s.BaseURL
+
+	maxLen := shared.ClampMin(width-6, 10)
+	return style.Copy().MaxHeight(1).Width(width).Render(prefix + shared.Truncate(label, maxLen))
+}
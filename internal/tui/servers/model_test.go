@@ -0,0 +1,73 @@
+package servers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/auth"
+)
+
+func TestFromResources(t *testing.T) {
+	resources := []auth.PlexResource{
+		{
+			Name:             "Living Room TV",
+			Product:          "Plex for Android (TV)",
+			Provides:         "client,player",
+			ClientIdentifier: "tv",
+		},
+		{
+			Name:             "Home",
+			Product:          "Plex Media Server",
+			Provides:         "server",
+			ClientIdentifier: "home-id",
+			Owned:            true,
+			AccessToken:      "home-token",
+			Connections: []auth.PlexConnection{
+				{Address: "172.17.0.2", Uri: "http://172.17.0.2:32400", Local: true},
+				{Address: "192.168.1.10", Uri: "http://192.168.1.10:32400", Local: true},
+			},
+		},
+		{
+			Name:             "Friend",
+			Product:          "Plex Media Server",
+			Provides:         "server",
+			ClientIdentifier: "friend-id",
+			AccessToken:      "friend-token",
+			Connections: []auth.PlexConnection{
+				{Address: "10.0.0.5", Uri: "http://10.0.0.5:32400", Local: true},
+				{Address: "203.0.113.7", Uri: "https://203-0-113-7.plex.direct:32400"},
+				{Address: "198.51.100.1", Uri: "https://relay.plex.direct:8443", Relay: true},
+			},
+		},
+		{
+			Name:             "Offline",
+			Product:          "Plex Media Server",
+			Provides:         "server",
+			ClientIdentifier: "offline-id",
+		},
+	}
+
+	got := FromResources(resources)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 servers, got %+v", got)
+	}
+
+	home, friend := got[0], got[1]
+	if home.MachineIdentifier != "home-id" || home.BaseURL != "http://192.168.1.10:32400" || !home.Owned {
+		t.Errorf("Unexpected home server %+v", home)
+	}
+	if friend.MachineIdentifier != "friend-id" || friend.BaseURL != "https://203-0-113-7.plex.direct:32400" || friend.Owned {
+		t.Errorf("Unexpected shared server %+v", friend)
+	}
+	var uris []string
+	for _, c := range friend.Connections {
+		uris = append(uris, c.URI)
+	}
+	want := []string{"https://203-0-113-7.plex.direct:32400", "http://10.0.0.5:32400", "https://relay.plex.direct:8443"}
+	if strings.Join(uris, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected connections %v, got %v", want, uris)
+	}
+	if friend.AccessToken != "friend-token" {
+		t.Errorf("Expected the shared server's own token, got %q", friend.AccessToken)
+	}
+}
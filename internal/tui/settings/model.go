@@ -17,6 +17,8 @@ const (
 	SettingSubtitles
 	SettingSubLang
 	SettingAudioLang
+	SettingQuality
+	SettingResume
 	SettingIcons
 	SettingStatusIndicator
 	SettingAutoSync
@@ -103,6 +105,12 @@ func (m Model) changeSetting(delta int) tea.Cmd {
 	case SettingAudioLang:
 		langs := []string{"auto", "eng", "fra", "ger", "spa", "ita"}
 		m.cfg.Player.AudioLang = rotate(m.cfg.Player.AudioLang, langs, delta)
+	case SettingQuality:
+		options := []string{"original", "2160p-40mbps", "1080p-8mbps", "720p-4mbps", "480p-2mbps"}
+		m.cfg.Player.Quality = rotate(m.cfg.Player.Quality, options, delta)
+	case SettingResume:
+		options := []string{config.ResumeFromOffset, config.ResumeStartOver}
+		m.cfg.Player.ResumeDefault = rotate(m.cfg.Player.ResumeDefault, options, delta)
 	case SettingIcons:
 		m.cfg.UI.UseIcons = !m.cfg.UI.UseIcons
 	case SettingStatusIndicator:
@@ -175,6 +183,8 @@ func (m Model) View() string {
 			m.renderToggle("Subtitles", "Enabled", m.cfg.Player.SubtitlesEnabled, m.cursor == SettingSubtitles, leftWidth),
 			m.renderChoice("Subtitles Language", defaultAuto(m.cfg.Player.SubtitlesLang), m.cursor == SettingSubLang, leftWidth),
 			m.renderChoice("Audio Language", defaultAuto(m.cfg.Player.AudioLang), m.cursor == SettingAudioLang, leftWidth),
+			m.renderChoice("Playback Quality", defaultAuto(m.cfg.Player.Quality), m.cursor == SettingQuality, leftWidth),
+			m.renderChoice("Resume Default", m.cfg.Player.ResumeDefault, m.cursor == SettingResume, leftWidth),
 			m.renderToggle("UI Icons", "Use icons in menus", m.cfg.UI.UseIcons, m.cursor == SettingIcons, leftWidth),
 			m.renderChoice("Status Indicator", defaultAuto(m.cfg.UI.StatusIndicatorStyle), m.cursor == SettingStatusIndicator, leftWidth),
 			m.renderToggle("Background Sync", "Auto update library", m.cfg.Sync.AutoSync, m.cursor == SettingAutoSync, leftWidth),
@@ -196,6 +206,8 @@ func (m Model) View() string {
 		m.renderToggle("Subtitles", "Enabled", m.cfg.Player.SubtitlesEnabled, m.cursor == SettingSubtitles, width),
 		m.renderChoice("Subtitles Language", defaultAuto(m.cfg.Player.SubtitlesLang), m.cursor == SettingSubLang, width),
 		m.renderChoice("Audio Language", defaultAuto(m.cfg.Player.AudioLang), m.cursor == SettingAudioLang, width),
+		m.renderChoice("Playback Quality", defaultAuto(m.cfg.Player.Quality), m.cursor == SettingQuality, width),
+		m.renderChoice("Resume Default", m.cfg.Player.ResumeDefault, m.cursor == SettingResume, width),
 		m.renderToggle("UI Icons", "Use icons in menus", m.cfg.UI.UseIcons, m.cursor == SettingIcons, width),
 		m.renderChoice("Status Indicator", defaultAuto(m.cfg.UI.StatusIndicatorStyle), m.cursor == SettingStatusIndicator, width),
 		m.renderToggle("Background Sync", "Auto update library", m.cfg.Sync.AutoSync, m.cursor == SettingAutoSync, width),
@@ -270,6 +282,19 @@ func (m Model) renderTip(width int) string {
 		tip = "Preferred subtitle language. Use auto to let MPV decide."
 	case SettingAudioLang:
 		tip = "Preferred audio language. Use auto to let MPV decide."
+	case SettingQuality:
+		switch m.cfg.Player.Quality {
+		case "", "auto", "original":
+			tip = "Plays the original file directly. Best quality, needs enough bandwidth."
+		default:
+			tip = "Asks the server to transcode when the source exceeds this resolution or bitrate. Useful on slow links."
+		}
+	case SettingResume:
+		if m.cfg.Player.ResumeDefault == config.ResumeStartOver {
+			tip = "Partially watched items ask where to start, with Start Over selected."
+		} else {
+			tip = "Partially watched items ask where to start, with Resume selected."
+		}
 	case SettingIcons:
 		tip = "Show icons (🎬, 📺) next to library names in the sidebar."
 	case SettingStatusIndicator:
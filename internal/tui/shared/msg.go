@@ -22,9 +22,36 @@ type MsgPlayVideo struct {
 
 // MsgSyncProgress reports synchronization progress
 type MsgSyncProgress struct {
-	Status string
-	Added  int
-	Done   bool
+	Status  string
+	Added   int
+	Removed int
+	Done    bool
+	Err     error // Set with Done when the sync could not run
+}
+
+// MsgWatchChange requests sending a change of watch state made in the
+// browser to the cache and Plex
+type MsgWatchChange struct {
+	Change interface{} // cache.WatchChange
+}
+
+// MsgDownload requests downloading a movie, an episode or every episode of
+// a season for offline playback
+type MsgDownload struct {
+	Item interface{} // plex.Video, or plex.Directory for a season
+	Show string      // Title of the show of a season
+}
+
+// MsgOpenShow requests opening the seasons of a show in the series browser
+type MsgOpenShow struct {
+	Show interface{} // plex.Video with LibrarySectionID set
 }
 
 type MsgManualSync struct{}
+
+// MsgAutoSync requests an automatic sync. It is skipped during playback or
+// while another sync is running.
+type MsgAutoSync struct{}
+
+// MsgCancelSync requests cancellation of the running sync
+type MsgCancelSync struct{}
@@ -134,4 +134,9 @@ const (
 	ViewCountdown
 	ViewSettings
 	ViewLogin
+	ViewSearch
+	ViewServers
+	ViewUsers
+	ViewResume
+	ViewDownloads
 )
@@ -0,0 +1,64 @@
+package shared
+
+import (
+	"context"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Work tracks the background commands using a cache database, so that the
+// database is only closed once they are done. A nil Work runs everything.
+type Work struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewWork() *Work {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Work{ctx: ctx, cancel: cancel}
+}
+
+// Context is done once Close is called, so that long requests give up.
+func (w *Work) Context() context.Context {
+	if w == nil {
+		return context.Background()
+	}
+	return w.ctx
+}
+
+// Cmd wraps cmd into a command that Close waits for. Once Close was
+// called, the command does nothing and returns nil.
+func (w *Work) Cmd(cmd tea.Cmd) tea.Cmd {
+	if w == nil || cmd == nil {
+		return cmd
+	}
+	return func() tea.Msg {
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			return nil
+		}
+		w.wg.Add(1)
+		w.mu.Unlock()
+		defer w.wg.Done()
+		return cmd()
+	}
+}
+
+// Close cancels the context, refuses new work and waits for the running
+// commands to return.
+func (w *Work) Close() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.cancel()
+	w.wg.Wait()
+}
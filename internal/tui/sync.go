@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// msgBackgroundSyncTick fires every background_sync_interval_minutes.
+type msgBackgroundSyncTick struct{}
+
+// scheduleBackgroundSync arms the next periodic sync if an interval is configured
+// and none is pending yet.
+func (m *MainModel) scheduleBackgroundSync() tea.Cmd {
+	interval := m.cfg.Sync.BackgroundSyncIntervalMin
+	if interval <= 0 || m.syncScheduled {
+		return nil
+	}
+	m.syncScheduled = true
+	return tea.Tick(time.Duration(interval)*time.Minute, func(t time.Time) tea.Msg {
+		return msgBackgroundSyncTick{}
+	})
+}
+
+// handleBackgroundSyncTick runs a periodic sync unless playback or another sync
+// is in progress, and schedules the next one.
+func (m *MainModel) handleBackgroundSyncTick() tea.Cmd {
+	m.syncScheduled = false
+	next := m.scheduleBackgroundSync()
+
+	if !m.cfg.Sync.AutoSync {
+		return next
+	}
+	return tea.Batch(next, func() tea.Msg { return shared.MsgAutoSync{} })
+}
+
+// startSync runs an incremental sync, streaming its progress into the header.
+// It can be stopped with cancelSync.
+func (m *MainModel) startSync(status string) tea.Cmd {
+	m.syncing = true
+	m.syncStatus = status
+	m.syncAdded = 0
+	m.syncRemoved = 0
+	m.syncErrors = nil
+	m.syncTick = 1
+	m.updateSubmodelsSyncStatus()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.syncCancel = cancel
+
+	events := cache.SyncStream(ctx, m.plexClient, m.db, false, m.cfg.Sync.Concurrency)
+	return tea.Batch(tickSync(), waitForSyncEvent(events))
+}
+
+// msgSyncEvent carries one cache.SyncEvent along with its stream.
+type msgSyncEvent struct {
+	event  cache.SyncEvent
+	events <-chan cache.SyncEvent
+}
+
+func waitForSyncEvent(events <-chan cache.SyncEvent) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-events
+		if !ok {
+			return shared.MsgSyncProgress{Done: true}
+		}
+		return msgSyncEvent{event: ev, events: events}
+	}
+}
+
+func (m *MainModel) handleSyncEvent(msg msgSyncEvent) tea.Cmd {
+	ev := msg.event
+	if ev.Section != "" && ev.Err != nil {
+		m.syncErrors = append(m.syncErrors, ev.Section)
+		return waitForSyncEvent(msg.events)
+	}
+
+	cmd := m.handleSyncProgress(shared.MsgSyncProgress{
+		Status:  ev.Status,
+		Added:   ev.Added,
+		Removed: ev.Removed,
+		Done:    ev.Done,
+		Err:     ev.Err,
+	})
+	if ev.Done {
+		return cmd
+	}
+	return tea.Batch(cmd, waitForSyncEvent(msg.events))
+}
+
+// handleSyncProgress updates the header sync status.
+func (m *MainModel) handleSyncProgress(msg shared.MsgSyncProgress) tea.Cmd {
+	m.syncStatus = msg.Status
+	m.syncAdded = msg.Added
+	m.syncRemoved = msg.Removed
+	m.syncing = !msg.Done
+	if msg.Done {
+		m.cancelSync() // Release the context
+		delay := 2 * time.Second
+		switch {
+		case errors.Is(msg.Err, context.Canceled):
+			m.syncStatus = "Sync Cancelled"
+		case msg.Err != nil:
+			m.syncStatus = "Sync Failed"
+			delay = 5 * time.Second
+		case len(m.syncErrors) > 0:
+			m.syncStatus = "Sync Complete • failed: " + strings.Join(m.syncErrors, ", ")
+			delay = 5 * time.Second
+		default:
+			m.syncStatus = "Sync Complete"
+		}
+		m.syncTick = 0
+		m.updateSubmodelsSyncStatus()
+		return tea.Tick(delay, func(t time.Time) tea.Msg { return msgSyncClear{} })
+	}
+	if m.syncStatus != "" && m.syncTick == 0 {
+		m.syncTick = 1
+		m.updateSubmodelsSyncStatus()
+		return tickSync()
+	}
+	m.updateSubmodelsSyncStatus()
+	return nil
+}
+
+// cancelSync stops the running sync, if any, and reports whether there was one.
+// The sync still ends with a Done event once it has rolled back.
+func (m *MainModel) cancelSync() bool {
+	if m.syncCancel == nil {
+		return false
+	}
+	m.syncCancel()
+	m.syncCancel = nil
+	return true
+}
+
+// isPlaying reports whether mpv is running or about to start the next item.
+func (m *MainModel) isPlaying() bool {
+	return m.currentView == shared.ViewPlayer || m.currentView == shared.ViewCountdown || m.currentView == shared.ViewResume
+}
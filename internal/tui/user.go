@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"github.com/Waddenn/plex-client/internal/auth"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	"github.com/Waddenn/plex-client/internal/tui/users"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openUserPicker lists the users of the account's Plex Home.
+func (m *MainModel) openUserPicker() tea.Cmd {
+	client := auth.NewAuthClient(m.cfg.Plex.ClientIdentifier, m.appInfo)
+	m.users = users.NewModel(client, m.cfg.Plex.Token, m.cfg.Plex.UserID)
+	m.users.SyncStatus = m.getSyncDisplay()
+	if m.width > 0 && m.height > 0 {
+		m.users, _ = m.users.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+	}
+	m.currentView = shared.ViewUsers
+	return m.users.Init()
+}
+
+// switchUser makes the picked home user active, with the user's own token
+// and cache. Like switchServer, it is refused while a sync is running.
+func (m *MainModel) switchUser(msg users.MsgSelectUser) tea.Cmd {
+	if m.syncing {
+		m.users.ErrorMsg = "A sync is running. Wait for it to finish or cancel it with ctrl+x."
+		return nil
+	}
+
+	next := m.cfg.Plex
+	next.Users = append([]config.UserConfig(nil), next.Users...)
+	next.Servers = append([]config.ServerConfig(nil), next.Servers...)
+	next.UseUser(msg.User)
+	if server, ok := userServer(next, msg.Servers); ok {
+		next.UseServer(server)
+	}
+	if err := m.connect(next); err != nil {
+		m.users.ErrorMsg = "Could not open the user's cache: " + err.Error()
+		return nil
+	}
+	if err := config.Save(m.cfg); err != nil {
+		m.users.ErrorMsg = "Could not save the configuration: " + err.Error()
+		return nil
+	}
+
+	m.currentView = shared.ViewDashboard
+	m.playQueue = nil
+	m.queueIdx = 0
+	return tea.Batch(m.dashboard.Init(), m.scheduleBackgroundSync(), m.syncNewServer(), waitForDownloadEvent(m.downloads.Events()))
+}
+
+// userServer picks the server to use after a switch among those the user
+// can reach: the active one when the user has access to it, else the
+// first. The saved address of a known server is kept.
+func userServer(p config.PlexConfig, list []config.ServerConfig) (config.ServerConfig, bool) {
+	if len(list) == 0 {
+		return config.ServerConfig{}, false
+	}
+	server := list[0]
+	for _, s := range list {
+		if s.MachineIdentifier == p.ServerID {
+			server = s
+			break
+		}
+	}
+	if saved, ok := p.Server(server.MachineIdentifier); ok {
+		server.BaseURL = saved.BaseURL
+	}
+	return server, true
+}
+
+// userName labels the active home user for headers; empty for the account
+// itself.
+func userName(cfg *config.Config) string {
+	if u, ok := cfg.Plex.User(cfg.Plex.UserID); ok {
+		return u.Title
+	}
+	return ""
+}
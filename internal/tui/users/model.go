@@ -0,0 +1,308 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Waddenn/plex-client/internal/auth"
+	"github.com/Waddenn/plex-client/internal/config"
+	"github.com/Waddenn/plex-client/internal/tui/servers"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MsgSelectUser is sent once plex.tv accepted the switch to a user.
+type MsgSelectUser struct {
+	// User has an empty UUID when switching back to the account itself
+	User config.UserConfig
+	// Servers are the servers the user can reach, with the user's tokens
+	Servers []config.ServerConfig
+}
+
+// Model is the "Switch User" screen listing the Plex Home users.
+type Model struct {
+	authClient *auth.AuthClient
+	token      string // Account token, used to list and switch users
+	width      int
+	height     int
+
+	users   []auth.HomeUser
+	account string // UUID of the account the token belongs to
+	current string // UUID of the active user; empty for the account
+	cursor  int
+
+	loading   bool
+	loadErr   error
+	switching bool
+
+	// PIN entry for protected users
+	pinFor   *auth.HomeUser
+	pinInput textinput.Model
+
+	// Error shown instead of switching, e.g. a wrong PIN
+	ErrorMsg string
+
+	// Sync State
+	SyncStatus string
+}
+
+// NewModel lists the home users of the account of token, current being the
+// active home user.
+func NewModel(client *auth.AuthClient, token, current string) Model {
+	ti := textinput.New()
+	ti.Placeholder = "PIN"
+	ti.CharLimit = 4
+	ti.Width = 10
+	ti.EchoMode = textinput.EchoPassword
+	ti.EchoCharacter = '•'
+
+	return Model{
+		authClient: client,
+		token:      token,
+		width:      80,
+		height:     24,
+		current:    current,
+		loading:    true,
+		pinInput:   ti,
+	}
+}
+
+type msgUsersLoaded struct {
+	users   []auth.HomeUser
+	account string
+	err     error
+}
+
+type msgSwitchFailed struct {
+	err error
+}
+
+func (m Model) Init() tea.Cmd {
+	client, token := m.authClient, m.token
+	return func() tea.Msg {
+		account, err := client.GetUser(token)
+		if err != nil {
+			return msgUsersLoaded{err: err}
+		}
+		users, err := client.GetHomeUsers(token)
+		if err != nil {
+			return msgUsersLoaded{err: err}
+		}
+		return msgUsersLoaded{users: users, account: account.UUID}
+	}
+}
+
+// switchTo signs in as u. The account itself needs no new token, but a
+// protected account still has its PIN checked.
+func (m Model) switchTo(u auth.HomeUser, pin string) tea.Cmd {
+	client, token, isAccount := m.authClient, m.token, u.UUID == m.account
+	return func() tea.Msg {
+		userToken := token
+		if !isAccount || u.Protected {
+			t, err := client.SwitchHomeUser(token, u.UUID, pin)
+			if err != nil {
+				return msgSwitchFailed{err: err}
+			}
+			if !isAccount {
+				userToken = t
+			}
+		}
+		resources, err := client.GetResources(userToken)
+		if err != nil {
+			return msgSwitchFailed{err: err}
+		}
+		return selection(u, isAccount, userToken, servers.FromResources(resources))
+	}
+}
+
+// selection builds the message for a successful switch to u.
+func selection(u auth.HomeUser, isAccount bool, token string, list []config.ServerConfig) MsgSelectUser {
+	msg := MsgSelectUser{Servers: list}
+	if isAccount {
+		return msg
+	}
+	msg.User = config.UserConfig{UUID: u.UUID, Title: userName(u), Token: token, ServerTokens: map[string]string{}}
+	for _, s := range list {
+		if s.AccessToken != "" {
+			msg.User.ServerTokens[s.MachineIdentifier] = s.AccessToken
+		}
+	}
+	return msg
+}
+
+func userName(u auth.HomeUser) string {
+	if u.Title != "" {
+		return u.Title
+	}
+	return u.Username
+}
+
+func (m *Model) selectCurrent() {
+	active := m.current
+	if active == "" {
+		active = m.account
+	}
+	for i, u := range m.users {
+		if u.UUID == active {
+			m.cursor = i
+			return
+		}
+	}
+	if m.cursor >= len(m.users) {
+		m.cursor = 0
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case msgUsersLoaded:
+		m.loading = false
+		m.loadErr = msg.err
+		if msg.err == nil {
+			m.users = msg.users
+			m.account = msg.account
+			m.selectCurrent()
+		}
+
+	case msgSwitchFailed:
+		m.switching = false
+		if errors.Is(msg.err, auth.ErrInvalidPIN) {
+			m.ErrorMsg = "Wrong PIN."
+		} else {
+			m.ErrorMsg = "Could not switch user: " + msg.err.Error()
+		}
+
+	case tea.KeyMsg:
+		if m.switching {
+			return m, nil
+		}
+		if m.pinFor != nil {
+			return m.updatePIN(msg)
+		}
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.users)-1 {
+				m.cursor++
+			}
+		case "enter":
+			if m.cursor >= len(m.users) {
+				return m, nil
+			}
+			u := m.users[m.cursor]
+			m.ErrorMsg = ""
+			if u.Protected {
+				m.pinFor = &u
+				m.pinInput.Reset()
+				return m, m.pinInput.Focus()
+			}
+			m.switching = true
+			return m, m.switchTo(u, "")
+		case "esc", "q", "backspace":
+			return m, func() tea.Msg { return shared.MsgBack{} }
+		}
+	}
+	return m, nil
+}
+
+func (m Model) updatePIN(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.pinFor = nil
+		m.pinInput.Blur()
+		return m, nil
+	case "enter":
+		u, pin := *m.pinFor, m.pinInput.Value()
+		if pin == "" {
+			return m, nil
+		}
+		m.pinFor = nil
+		m.pinInput.Blur()
+		m.switching = true
+		return m, m.switchTo(u, pin)
+	}
+	var cmd tea.Cmd
+	m.pinInput, cmd = m.pinInput.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) View() string {
+	availableWidth := shared.ClampMin(m.width, 20)
+	availableHeight := shared.ClampMin(m.height, 10)
+
+	title := "📂 Plex CLI > Switch User"
+	if m.SyncStatus != "" {
+		title += shared.StyleDim.Render("  " + m.SyncStatus)
+	}
+	header, headerHeight := shared.RenderHeaderLegacySafe(title, availableWidth)
+
+	status := fmt.Sprintf("%d users", len(m.users))
+	switch {
+	case m.loading:
+		status = "Loading users..."
+	case m.switching:
+		status += " • switching..."
+	}
+	help := "[↑/↓] Navigate • [Enter] Switch • [Esc] Back"
+	if m.pinFor != nil {
+		help = "[Enter] Confirm • [Esc] Cancel"
+	}
+	footer, footerHeight := shared.RenderFooterLegacySafe(status, help, availableWidth)
+
+	bodyHeight := shared.ClampMin(availableHeight-headerHeight-footerHeight, 1)
+	var lines []string
+	if m.ErrorMsg != "" {
+		lines = append(lines, lipgloss.NewStyle().Foreground(shared.ColorRed).Padding(0, 2).Render("⚠ "+m.ErrorMsg), "")
+	}
+	switch {
+	case m.loadErr != nil:
+		lines = append(lines, shared.StyleDim.Copy().Padding(0, 2).Render("Could not load users: "+m.loadErr.Error()))
+	case !m.loading && len(m.users) == 0:
+		lines = append(lines, shared.StyleDim.Copy().Padding(0, 2).Render("This account is not part of a Plex Home."))
+	}
+	for i, u := range m.users {
+		lines = append(lines, m.renderRow(u, i == m.cursor, availableWidth))
+	}
+	if m.pinFor != nil {
+		lines = append(lines, "", lipgloss.NewStyle().Padding(0, 2).Render("PIN for "+userName(*m.pinFor)+": "+m.pinInput.View()))
+	}
+	body := lipgloss.NewStyle().Width(availableWidth).Height(bodyHeight).MaxHeight(bodyHeight).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, body, footer)
+}
+
+func (m *Model) renderRow(u auth.HomeUser, active bool, width int) string {
+	prefix := "  "
+	style := shared.StyleItemNormal
+	if active {
+		prefix = shared.SelectionIndicator()
+		style = shared.StyleItemNormal.Copy().Foreground(shared.ColorPlexOrange).Bold(true)
+	}
+
+	label := userName(u)
+	switch {
+	case u.Admin:
+		label += " (admin)"
+	case u.Restricted:
+		label += " (managed)"
+	}
+	if u.Protected {
+		label += " 🔒"
+	}
+	if u.UUID == m.current || m.current == "" && u.UUID == m.account {
+		label += " ✓"
+	}
+
+	maxLen := shared.ClampMin(width-6, 10)
+	return style.Copy().MaxHeight(1).Width(width).Render(prefix + shared.Truncate(label, maxLen))
+}
@@ -0,0 +1,66 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/Waddenn/plex-client/internal/appinfo"
+	"github.com/Waddenn/plex-client/internal/auth"
+	"github.com/Waddenn/plex-client/internal/config"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestProtectedUserAsksForPIN(t *testing.T) {
+	m := NewModel(auth.NewAuthClient("client-id", appinfo.Default()), "token", "")
+	m, _ = m.Update(msgUsersLoaded{
+		account: "admin-uuid",
+		users: []auth.HomeUser{
+			{UUID: "admin-uuid", Title: "Admin", Admin: true},
+			{UUID: "kid-uuid", Title: "Kid", Restricted: true, Protected: true},
+		},
+	})
+	if m.cursor != 0 {
+		t.Fatalf("Expected the account to be preselected, got cursor %d", m.cursor)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.pinFor == nil || m.pinFor.UUID != "kid-uuid" {
+		t.Fatalf("Expected a PIN prompt for the protected user, got %+v", m.pinFor)
+	}
+	if m.switching {
+		t.Error("Expected no switch before the PIN is entered")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.pinFor != nil {
+		t.Error("Expected Esc to cancel the PIN prompt")
+	}
+
+	m, _ = m.Update(msgSwitchFailed{err: auth.ErrInvalidPIN})
+	if m.ErrorMsg != "Wrong PIN." {
+		t.Errorf("Expected a wrong PIN message, got %q", m.ErrorMsg)
+	}
+}
+
+func TestSelection(t *testing.T) {
+	list := []config.ServerConfig{
+		{MachineIdentifier: "home-id", AccessToken: "kid-home-token"},
+		{MachineIdentifier: "other-id"},
+	}
+
+	msg := selection(auth.HomeUser{UUID: "kid-uuid", Username: "kid"}, false, "kid-token", list)
+	if msg.User.UUID != "kid-uuid" || msg.User.Title != "kid" || msg.User.Token != "kid-token" {
+		t.Errorf("Unexpected user %+v", msg.User)
+	}
+	if got := msg.User.ServerTokens["home-id"]; got != "kid-home-token" {
+		t.Errorf("Expected the user's server token, got %q", got)
+	}
+	if _, ok := msg.User.ServerTokens["other-id"]; ok {
+		t.Error("Expected no token for a server without one")
+	}
+
+	msg = selection(auth.HomeUser{UUID: "admin-uuid", Admin: true}, true, "token", list)
+	if msg.User.UUID != "" || len(msg.Servers) != 2 {
+		t.Errorf("Expected a switch back to the account, got %+v", msg)
+	}
+}
@@ -0,0 +1,129 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Waddenn/plex-client/internal/cache"
+	"github.com/Waddenn/plex-client/internal/tui/shared"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Bounds of the wait between attempts to send the outbox while Plex is
+// unreachable.
+var (
+	outboxRetryMin = 5 * time.Second
+	outboxRetryMax = 5 * time.Minute
+)
+
+// msgWatchChanged reports the outcome of a change of watch state.
+type msgWatchChanged struct {
+	Change cache.WatchChange
+	Queued bool
+	Err    error
+}
+
+type msgReplayOutbox struct{}
+
+// msgOutboxReplayed reports an attempt to send the outbox, and when to try
+// again if anything is left.
+type msgOutboxReplayed struct {
+	Next time.Duration
+	Err  error
+}
+
+// openOutbox points the outbox and its replayer at the active cache and
+// server.
+func (m *MainModel) openOutbox() {
+	m.outbox = cache.NewOutbox(m.db)
+	m.replayer = &cache.Replayer{Outbox: m.outbox, Plex: m.plexClient, MinDelay: outboxRetryMin, MaxDelay: outboxRetryMax}
+	m.replayScheduled = false
+}
+
+// changeWatchState stores c in the cache and sends it to Plex, queueing it
+// when Plex cannot be reached.
+func (m *MainModel) changeWatchState(c cache.WatchChange) tea.Cmd {
+	reporter := m.watchReporter()
+	return m.work.Cmd(func() tea.Msg {
+		queued, err := reporter.Change(context.Background(), c)
+		return msgWatchChanged{Change: c, Queued: queued, Err: err}
+	})
+}
+
+// handleWatchChanged reports the outcome of a change in the browser and
+// schedules a replay when it was queued.
+func (m *MainModel) handleWatchChanged(msg msgWatchChanged) tea.Cmd {
+	if msg.Err != nil && !msg.Queued {
+		m.browser.Notice = fmt.Sprintf("⚠ Could not %s: %v", msg.Change, msg.Err)
+		return nil
+	}
+	m.updateWatchNotice()
+	return m.scheduleReplay(m.replayer.Delay())
+}
+
+// scheduleReplay arms an attempt to send the outbox after delay, unless
+// one is pending or the outbox is empty.
+func (m *MainModel) scheduleReplay(delay time.Duration) tea.Cmd {
+	if m.replayScheduled {
+		return nil
+	}
+	if n, err := m.outbox.Len(); err != nil || n == 0 {
+		return nil
+	}
+	m.replayScheduled = true
+	return tea.Tick(delay, func(time.Time) tea.Msg { return msgReplayOutbox{} })
+}
+
+// replayOutbox sends what the outbox holds.
+func (m *MainModel) replayOutbox() tea.Cmd {
+	replayer, ctx := m.replayer, m.work.Context()
+	return m.work.Cmd(func() tea.Msg {
+		next, err := replayer.Replay(ctx)
+		return msgOutboxReplayed{Next: next, Err: err}
+	})
+}
+
+// updateWatchNotice shows in the browser how many updates wait for Plex.
+func (m *MainModel) updateWatchNotice() {
+	n, _ := m.outbox.Len()
+	switch n {
+	case 0:
+		m.browser.Notice = ""
+	case 1:
+		m.browser.Notice = "⚠ Offline: 1 update waiting for Plex"
+	default:
+		m.browser.Notice = fmt.Sprintf("⚠ Offline: %d updates waiting for Plex", n)
+	}
+}
+
+// watchReporter returns the sink for watch state, mirroring it into the
+// cache and queueing in the outbox what Plex could not receive.
+func (m *MainModel) watchReporter() *cache.WatchStateReporter {
+	return &cache.WatchStateReporter{Plex: m.plexClient, DB: m.db, Outbox: m.outbox}
+}
+
+// handleWatchMsg routes the messages of watch changes and of the outbox,
+// and reports whether msg was one.
+func (m *MainModel) handleWatchMsg(msg tea.Msg) (tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case shared.MsgWatchChange:
+		c, ok := msg.Change.(cache.WatchChange)
+		if !ok {
+			return nil, true
+		}
+		return m.changeWatchState(c), true
+	case msgWatchChanged:
+		return m.handleWatchChanged(msg), true
+	case msgReplayOutbox:
+		return m.replayOutbox(), true
+	case msgOutboxReplayed:
+		m.replayScheduled = false
+		m.updateWatchNotice()
+		if msg.Next == 0 {
+			return nil, true
+		}
+		return m.scheduleReplay(msg.Next), true
+	}
+	return nil, false
+}